@@ -0,0 +1,199 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/pokitpeng/ai/pkg/agent"
+	"github.com/pokitpeng/ai/pkg/history"
+	"github.com/pokitpeng/ai/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// agentCmd represents the agent subcommand
+var agentCmd = &cobra.Command{
+	Use:   "agent <task>",
+	Short: "Let the model use local tools to carry out a task",
+	Long: `Let the model carry out a task in the current directory with local tools: reading files,
+listing directories, searching with grep, running allowlisted commands and proposing patches.
+Files outside the current directory can't be reached, by the tools or by the paths given to
+commands. Commands that can run others or delete files, such as find or go, must be given to
+--allow and then run with your permissions. Every command and patch is shown and needs your
+confirmation; with --dry-run they are only listed.
+
+Examples:
+  ai agent "Why does the build fail?"
+  ai agent --allow go,make "Run the tests and fix the failures"
+  ai agent --dry-run "Add a README for the pkg/util package"`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runAgent(cmd, args[0])
+	},
+}
+
+// runAgent asks the model to carry out a task with the sandboxed tools
+func runAgent(cmd *cobra.Command, task string) {
+	model, err := getModel(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	// Later steps continue the conversation without a question, so keep the model routed for the task
+	model = routeOnce(cmd, model, models.QuestionRequest(task))
+
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	allow, _ := cmd.Flags().GetStringSlice("allow")
+	maxSteps, _ := cmd.Flags().GetInt("max-steps")
+
+	// Text streamed by the model and the actions are printed as they come
+	out := &agentOutput{}
+	stdin := bufio.NewReader(os.Stdin)
+	options := []agent.Option{
+		agent.WithCommands(slices.Concat(agent.DefaultCommands, allow)...),
+		agent.WithObserver(func(action agent.Action) { out.action(action, dryRun) }),
+		agent.WithApproval(func(action agent.Action) bool { return confirm(stdin, "Apply?") }),
+	}
+	if dryRun {
+		options = append(options, agent.WithDryRun())
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	sandbox, err := agent.NewSandbox(wd, options...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+
+	registry := models.NewToolRegistry()
+	registry.SetMaxSteps(maxSteps)
+	if err := sandbox.Register(registry); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+
+	systemPrompt, err := withProjectPrompt(sandbox.SystemPrompt())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	chatOptions := []models.ChatOption{models.WithSystemPrompt(systemPrompt)}
+
+	// Get history if needed
+	noHistory, _ := cmd.Flags().GetBool("no-history")
+	historyEnabled := modelManager.GetDefaults().HistoryEnabled()
+	if historyEnabled && !noHistory && !historyManager.IsEmpty() {
+		chatOptions = append(chatOptions, models.WithHistory(convertToModelMessages(historyManager.GetMessages())))
+	}
+
+	checkBudget()
+	info := &models.ResponseInfo{}
+	chatOptions = append(chatOptions, models.WithResponseInfo(info), models.WithStreamHandler(out.text))
+	answer, err := registry.Run(context.Background(), model, task, chatOptions...)
+	out.endLine()
+	reportFallbacks(info, err)
+	if info.Model != "" {
+		recordUsage("agent", info)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if !errors.Is(err, models.ErrToolStepLimit) {
+			return
+		}
+	}
+
+	if dryRun {
+		printProposedActions(sandbox.Proposed())
+	}
+
+	// Add to history, with the tool calls
+	if historyEnabled {
+		historyManager.AddUserMessage(task)
+		historyManager.AddAssistantReplyWithTools(answer, newGeneration(info), historyToolCalls(info.ToolResults))
+	}
+}
+
+// agentOutput prints the streamed text and the actions of an agent, keeping actions on their own lines
+type agentOutput struct {
+	midLine bool
+}
+
+// text prints streamed text
+func (o *agentOutput) text(chunk string) {
+	fmt.Print(chunk)
+	o.midLine = !strings.HasSuffix(chunk, "\n")
+}
+
+// action prints an action about to run, with the command or change it makes
+func (o *agentOutput) action(action agent.Action, dryRun bool) {
+	o.endLine()
+	if !action.SideEffect {
+		fmt.Printf("→ %s\n", action.Summary)
+		return
+	}
+
+	if dryRun {
+		fmt.Printf("→ %s (dry run, not applied)\n", action.Summary)
+		return
+	}
+	fmt.Printf("→ %s\n%s\n", action.Summary, indent(action.Detail))
+}
+
+// endLine ends a line of streamed text
+func (o *agentOutput) endLine() {
+	if o.midLine {
+		fmt.Println()
+		o.midLine = false
+	}
+}
+
+// printProposedActions lists the actions recorded in a dry run
+func printProposedActions(actions []agent.Action) {
+	if len(actions) == 0 {
+		fmt.Println("\nNo changes proposed.")
+		return
+	}
+
+	fmt.Println("\nProposed actions (dry run, nothing was changed):")
+	for i, action := range actions {
+		fmt.Printf("\n%d. %s\n%s\n", i+1, action.Summary, indent(action.Detail))
+	}
+}
+
+// indent indents each line of text
+func indent(text string) string {
+	return "    " + strings.ReplaceAll(text, "\n", "\n    ")
+}
+
+// historyToolCalls converts the tool calls of an agent run into history records
+func historyToolCalls(results []models.ToolResult) []history.ToolCall {
+	var calls []history.ToolCall
+	for _, result := range results {
+		call := history.ToolCall{
+			Name:      result.Call.Function.Name,
+			Arguments: result.Call.Function.Arguments,
+		}
+		if result.Err != nil {
+			call.Error = result.Err.Error()
+		} else {
+			call.Output = result.Output
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+func init() {
+	rootCmd.AddCommand(agentCmd)
+	agentCmd.Flags().Bool("dry-run", false, "Only show the commands and patches the model proposes")
+	agentCmd.Flags().StringSlice("allow", nil, "Additional commands the model may run (default "+strings.Join(agent.DefaultCommands, ", ")+")")
+	agentCmd.Flags().Int("max-steps", models.DefaultMaxToolSteps, "Maximum number of tool call rounds")
+}
@@ -0,0 +1,91 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd represents the cache subcommand
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the response cache",
+	Long: `Inspect and clear the on-disk cache of answers.
+
+The cache is off by default. When enabled, a request with the same model, messages,
+temperature and max tokens as an earlier one is answered from disk:
+  ai config set cache true
+  ai config set cache_ttl 24h
+  ai config set cache_max_size 100
+
+Use --no-cache to skip the cache for one request, or --refresh to ask the model again and update the cache.`,
+}
+
+// cacheStatsCmd shows the size of the response cache
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show the response cache size",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		defaults := modelManager.GetDefaults()
+		cache := defaults.ResponseCache()
+
+		stats, err := cache.Stats()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read cache: %v\n", err)
+			os.Exit(1)
+		}
+
+		status := "disabled"
+		if defaults.CacheEnabled() {
+			status = "enabled"
+		}
+
+		fmt.Printf("Status:    %s\n", status)
+		fmt.Printf("Directory: %s\n", cache.Dir())
+		fmt.Printf("Entries:   %d (%d expired)\n", stats.Entries, stats.Expired)
+		fmt.Printf("Size:      %s of %s\n", formatBytes(stats.Size), formatBytes(cache.MaxSize()))
+		fmt.Printf("TTL:       %s\n", cache.TTL())
+		if stats.Entries > 0 {
+			fmt.Printf("Oldest:    %s\n", stats.Oldest.Format(time.DateTime))
+			fmt.Printf("Newest:    %s\n", stats.Newest.Format(time.DateTime))
+		}
+	},
+}
+
+// cacheClearCmd removes all cached answers
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Remove all cached answers",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		removed, err := modelManager.GetDefaults().ResponseCache().Clear()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to clear cache: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Removed %d cached answer(s)\n", removed)
+	},
+}
+
+// formatBytes formats a size in bytes with a binary unit
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
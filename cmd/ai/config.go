@@ -0,0 +1,417 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pokitpeng/ai/pkg/models"
+	"github.com/pokitpeng/ai/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// configSetting describes a global setting that can be read and written with 'ai config'
+type configSetting struct {
+	key         string
+	description string
+	get         func(d *models.DefaultsConfig) string
+	set         func(d *models.DefaultsConfig, value string) error
+}
+
+// configSettings lists the global settings in display order
+var configSettings = []configSetting{
+	{
+		key:         "default_model",
+		description: "Model used when none is specified",
+		get:         func(d *models.DefaultsConfig) string { return d.Model },
+		set: func(d *models.DefaultsConfig, value string) error {
+			d.Model = value
+			return nil
+		},
+	},
+	{
+		key:         "temperature",
+		description: "Default temperature for models without their own options",
+		get: func(d *models.DefaultsConfig) string {
+			if d.Temperature == nil {
+				return ""
+			}
+			return strconv.FormatFloat(*d.Temperature, 'f', -1, 64)
+		},
+		set: func(d *models.DefaultsConfig, value string) error {
+			if value == "" {
+				d.Temperature = nil
+				return nil
+			}
+			temperature, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid temperature: %s", value)
+			}
+			d.Temperature = &temperature
+			return nil
+		},
+	},
+	{
+		key:         "max_tokens",
+		description: "Default maximum tokens for models without their own options",
+		get: func(d *models.DefaultsConfig) string {
+			if d.MaxTokens == 0 {
+				return ""
+			}
+			return strconv.Itoa(d.MaxTokens)
+		},
+		set: func(d *models.DefaultsConfig, value string) error {
+			if value == "" {
+				d.MaxTokens = 0
+				return nil
+			}
+			maxTokens, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid max tokens: %s", value)
+			}
+			d.MaxTokens = maxTokens
+			return nil
+		},
+	},
+	{
+		key:         "stream",
+		description: "Default streaming for models without their own options",
+		get:         func(d *models.DefaultsConfig) string { return formatOptionalBool(d.Stream) },
+		set:         func(d *models.DefaultsConfig, value string) error { return parseOptionalBool(value, &d.Stream) },
+	},
+	{
+		key:         "history",
+		description: "Send and record conversation history (true/false)",
+		get:         func(d *models.DefaultsConfig) string { return strconv.FormatBool(d.HistoryEnabled()) },
+		set:         func(d *models.DefaultsConfig, value string) error { return parseOptionalBool(value, &d.History) },
+	},
+	{
+		key:         "output",
+		description: "Answer output format (text/json)",
+		get:         func(d *models.DefaultsConfig) string { return d.OutputFormat() },
+		set: func(d *models.DefaultsConfig, value string) error {
+			d.Output = value
+			return nil
+		},
+	},
+	{
+		key:         "monthly_budget",
+		description: "Warn before requests once this month's cost reaches 80% of this amount (USD)",
+		get: func(d *models.DefaultsConfig) string {
+			if d.MonthlyBudget == 0 {
+				return ""
+			}
+			return strconv.FormatFloat(d.MonthlyBudget, 'f', -1, 64)
+		},
+		set: func(d *models.DefaultsConfig, value string) error {
+			if value == "" {
+				d.MonthlyBudget = 0
+				return nil
+			}
+			budget, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid budget: %s", value)
+			}
+			d.MonthlyBudget = budget
+			return nil
+		},
+	},
+	{
+		key:         "session_scope",
+		description: "Active session shared everywhere or kept per repository (global/directory)",
+		get:         func(d *models.DefaultsConfig) string { return d.SessionScopeOrDefault() },
+		set: func(d *models.DefaultsConfig, value string) error {
+			d.SessionScope = value
+			return nil
+		},
+	},
+	{
+		key:         "cache",
+		description: "Answer repeated requests from the response cache (true/false)",
+		get:         func(d *models.DefaultsConfig) string { return strconv.FormatBool(d.CacheEnabled()) },
+		set:         func(d *models.DefaultsConfig, value string) error { return parseOptionalBool(value, &d.Cache) },
+	},
+	{
+		key:         "cache_ttl",
+		description: "How long cached answers are used (e.g. 24h)",
+		get: func(d *models.DefaultsConfig) string {
+			if d.CacheTTL == 0 {
+				return ""
+			}
+			return d.CacheTTL.String()
+		},
+		set: func(d *models.DefaultsConfig, value string) error {
+			if value == "" {
+				d.CacheTTL = 0
+				return nil
+			}
+			ttl, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid duration: %s", value)
+			}
+			d.CacheTTL = ttl
+			return nil
+		},
+	},
+	{
+		key:         "cache_max_size",
+		description: "Response cache size in MB above which the oldest answers are removed",
+		get: func(d *models.DefaultsConfig) string {
+			if d.CacheMaxSize == 0 {
+				return ""
+			}
+			return strconv.Itoa(d.CacheMaxSize)
+		},
+		set: func(d *models.DefaultsConfig, value string) error {
+			if value == "" {
+				d.CacheMaxSize = 0
+				return nil
+			}
+			size, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid size: %s", value)
+			}
+			d.CacheMaxSize = size
+			return nil
+		},
+	},
+	{
+		key:         "fallbacks",
+		description: "Models or @groups tried in turn when a model fails, after its own fallbacks",
+		get:         func(d *models.DefaultsConfig) string { return strings.Join(d.Fallbacks, ",") },
+		set: func(d *models.DefaultsConfig, value string) error {
+			d.Fallbacks = nil
+			for _, name := range strings.Split(value, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					d.Fallbacks = append(d.Fallbacks, name)
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// findConfigSetting looks up a global setting by key
+func findConfigSetting(key string) (*configSetting, error) {
+	for i := range configSettings {
+		if configSettings[i].key == key {
+			return &configSettings[i], nil
+		}
+	}
+
+	keys := make([]string, len(configSettings))
+	for i, setting := range configSettings {
+		keys[i] = setting.key
+	}
+	return nil, fmt.Errorf("unknown setting %q, available settings: %s", key, strings.Join(keys, ", "))
+}
+
+func formatOptionalBool(value *bool) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*value)
+}
+
+// parseOptionalBool parses a boolean setting, an empty value resets it
+func parseOptionalBool(value string, target **bool) error {
+	if value == "" {
+		*target = nil
+		return nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid boolean: %s", value)
+	}
+	*target = &parsed
+	return nil
+}
+
+// configCmd represents the config subcommand
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the configuration file",
+	Long:  `Inspect and change global settings, edit and validate the configuration file.`,
+}
+
+// configGetCmd shows global settings
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Show global settings",
+	Long:  `Show the value of a global setting, or all settings when no key is given.`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		defaults := modelManager.GetDefaults()
+
+		if len(args) == 1 {
+			setting, err := findConfigSetting(args[0])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(setting.get(&defaults))
+			return
+		}
+
+		for _, setting := range configSettings {
+			value := setting.get(&defaults)
+			if value == "" {
+				value = "(not set)"
+			}
+			fmt.Printf("%-14s %-12s %s\n", setting.key, value, setting.description)
+		}
+	},
+}
+
+// configSetCmd changes a global setting
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a global setting",
+	Long:  `Change a global setting. An empty value resets the setting to its default.`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		setting, err := findConfigSetting(args[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		defaults := modelManager.GetDefaults()
+		if err := setting.set(&defaults, args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if err := modelManager.SetDefaults(defaults); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to update setting: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Show the value in effect, an empty default model falls back to the first one
+		defaults = modelManager.GetDefaults()
+		fmt.Printf("Set %s to %s\n", setting.key, setting.get(&defaults))
+	},
+}
+
+// configEditCmd opens the configuration file in an editor
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the configuration file",
+	Long:  `Open the configuration file in $VISUAL or $EDITOR (vi by default) and validate it afterwards.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile := modelManager.ConfigFile()
+
+		editor := os.Getenv("VISUAL")
+		if editor == "" {
+			editor = os.Getenv("EDITOR")
+		}
+		if editor == "" {
+			editor = "vi"
+		}
+
+		// The editor setting may contain arguments, e.g. "code --wait"
+		editorArgs := strings.Fields(editor)
+		editCmd := exec.Command(editorArgs[0], append(editorArgs[1:], configFile)...)
+		editCmd.Stdin = os.Stdin
+		editCmd.Stdout = os.Stdout
+		editCmd.Stderr = os.Stderr
+
+		if err := editCmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Editor failed: %v\n", err)
+			os.Exit(1)
+		}
+
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read config file: %v\n", err)
+			os.Exit(1)
+		}
+
+		if issues := models.ValidateConfig(data); len(issues) > 0 {
+			printConfigIssues(configFile, issues)
+			os.Exit(1)
+		}
+
+		fmt.Printf("%s is valid\n", configFile)
+	},
+}
+
+// configPathCmd prints the configuration file path
+var configPathCmd = &cobra.Command{
+	Use:   "path",
+	Short: "Print the configuration file path",
+	Long:  `Print the path of the configuration file. With --all, also print the data directory and the project configuration in effect.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+		if !all {
+			fmt.Println(modelManager.ConfigFile())
+			return
+		}
+
+		projectFile := "(none)"
+		if projectConfig != nil {
+			projectFile = projectConfig.Path
+		}
+
+		fmt.Printf("config:  %s\n", modelManager.ConfigFile())
+		fmt.Printf("data:    %s\n", util.DataDir())
+		fmt.Printf("project: %s\n", projectFile)
+	},
+}
+
+// configValidateCmd validates the configuration file
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Validate the configuration file",
+	Long:  `Check the configuration file for syntax errors, unknown fields and invalid values, reporting line numbers.`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile := modelManager.ConfigFile()
+		if len(args) > 0 {
+			configFile = args[0]
+		}
+
+		data, err := os.ReadFile(configFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read config file: %v\n", err)
+			os.Exit(1)
+		}
+
+		issues := models.ValidateConfig(data)
+		if len(issues) == 0 {
+			fmt.Printf("%s is valid\n", configFile)
+			return
+		}
+
+		printConfigIssues(configFile, issues)
+		os.Exit(1)
+	},
+}
+
+// printConfigIssues prints validation problems in file:line form
+func printConfigIssues(configFile string, issues []models.ConfigIssue) {
+	for _, issue := range issues {
+		if issue.Line > 0 {
+			fmt.Fprintf(os.Stderr, "%s:%d: %s: %s\n", configFile, issue.Line, issue.Path, issue.Message)
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: %s: %s\n", configFile, issue.Path, issue.Message)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Found %d problem(s)\n", len(issues))
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configPathCmd)
+	configCmd.AddCommand(configValidateCmd)
+
+	configPathCmd.Flags().Bool("all", false, "Also print the data directory and project configuration")
+}
@@ -0,0 +1,48 @@
+package ai
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/pokitpeng/ai/pkg/models"
+)
+
+// debugEnv enables debugging like --debug when set to 1 or true, or like --debug-file when set to a path
+const debugEnv = "AI_DEBUG"
+
+// setupDebug logs the API traffic and model calls when --debug, --debug-file or AI_DEBUG is set
+func setupDebug() {
+	debug, _ := rootCmd.PersistentFlags().GetBool("debug")
+	debugFile, _ := rootCmd.PersistentFlags().GetString("debug-file")
+
+	if !debug && debugFile == "" {
+		switch value := os.Getenv(debugEnv); strings.ToLower(value) {
+		case "", "0", "false":
+		case "1", "true":
+			debug = true
+		default:
+			debugFile = value
+		}
+	}
+
+	var output io.Writer = os.Stderr
+	if debugFile != "" {
+		file, err := os.OpenFile(debugFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open debug log, logging to stderr: %v\n", err)
+		} else {
+			output = file
+		}
+		debug = true
+	}
+	if !debug {
+		return
+	}
+
+	models.SetDebugOutput(output)
+	slog.SetDefault(slog.New(slog.NewTextHandler(output, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	modelManager.Use(models.Logging(slog.Default()))
+}
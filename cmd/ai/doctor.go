@@ -0,0 +1,62 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pokitpeng/ai/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// doctorCmd checks that a model can be reached and used
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [model]",
+	Short: "Diagnose connection problems with a model",
+	Long: `Send a minimal request to a model and report DNS, TLS, authentication and
+model availability problems in plain language. Uses the default model when none is given.
+
+Use --debug to see the full request and response.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := modelManager.GetDefaultModelName()
+		if len(args) > 0 {
+			name = args[0]
+		}
+		if name == "" {
+			fmt.Fprintln(os.Stderr, "No model configured, add one with: ai model add <model> <url> <apikey>")
+			os.Exit(1)
+		}
+
+		config, err := modelManager.ResolvedModelConfig(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v: %s\n", err, name)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Checking %s\n\n", name)
+
+		failed := false
+		for _, check := range models.Diagnose(context.Background(), config) {
+			mark := "✓"
+			switch check.Status {
+			case models.CheckFailed:
+				mark = "✗"
+				failed = true
+			case models.CheckSkipped:
+				mark = "-"
+			}
+
+			fmt.Printf("%s %-14s %s\n", mark, check.Name, check.Detail)
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+		fmt.Printf("\n%s is ready to use\n", name)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
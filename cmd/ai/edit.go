@@ -0,0 +1,199 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/pokitpeng/ai/pkg/edit"
+	"github.com/pokitpeng/ai/pkg/models"
+	"github.com/pokitpeng/ai/pkg/util"
+	"github.com/spf13/cobra"
+)
+
+// editAttempts is how many times the model is asked for edits that apply
+const editAttempts = 3
+
+// editCmd represents the edit subcommand
+var editCmd = &cobra.Command{
+	Use:   "edit <file>... <instruction>",
+	Short: "Let the model edit files",
+	Long: `Ask the model to change files following an instruction. The model answers with
+SEARCH/REPLACE blocks, which are checked to apply to the files and shown as a diff.
+The files are written once you confirm, keeping a backup to restore with --undo.
+
+Examples:
+  ai edit main.go "Extract the HTTP handlers into functions"
+  ai edit server.go server_test.go "Rename Start to Run"
+  ai edit --undo`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if undo, _ := cmd.Flags().GetBool("undo"); undo {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(2)(cmd, args)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		if undo, _ := cmd.Flags().GetBool("undo"); undo {
+			undoEdit()
+			return
+		}
+
+		editFiles(cmd, args[:len(args)-1], args[len(args)-1])
+	},
+}
+
+// editFiles asks the model to edit files and writes the changes once confirmed
+func editFiles(cmd *cobra.Command, fileNames []string, instruction string) {
+	files, err := edit.LoadFiles(fileNames)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read file: %v\n", err)
+		return
+	}
+
+	model, err := getModel(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	request := models.RouteRequest{Command: models.RouteCommandFile, Question: instruction, Files: fileNames, Length: utf8.RuneCountInString(instruction)}
+	for _, file := range files {
+		request.Length += utf8.RuneCountInString(file.Content)
+	}
+	// Retries continue the conversation, so keep the model routed for the edit
+	model = routeOnce(cmd, model, request)
+
+	systemPrompt, err := withProjectPrompt(edit.SystemPrompt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+
+	// Ask for edits until they apply, telling the model what was wrong
+	checkBudget()
+	question := edit.Prompt(instruction, files)
+	var conversation []models.Message
+	var changed map[string]string
+	for attempt := 1; ; attempt++ {
+		info := &models.ResponseInfo{}
+		answer, err := model.Chat(context.Background(), question,
+			models.WithSystemPrompt(systemPrompt),
+			models.WithHistory(conversation),
+			models.WithStream(false),
+			models.WithResponseInfo(info),
+			models.WithCacheMode(getCacheMode(cmd)),
+		)
+		reportFallbacks(info, err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+		recordUsage("edit", info)
+
+		blocks, err := edit.Parse(answer)
+		if err == nil {
+			changed, err = edit.Apply(files, blocks)
+		}
+		if err == nil {
+			break
+		}
+		if attempt == editAttempts {
+			fmt.Fprintf(os.Stderr, "Error: the edits of the model don't apply: %v\n", err)
+			return
+		}
+
+		fmt.Fprintf(os.Stderr, "The edits don't apply (%v), asking again...\n", err)
+		conversation = append(conversation,
+			models.Message{Role: "user", Content: question},
+			models.Message{Role: "assistant", Content: answer},
+		)
+		question = fmt.Sprintf("Your edits could not be applied: %v. Reply again with all the SEARCH/REPLACE blocks, corrected. Each SEARCH part must match the current file exactly, once.", err)
+	}
+
+	if len(changed) == 0 {
+		fmt.Println("No changes.")
+		return
+	}
+
+	// Show the changes in the order the files were given
+	color := isTerminal()
+	for _, file := range files {
+		if content, ok := changed[file.Name]; ok {
+			printDiff(edit.Diff(file.Name, file.Content, content), color)
+		}
+	}
+
+	if yes, _ := cmd.Flags().GetBool("yes"); !yes && !confirm(bufio.NewReader(os.Stdin), fmt.Sprintf("Apply the changes to %d file(s)?", len(changed))) {
+		fmt.Println("No files changed.")
+		return
+	}
+
+	if _, err := editBackups().Save(instruction, files, changed); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to save backup: %v\n", err)
+		return
+	}
+	if err := edit.WriteFiles(changed); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	fmt.Printf("Changed %d file(s). Use 'ai edit --undo' to restore them.\n", len(changed))
+}
+
+// undoEdit restores the files of the latest edit
+func undoEdit() {
+	backup, err := editBackups().Undo()
+	if errors.Is(err, edit.ErrNoBackup) {
+		fmt.Println("No edit to undo.")
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to undo: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Undid %q from %s:\n", backup.Instruction, backup.Time.Format("2006-01-02 15:04:05"))
+	for _, file := range backup.Files {
+		fmt.Printf("  restored %s\n", file.Path)
+	}
+}
+
+// editBackups returns the store of the backups of edited files
+func editBackups() *edit.Backups {
+	return edit.NewBackups(filepath.Join(util.DataDir(), "backups"))
+}
+
+// printDiff prints a unified diff, colored for a terminal
+func printDiff(diff string, color bool) {
+	for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		if color {
+			switch {
+			case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+				line = text.Bold.Sprint(line)
+			case strings.HasPrefix(line, "@@"):
+				line = text.FgCyan.Sprint(line)
+			case strings.HasPrefix(line, "+"):
+				line = text.FgGreen.Sprint(line)
+			case strings.HasPrefix(line, "-"):
+				line = text.FgRed.Sprint(line)
+			}
+		}
+		fmt.Println(line)
+	}
+}
+
+// isTerminal reports whether standard output is a terminal
+func isTerminal() bool {
+	_, _, ok := util.TerminalSize(os.Stdout)
+	return ok
+}
+
+func init() {
+	rootCmd.AddCommand(editCmd)
+	editCmd.Flags().Bool("undo", false, "Restore the files changed by the latest edit")
+	editCmd.Flags().BoolP("yes", "y", false, "Write the changes without asking")
+}
@@ -14,10 +14,11 @@ var fileCmd = &cobra.Command{
 		filePath := args[0]
 		question := args[1]
 
-		askWithFile(filePath, question)
+		askWithFile(cmd, filePath, question)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(fileCmd)
+	addJSONFlags(fileCmd)
 }
@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/pokitpeng/ai/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// groupCmd represents the group subcommand
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Manage named groups of models",
+	Long: `Manage named groups of models. A group, or a tag set with 'ai model options <name> --tags',
+is written @name wherever models are selected:
+
+  ai group add fast gpt-4o-mini,qwen-7b
+  ai multi @fast "What is functional programming?"
+  ai -m @local "Explain closures"
+
+Where one model is expected, the first model of the group is used.`,
+}
+
+// groupListCmd lists groups and tags
+var groupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List model groups and tags",
+	Long:  `List the groups of the configuration and the tags set on models, with their models.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		groups := modelManager.ListGroups()
+
+		// Tags select their models like groups
+		tags := make(map[string][]string)
+		for name, config := range modelManager.ListModels() {
+			for _, tag := range config.Tags {
+				tags[tag] = append(tags[tag], name)
+			}
+		}
+
+		if len(groups) == 0 && len(tags) == 0 {
+			fmt.Println("No groups configured. Use 'ai group add' to add a group.")
+			return
+		}
+
+		// Create table
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.SetStyle(table.StyleLight)
+		t.Style().Options.SeparateRows = true
+
+		t.AppendHeader(table.Row{"Name", "Kind", "Models"})
+
+		for _, name := range sortedNames(groups) {
+			t.AppendRow(table.Row{models.GroupPrefix + name, "group", strings.Join(groups[name], ", ")})
+		}
+		for _, name := range sortedNames(tags) {
+			// A group hides the tag with the same name
+			if _, exists := groups[name]; exists {
+				continue
+			}
+			sort.Strings(tags[name])
+			t.AppendRow(table.Row{models.GroupPrefix + name, "tag", strings.Join(tags[name], ", ")})
+		}
+
+		t.Render()
+	},
+}
+
+// groupAddCmd adds a group
+var groupAddCmd = &cobra.Command{
+	Use:   "add <name> <model1,model2,...>",
+	Short: "Add a model group",
+	Long:  `Add a named group of models, selected with @name. Models are asked in the order given.`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := strings.TrimPrefix(args[0], models.GroupPrefix)
+
+		var members []string
+		for _, member := range strings.Split(args[1], ",") {
+			if member = strings.TrimSpace(member); member != "" {
+				members = append(members, member)
+			}
+		}
+
+		if err := modelManager.AddGroup(name, members); err != nil {
+			fmt.Printf("Failed to add group: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Group '%s' added successfully, use it as %s%s\n", name, models.GroupPrefix, name)
+	},
+}
+
+// groupRemoveCmd removes a group
+var groupRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a model group",
+	Long:  `Remove a model group. The models themselves are kept.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		if err := modelManager.RemoveGroup(name); err != nil {
+			fmt.Printf("Failed to remove group: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Group '%s' removed successfully\n", strings.TrimPrefix(name, models.GroupPrefix))
+	},
+}
+
+// sortedNames returns the keys of a map of model lists in sorted order
+func sortedNames(m map[string][]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	rootCmd.AddCommand(groupCmd)
+	groupCmd.AddCommand(groupListCmd)
+	groupCmd.AddCommand(groupAddCmd)
+	groupCmd.AddCommand(groupRemoveCmd)
+}
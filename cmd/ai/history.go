@@ -0,0 +1,83 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pokitpeng/ai/pkg/history"
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history subcommand
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Manage the history store",
+	Long:  `Search the conversation history and move it between storage backends.`,
+}
+
+// historyMigrateCmd copies the history to another store
+var historyMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Move the history to another store",
+	Long: `Copy all sessions to another store and use it from now on.
+
+Stores:
+  json    One JSON file per session (default)
+  sqlite  A SQLite database with full-text search, faster with many sessions
+
+The previous store is kept: JSON files stay in place, a SQLite database is renamed to history.db.bak.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		to, _ := cmd.Flags().GetString("to")
+		if to != history.StoreJSON && to != history.StoreSQLite {
+			fmt.Fprintf(os.Stderr, "Error: unknown store %q, expected %s or %s\n", to, history.StoreJSON, history.StoreSQLite)
+			os.Exit(1)
+		}
+
+		// Release the store in use before moving it
+		if historyManager != nil {
+			historyManager.Close()
+		}
+
+		count, err := history.Migrate(historyDir(), to)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to migrate history: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Migrated %d session(s) to %s\n", count, to)
+	},
+}
+
+// historySearchCmd searches the messages of all sessions
+var historySearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Search the conversation history",
+	Long:  `List the sessions with a message containing all words of the query.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sessions, err := historyManager.SearchSessions(strings.Join(args, " "))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to search history: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(sessions) == 0 {
+			fmt.Println("No matching sessions found")
+			return
+		}
+
+		printSessionTable(sessions)
+		fmt.Println("Use 'ai session switch <ID>' to continue a session")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.AddCommand(historyMigrateCmd)
+	historyCmd.AddCommand(historySearchCmd)
+
+	historyMigrateCmd.Flags().String("to", "", "Target store (json or sqlite)")
+	historyMigrateCmd.MarkFlagRequired("to")
+}
@@ -1,8 +1,12 @@
 package ai
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	neturl "net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/jedib0t/go-pretty/v6/table"
@@ -53,13 +57,15 @@ var modelListCmd = &cobra.Command{
 			{Number: 3, WidthMax: 30, WidthMin: 10, Transformer: truncateString(30)},
 			{Number: 4, WidthMax: 20, WidthMin: 10, Transformer: truncateString(20)},
 			{Number: 5, WidthMax: 30, WidthMin: 15},
+			{Number: 6, WidthMax: 20},
 		})
 
 		// Add header
-		t.AppendHeader(table.Row{"Default", "Name", "URL", "API Key", "Parameters"})
+		t.AppendHeader(table.Row{"Default", "Name", "URL", "API Key", "Parameters", "Groups"})
 
 		// Get global default options
-		globalDefaults := models.DefaultChatOptions()
+		defaults := modelManager.GetDefaults()
+		globalDefaults := defaults.ChatOptions()
 
 		// Add data rows
 		for name, config := range modelsList {
@@ -68,8 +74,22 @@ var modelListCmd = &cobra.Command{
 				defaultMark = "✓"
 			}
 
+			// Show connection details, including those inherited from a profile
+			url, apiKey := config.URL, config.APIKey
+			if config.Profile != "" {
+				if provider, err := modelManager.GetProvider(config.Profile); err == nil {
+					if url == "" {
+						url = provider.URL
+					}
+					if apiKey == "" {
+						apiKey = provider.ResolveAPIKey()
+					}
+				}
+				url = fmt.Sprintf("[%s] %s", config.Profile, url)
+			}
+
 			// Mask API Key
-			apiKeyMasked := maskAPIKey(config.APIKey)
+			apiKeyMasked := maskAPIKey(apiKey)
 
 			// Prepare options info
 			var optionsInfo string
@@ -96,12 +116,19 @@ var modelListCmd = &cobra.Command{
 				}
 			}
 
+			// Groups and tags the model can be selected with
+			groups := modelManager.GroupsOf(name)
+			for i, group := range groups {
+				groups[i] = models.GroupPrefix + group
+			}
+
 			t.AppendRow(table.Row{
 				defaultMark,
 				shortName,
-				config.URL,
+				url,
 				apiKeyMasked,
 				optionsInfo,
+				strings.Join(groups, " "),
 			})
 		}
 
@@ -139,14 +166,25 @@ func truncateString(maxLen int) func(val interface{}) string {
 
 // addCmd adds a new model
 var addCmd = &cobra.Command{
-	Use:   "add <name> <url> <apikey>",
+	Use:   "add <name> [<url> <apikey>]",
 	Short: "Add a new AI model",
-	Long:  `Add a new AI model configuration, providing the name, API URL, and API key.`,
-	Args:  cobra.ExactArgs(3),
+	Long: `Add a new AI model configuration, providing the name, API URL, and API key.
+With --profile, the URL and API key come from a shared connection profile and can be omitted.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if profile, _ := cmd.Flags().GetString("profile"); profile != "" {
+			return cobra.RangeArgs(1, 3)(cmd, args)
+		}
+		return cobra.ExactArgs(3)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		name := args[0]
-		url := args[1]
-		apiKey := args[2]
+		var url, apiKey string
+		if len(args) > 1 {
+			url = args[1]
+		}
+		if len(args) > 2 {
+			apiKey = args[2]
+		}
 
 		// Get flags
 		defaultEnabled, _ := cmd.Flags().GetBool("default")
@@ -165,7 +203,44 @@ var addCmd = &cobra.Command{
 			}
 		}
 
-		err := modelManager.AddModel(name, url, apiKey, defaultEnabled, chatOptions)
+		provider, _ := cmd.Flags().GetString("provider")
+		if err := models.CheckProvider(provider); err != nil {
+			fmt.Printf("Failed to add model: %v\n", err)
+			return
+		}
+		profile, _ := cmd.Flags().GetString("profile")
+
+		config := &models.ModelConfig{
+			Name:               name,
+			Provider:           provider,
+			Profile:            profile,
+			URL:                url,
+			APIKey:             apiKey,
+			DefaultEnabled:     defaultEnabled,
+			DefaultChatOptions: chatOptions,
+		}
+		applyPricingFlags(cmd, config)
+		applyTagsFlag(cmd, config)
+		applyVisionFlag(cmd, config)
+
+		// Check that the model answers before saving it
+		if verify, _ := cmd.Flags().GetBool("verify"); verify {
+			model, err := modelManager.CreateModel(config)
+			if err != nil {
+				fmt.Printf("Failed to add model: %v\n", err)
+				return
+			}
+
+			fmt.Printf("Verifying model '%s'...\n", name)
+			result := models.Probe(context.Background(), model)
+			if result.Err != nil {
+				fmt.Printf("Verification failed, model not added: %v\n", result.Err)
+				return
+			}
+			fmt.Printf("Model answered in %s (first token after %s)\n", formatDuration(result.Latency), formatDuration(result.TimeToFirstToken))
+		}
+
+		err := modelManager.AddModelConfig(config)
 		if err != nil {
 			fmt.Printf("Failed to add model: %v\n", err)
 			return
@@ -255,6 +330,13 @@ var optionsCmd = &cobra.Command{
 			config.DefaultEnabled = defaultEnabled
 		}
 
+		applyPricingFlags(cmd, config)
+		applyTagsFlag(cmd, config)
+		applyVisionFlag(cmd, config)
+		if cmd.Flags().Changed("fallbacks") {
+			config.Fallbacks, _ = cmd.Flags().GetStringSlice("fallbacks")
+		}
+
 		// Update the model config
 		err = modelManager.UpdateModelConfig(name, config)
 		if err != nil {
@@ -268,9 +350,255 @@ var optionsCmd = &cobra.Command{
 			config.DefaultChatOptions.MaxTokens,
 			config.DefaultChatOptions.Stream,
 			config.DefaultEnabled)
+		if config.Pricing != nil {
+			fmt.Printf("Pricing: $%g input, $%g output per 1M tokens\n", config.Pricing.Input, config.Pricing.Output)
+		}
+		if len(config.Tags) > 0 {
+			fmt.Printf("Tags: %s\n", strings.Join(config.Tags, ", "))
+		}
+		if len(config.Fallbacks) > 0 {
+			fmt.Printf("Fallbacks: %s\n", strings.Join(config.Fallbacks, ", "))
+		}
+		fmt.Printf("Images: %v\n", config.SupportsImages())
 	},
 }
 
+// applyTagsFlag sets the model tags from --tags
+func applyTagsFlag(cmd *cobra.Command, config *models.ModelConfig) {
+	if !cmd.Flags().Changed("tags") {
+		return
+	}
+
+	tags, _ := cmd.Flags().GetStringSlice("tags")
+	config.Tags = nil
+	for _, tag := range tags {
+		if tag = strings.TrimPrefix(strings.TrimSpace(tag), models.GroupPrefix); tag != "" {
+			config.Tags = append(config.Tags, tag)
+		}
+	}
+}
+
+// applyVisionFlag sets whether the model reads images from --vision
+func applyVisionFlag(cmd *cobra.Command, config *models.ModelConfig) {
+	if !cmd.Flags().Changed("vision") {
+		return
+	}
+
+	vision, _ := cmd.Flags().GetBool("vision")
+	config.Vision = &vision
+}
+
+// applyPricingFlags sets the model pricing from --input-price and --output-price
+func applyPricingFlags(cmd *cobra.Command, config *models.ModelConfig) {
+	if !cmd.Flags().Changed("input-price") && !cmd.Flags().Changed("output-price") {
+		return
+	}
+
+	if config.Pricing == nil {
+		config.Pricing = &models.Pricing{}
+	}
+	if cmd.Flags().Changed("input-price") {
+		config.Pricing.Input, _ = cmd.Flags().GetFloat64("input-price")
+	}
+	if cmd.Flags().Changed("output-price") {
+		config.Pricing.Output, _ = cmd.Flags().GetFloat64("output-price")
+	}
+}
+
+// discoverCmd discovers models served by a provider
+var discoverCmd = &cobra.Command{
+	Use:   "discover [url]",
+	Short: "Discover and add models served by a provider",
+	Long: `Query the provider's model-listing endpoint, show the available models and add the selected ones.
+All selected models share one connection profile, named after the URL host unless --profile is given.
+With an existing --profile, the URL and API key can be omitted.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Get flags
+		apiKey, _ := cmd.Flags().GetString("key")
+		provider, _ := cmd.Flags().GetString("provider")
+		profileName, _ := cmd.Flags().GetString("profile")
+		selectFlag, _ := cmd.Flags().GetString("select")
+		all, _ := cmd.Flags().GetBool("all")
+		if err := models.CheckProvider(provider); err != nil {
+			fmt.Printf("Failed to discover models: %v\n", err)
+			return
+		}
+
+		var url string
+		if len(args) > 0 {
+			url = args[0]
+		}
+
+		if profileName == "" {
+			if url == "" {
+				fmt.Println("Please specify a URL or an existing profile with --profile")
+				return
+			}
+			profileName = profileNameFromURL(url)
+		}
+
+		// Reuse an existing profile, or prepare a new one
+		profile, err := modelManager.GetProvider(profileName)
+		profileExists := err == nil
+		if profileExists {
+			if url != "" && strings.TrimSuffix(url, "/") != strings.TrimSuffix(profile.URL, "/") {
+				fmt.Printf("Profile '%s' already exists with URL %s, use --profile to choose another name\n", profileName, profile.URL)
+				return
+			}
+			if apiKey != "" && apiKey != profile.ResolveAPIKey() {
+				fmt.Printf("Profile '%s' already exists with a different API key, use 'ai provider update' to rotate it\n", profileName)
+				return
+			}
+		} else {
+			if url == "" {
+				fmt.Printf("Profile '%s' not found, please specify a URL\n", profileName)
+				return
+			}
+			profile = &models.ProviderConfig{Type: provider, URL: url, APIKey: apiKey}
+		}
+		if provider == "" {
+			provider = profile.Type
+		}
+
+		remoteModels, err := models.DiscoverModels(context.Background(), provider, profile.URL, profile.ResolveAPIKey())
+		if err != nil {
+			fmt.Printf("Failed to discover models: %v\n", err)
+			return
+		}
+
+		if len(remoteModels) == 0 {
+			fmt.Println("The provider did not report any models.")
+			return
+		}
+
+		configured := modelManager.ListModels()
+
+		// Create table
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.SetStyle(table.StyleLight)
+		t.SetColumnConfigs([]table.ColumnConfig{
+			{Number: 1, Align: text.AlignRight},
+			{Number: 4, Align: text.AlignRight},
+			{Number: 5, Align: text.AlignCenter},
+		})
+		t.AppendHeader(table.Row{"No.", "ID", "Owned By", "Context", "Added"})
+
+		for i, m := range remoteModels {
+			contextLength := "-"
+			if m.ContextLength > 0 {
+				contextLength = strconv.Itoa(m.ContextLength)
+			}
+
+			addedMark := " "
+			if _, exists := configured[m.ID]; exists {
+				addedMark = "✓"
+			}
+
+			t.AppendRow(table.Row{i + 1, m.ID, m.OwnedBy, contextLength, addedMark})
+		}
+		t.Render()
+
+		// Determine selection
+		selection := selectFlag
+		if all {
+			selection = "all"
+		}
+		if selection == "" {
+			fmt.Print("Select models to add (numbers or IDs separated by commas, 'all', empty to cancel): ")
+			line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			selection = strings.TrimSpace(line)
+		}
+		if selection == "" {
+			fmt.Println("No models selected.")
+			return
+		}
+
+		selected, err := parseModelSelection(selection, remoteModels)
+		if err != nil {
+			fmt.Printf("Invalid selection: %v\n", err)
+			return
+		}
+
+		if !profileExists {
+			if err := modelManager.AddProvider(profileName, profile); err != nil {
+				fmt.Printf("Failed to add provider: %v\n", err)
+				return
+			}
+			fmt.Printf("Provider '%s' added successfully\n", profileName)
+		}
+
+		for _, id := range selected {
+			if _, exists := configured[id]; exists {
+				fmt.Printf("Model '%s' already exists, skipped\n", id)
+				continue
+			}
+
+			err := modelManager.AddModelConfig(&models.ModelConfig{
+				Name:    id,
+				Profile: profileName,
+			})
+			if err != nil {
+				fmt.Printf("Failed to add model '%s': %v\n", id, err)
+				continue
+			}
+
+			fmt.Printf("Model '%s' added successfully\n", id)
+		}
+	},
+}
+
+// profileNameFromURL derives a connection profile name from the URL host
+func profileNameFromURL(rawURL string) string {
+	if u, err := neturl.Parse(rawURL); err == nil && u.Hostname() != "" {
+		return u.Hostname()
+	}
+	return rawURL
+}
+
+// parseModelSelection resolves a comma-separated list of numbers or IDs against the discovered models
+func parseModelSelection(selection string, remoteModels []models.RemoteModel) ([]string, error) {
+	if strings.EqualFold(selection, "all") {
+		ids := make([]string, len(remoteModels))
+		for i, m := range remoteModels {
+			ids[i] = m.ID
+		}
+		return ids, nil
+	}
+
+	var ids []string
+	for _, item := range strings.Split(selection, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		// Check if it's a number shown in the list
+		if index, err := strconv.Atoi(item); err == nil {
+			if index < 1 || index > len(remoteModels) {
+				return nil, fmt.Errorf("number out of range: %d", index)
+			}
+			ids = append(ids, remoteModels[index-1].ID)
+			continue
+		}
+
+		found := false
+		for _, m := range remoteModels {
+			if m.ID == item {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown model: %s", item)
+		}
+		ids = append(ids, item)
+	}
+
+	return ids, nil
+}
+
 // Register commands in init
 func init() {
 	rootCmd.AddCommand(modelCmd)
@@ -279,18 +607,36 @@ func init() {
 	modelCmd.AddCommand(removeCmd)
 	modelCmd.AddCommand(setCmd)
 	modelCmd.AddCommand(optionsCmd)
+	modelCmd.AddCommand(discoverCmd)
 
 	// Add flags for add command
 	addCmd.Flags().Bool("default", false, "Set this model as the default")
 	addCmd.Flags().Float64("temperature", 0.2, "Set default temperature (0.0-1.0)")
 	addCmd.Flags().Int("max-tokens", 2048, "Set default maximum tokens")
 	addCmd.Flags().Bool("stream", true, "Enable streaming output by default")
+	addCmd.Flags().String("provider", "", "API provider (openai, anthropic, gemini); detected from name and URL if empty")
+	addCmd.Flags().String("profile", "", "Use the URL and API key of a shared connection profile")
+	addCmd.Flags().Bool("verify", false, "Send a test prompt and only add the model if it answers")
+
+	// Add flags for discover command
+	discoverCmd.Flags().String("key", "", "API key used to list and call the models")
+	discoverCmd.Flags().String("provider", "", "API provider (openai, gemini); detected from URL if empty")
+	discoverCmd.Flags().String("profile", "", "Connection profile to create or reuse (defaults to the URL host)")
+	discoverCmd.Flags().String("select", "", "Models to add without prompting (numbers or IDs separated by commas)")
+	discoverCmd.Flags().Bool("all", false, "Add all discovered models without prompting")
 
 	// Add flags for options command
 	optionsCmd.Flags().Float64("temperature", 0.2, "Set default temperature (0.0-1.0)")
 	optionsCmd.Flags().Int("max-tokens", 2048, "Set default maximum tokens")
 	optionsCmd.Flags().Bool("stream", true, "Enable streaming output by default")
 	optionsCmd.Flags().Bool("default", false, "Set this model as the default")
+	optionsCmd.Flags().StringSlice("fallbacks", nil, "Models or @groups tried in turn when this model fails (empty to clear)")
+	for _, cmd := range []*cobra.Command{addCmd, optionsCmd} {
+		cmd.Flags().Float64("input-price", 0, "Price in USD per 1M input tokens, used by 'ai usage'")
+		cmd.Flags().Float64("output-price", 0, "Price in USD per 1M output tokens, used by 'ai usage'")
+		cmd.Flags().StringSlice("tags", nil, "Tags selecting the model with @tag, e.g. local,cheap (empty to clear)")
+		cmd.Flags().Bool("vision", false, "Whether the model reads images; guessed from the model name when not set")
+	}
 }
 
 // maskAPIKey masks the API key
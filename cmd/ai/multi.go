@@ -1,21 +1,50 @@
 package ai
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 
+	"github.com/pokitpeng/ai/pkg/history"
+	"github.com/pokitpeng/ai/pkg/models"
+	"github.com/pokitpeng/ai/pkg/util"
 	"github.com/spf13/cobra"
 )
 
 // multiCmd represents the multi subcommand
 var multiCmd = &cobra.Command{
-	Use:   "multi <model1,model2,...> <question>",
+	Use:   "multi <model1,model2,...|@group> <question>",
 	Short: "Ask multiple models simultaneously",
-	Long:  `Ask the same question to multiple models simultaneously and compare their answers.`,
-	Args:  cobra.ExactArgs(2),
+	Long: `Ask the same question to multiple models simultaneously and compare their answers.
+
+Models can be listed by name or selected by group or tag with @name, see 'ai group'.
+In a terminal, each answer streams into its own pane with a status line showing
+the latency and token counts; the full answers are printed in order at the end.
+With --sequential, the models are asked one after another and their answers stream in order.
+
+Examples:
+  ai multi gpt-4o,gemini-2.0-flash "What is functional programming?"
+  ai multi gpt-4o,gemini-2.0-flash --sequential "Explain closures"
+  ai multi @fast "Explain closures"
+  ai multi gpt-4o,gemini-2.0-flash --format json "Explain closures" > answers.json
+
+With --judge, a judge model grades the answers with a rubric and ranks them; with
+--synthesize, the answers are merged into one reply, by the judge model or the default
+model. The answers, ranking and merged reply are saved to the current session so
+follow-up questions can refer to them.
+
+  ai multi gpt-4o,gemini-2.0-flash,claude-3-5-sonnet --judge gpt-4o "Explain closures"
+  ai multi gpt-4o,gemini-2.0-flash --synthesize "Explain closures"`,
+	Args: cobra.ExactArgs(2),
 	Run: func(cmd *cobra.Command, args []string) {
-		// Parse model list
-		modelList := strings.Split(args[0], ",")
+		// Parse model list, expanding groups and tags
+		modelList, err := modelManager.ResolveModelNames(strings.Split(args[0], ","))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
 		if len(modelList) < 1 {
 			fmt.Println("Please specify at least one model")
 			return
@@ -25,10 +54,339 @@ var multiCmd = &cobra.Command{
 		question := args[1]
 
 		// Execute multi-model questioning
-		askMultiModels(modelList, question)
+		askMultiModels(cmd, modelList, question)
 	},
 }
 
+// multiResult is the answer of one model to a multi-model question
+type multiResult struct {
+	Model  string
+	Answer string
+	Info   *models.ResponseInfo
+	Err    error
+}
+
+// multiAnswerOutput is the JSON output of one model's answer
+type multiAnswerOutput struct {
+	Model            string `json:"model"`
+	Answer           string `json:"answer,omitempty"`
+	Error            string `json:"error,omitempty"`
+	LatencyMS        int64  `json:"latency_ms,omitempty"`
+	FirstTokenMS     int64  `json:"first_token_ms,omitempty"`
+	PromptTokens     int    `json:"prompt_tokens,omitempty"`
+	CompletionTokens int    `json:"completion_tokens,omitempty"`
+	Cached           bool   `json:"cached,omitempty"`
+}
+
+// multiOutput is the JSON output of a multi-model question
+type multiOutput struct {
+	Question  string              `json:"question"`
+	Answers   []multiAnswerOutput `json:"answers"`
+	Judgement *models.Judgement   `json:"judgement,omitempty"`
+	Synthesis string              `json:"synthesis,omitempty"`
+}
+
+// askMultiModels asks multiple models the same question, in parallel unless --sequential is set
+func askMultiModels(cmd *cobra.Command, modelNames []string, question string) {
+	format, _ := cmd.Flags().GetString("format")
+	if format == "" {
+		format = getOutputFormat(cmd)
+	}
+	if format != models.OutputText && format != models.OutputJSON {
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q, expected %s or %s\n", format, models.OutputText, models.OutputJSON)
+		os.Exit(1)
+	}
+	sequential, _ := cmd.Flags().GetBool("sequential")
+
+	// Get project settings
+	chatOptions, err := projectChatOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	chatOptions = append(chatOptions, models.WithCacheMode(getCacheMode(cmd)))
+	checkBudget()
+
+	// Models that can't be found are reported with the answers
+	results := make([]multiResult, len(modelNames))
+	resolved := make([]models.Model, len(modelNames))
+	var targets []models.Model
+	var indexes []int
+	for i, name := range modelNames {
+		results[i].Model = name
+		model, err := modelManager.GetModel(name)
+		if err != nil {
+			results[i].Err = fmt.Errorf("%w: %s", err, name)
+			continue
+		}
+		resolved[i] = model
+		targets = append(targets, model)
+		indexes = append(indexes, i)
+	}
+
+	ctx := context.Background()
+	finish := func(i int, event models.StreamEvent) {
+		results[i].Answer, results[i].Info, results[i].Err = event.Answer, event.Info, event.Err
+		if event.Err == nil {
+			recordUsage("multi", event.Info)
+		}
+	}
+
+	switch {
+	case sequential:
+		if format == models.OutputText {
+			fmt.Printf("Question: %s\n\n", question)
+		}
+		for i, model := range resolved {
+			if model == nil {
+				if format == models.OutputText {
+					printMultiResult(results[i])
+				}
+				continue
+			}
+
+			if format == models.OutputText {
+				fmt.Printf("===== Model: %s =====\n", results[i].Model)
+			}
+			streamed := false
+			for event := range models.ChatStream(ctx, model, question, chatOptions...) {
+				if event.Done {
+					finish(i, event)
+				} else if format == models.OutputText {
+					fmt.Print(event.Chunk)
+					streamed = true
+				}
+			}
+			if format == models.OutputJSON {
+				continue
+			}
+
+			switch {
+			case results[i].Err != nil:
+				if streamed {
+					fmt.Println()
+				}
+				fmt.Printf("Error: %v\n", results[i].Err)
+			case streamed:
+				fmt.Println()
+			default:
+				fmt.Println(results[i].Answer)
+			}
+			if results[i].Err == nil {
+				fmt.Println(multiStatus(results[i]))
+			}
+			fmt.Println()
+		}
+
+	default:
+		events := models.StreamAll(ctx, targets, question, chatOptions...)
+		if format == models.OutputText {
+			if width, height, ok := util.TerminalSize(os.Stdout); ok {
+				if view := newMultiView(results, width, height); view != nil {
+					view.run(events, indexes, finish)
+				}
+			}
+		}
+		// Collect what the live view didn't consume, e.g. when output is not a terminal
+		for event := range events {
+			if event.Done {
+				finish(indexes[event.Index], event)
+			}
+		}
+
+		if format == models.OutputText {
+			fmt.Printf("Question: %s\n\n", question)
+			for _, result := range results {
+				printMultiResult(result)
+			}
+		}
+	}
+
+	judgement, synthesis := reviewMultiAnswers(cmd, question, results, format, chatOptions)
+
+	if format == models.OutputJSON {
+		printMultiJSON(question, results, judgement, synthesis)
+	}
+}
+
+// reviewMultiAnswers judges and merges the answers as requested with --judge and
+// --synthesize, printing the results in text format and saving them to the current session
+func reviewMultiAnswers(cmd *cobra.Command, question string, results []multiResult, format string, chatOptions []models.ChatOption) (*models.Judgement, string) {
+	judgeName, _ := cmd.Flags().GetString("judge")
+	synthesize, _ := cmd.Flags().GetBool("synthesize")
+	if judgeName == "" && !synthesize {
+		return nil, ""
+	}
+
+	var candidates []models.Candidate
+	for _, result := range results {
+		if result.Err == nil && strings.TrimSpace(result.Answer) != "" {
+			candidates = append(candidates, models.Candidate{Model: result.Model, Answer: result.Answer})
+		}
+	}
+	if len(candidates) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no answers to judge or synthesize")
+		return nil, ""
+	}
+
+	// The judge also writes the synthesis; without one, the default model does
+	var reviewer models.Model
+	var err error
+	if judgeName != "" {
+		reviewer, err = modelManager.GetModelWithFallbacks(judgeName)
+		if err != nil {
+			err = fmt.Errorf("%w: %s", err, judgeName)
+		}
+	} else {
+		reviewer, err = getModel(cmd)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return nil, ""
+	}
+
+	ctx := context.Background()
+	var judgement *models.Judgement
+	var synthesis string
+	var generation *history.Generation
+
+	if judgeName != "" {
+		info := &models.ResponseInfo{}
+		judgement, err = models.Judge(ctx, reviewer, question, candidates, append(chatOptions, models.WithResponseInfo(info))...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Judging failed: %v\n", err)
+		} else {
+			recordUsage("multi", info)
+			generation = newGeneration(info)
+			if format == models.OutputText {
+				fmt.Printf("===== Judge: %s =====\n%s\n\n", judgement.Judge, formatJudgement(judgement))
+			}
+		}
+	}
+
+	if synthesize {
+		info := &models.ResponseInfo{}
+		options := append(chatOptions, models.WithResponseInfo(info), models.WithStream(format == models.OutputText))
+		if format == models.OutputText {
+			fmt.Printf("===== Synthesis: %s =====\n", reviewer.Name())
+		}
+		synthesis, err = models.Synthesize(ctx, reviewer, question, candidates, options...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Synthesis failed: %v\n", err)
+		} else {
+			recordUsage("multi", info)
+			generation = newGeneration(info)
+			if format == models.OutputText {
+				fmt.Println()
+			}
+		}
+	}
+
+	if generation != nil && modelManager.GetDefaults().HistoryEnabled() {
+		historyManager.AddUserMessage(question)
+		historyManager.AddAssistantReply(multiTranscript(candidates, judgement, synthesis), generation)
+	}
+	return judgement, synthesis
+}
+
+// formatJudgement lists the ranking of a judgement followed by its rationale
+func formatJudgement(judgement *models.Judgement) string {
+	var lines []string
+	for i, rank := range judgement.Ranking {
+		line := fmt.Sprintf("%d. %s (%g/10)", i+1, rank.Model, rank.Score)
+		if rank.Reason != "" {
+			line += ": " + rank.Reason
+		}
+		lines = append(lines, line)
+	}
+	if judgement.Rationale != "" {
+		lines = append(lines, "", judgement.Rationale)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// multiTranscript combines the answers, judgement and synthesis into one reply for the history
+func multiTranscript(candidates []models.Candidate, judgement *models.Judgement, synthesis string) string {
+	var transcript strings.Builder
+	for _, candidate := range candidates {
+		fmt.Fprintf(&transcript, "## Answer of %s\n\n%s\n\n", candidate.Model, strings.TrimSpace(candidate.Answer))
+	}
+	if judgement != nil {
+		fmt.Fprintf(&transcript, "## Ranking by %s\n\n%s\n\n", judgement.Judge, formatJudgement(judgement))
+	}
+	if synthesis != "" {
+		fmt.Fprintf(&transcript, "## Synthesis\n\n%s\n", strings.TrimSpace(synthesis))
+	}
+	return strings.TrimSpace(transcript.String())
+}
+
+// printMultiResult prints one model's answer with its status line
+func printMultiResult(result multiResult) {
+	fmt.Printf("===== Model: %s =====\n", result.Model)
+	if result.Err != nil {
+		fmt.Printf("Error: %v\n", result.Err)
+	} else {
+		fmt.Println(result.Answer)
+		fmt.Println(multiStatus(result))
+	}
+	fmt.Println()
+}
+
+// multiStatus describes the latency and token counts of an answer
+func multiStatus(result multiResult) string {
+	if result.Err != nil {
+		return "✗ " + singleLine(result.Err.Error())
+	}
+
+	info := result.Info
+	if info == nil {
+		return "✓"
+	}
+
+	details := []string{"✓ " + formatDuration(info.Latency)}
+	if info.TimeToFirstToken > 0 {
+		details = append(details, "first token "+formatDuration(info.TimeToFirstToken))
+	}
+	if info.Usage.TotalTokens > 0 {
+		details = append(details, fmt.Sprintf("%d tokens in / %d out", info.Usage.PromptTokens, info.Usage.CompletionTokens))
+	}
+	if info.Cached {
+		details = append(details, "cached")
+	}
+	return strings.Join(details, " · ")
+}
+
+// printMultiJSON prints the answers of all models, with the judgement and synthesis, as indented JSON
+func printMultiJSON(question string, results []multiResult, judgement *models.Judgement, synthesis string) {
+	output := multiOutput{Question: question, Answers: make([]multiAnswerOutput, len(results)), Judgement: judgement, Synthesis: synthesis}
+	for i, result := range results {
+		answer := multiAnswerOutput{Model: result.Model, Answer: result.Answer}
+		if result.Err != nil {
+			answer.Error = result.Err.Error()
+		}
+		if info := result.Info; info != nil {
+			answer.LatencyMS = info.Latency.Milliseconds()
+			answer.FirstTokenMS = info.TimeToFirstToken.Milliseconds()
+			answer.PromptTokens = info.Usage.PromptTokens
+			answer.CompletionTokens = info.Usage.CompletionTokens
+			answer.Cached = info.Cached
+		}
+		output.Answers[i] = answer
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode output: %v\n", err)
+		return
+	}
+	fmt.Println(string(data))
+}
+
 func init() {
 	rootCmd.AddCommand(multiCmd)
+
+	multiCmd.Flags().Bool("sequential", false, "Ask the models one after another, streaming the answers in order")
+	multiCmd.Flags().String("format", "", "Output format (text, json); defaults to --output")
+	multiCmd.Flags().String("judge", "", "Model that grades and ranks the answers")
+	multiCmd.Flags().Bool("synthesize", false, "Merge the answers into one reply, with the judge or the default model")
 }
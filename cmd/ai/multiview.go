@@ -0,0 +1,134 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/pokitpeng/ai/pkg/models"
+)
+
+// multiRefreshInterval is how often the live view redraws elapsed times
+const multiRefreshInterval = 100 * time.Millisecond
+
+// multiPane is the live state of one model's answer
+type multiPane struct {
+	result *multiResult
+	answer strings.Builder
+	done   bool
+}
+
+// multiView shows the answers of several models streaming into stacked panes,
+// each ending with a status line
+type multiView struct {
+	panes []*multiPane
+	width int
+	// lines is the number of answer lines shown in each pane
+	lines int
+	start time.Time
+	// drawn is the number of lines of the last frame, which the next one overwrites
+	drawn int
+}
+
+// newMultiView creates a view for the results in a terminal of the given size,
+// or returns nil when the panes don't fit
+func newMultiView(results []multiResult, width, height int) *multiView {
+	if len(results) == 0 || width < 20 {
+		return nil
+	}
+	// Each pane needs a header and a status line, the last terminal line stays free
+	lines := (height-1)/len(results) - 2
+	if lines < 0 {
+		return nil
+	}
+
+	view := &multiView{width: width - 1, lines: lines, start: time.Now()}
+	for i := range results {
+		view.panes = append(view.panes, &multiPane{result: &results[i], done: results[i].Err != nil})
+	}
+	return view
+}
+
+// run draws the panes until every model has answered, calling finish with the
+// final event of each model. indexes maps the event indexes to the results.
+func (v *multiView) run(events <-chan models.StreamEvent, indexes []int, finish func(int, models.StreamEvent)) {
+	ticker := time.NewTicker(multiRefreshInterval)
+	defer ticker.Stop()
+
+	v.draw()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				v.clear()
+				return
+			}
+			pane := v.panes[indexes[event.Index]]
+			if event.Done {
+				finish(indexes[event.Index], event)
+				pane.done = true
+			} else {
+				pane.answer.WriteString(event.Chunk)
+			}
+		case <-ticker.C:
+			v.draw()
+		}
+	}
+}
+
+// draw replaces the last frame with the current state of the panes
+func (v *multiView) draw() {
+	var frame strings.Builder
+	v.moveToTop(&frame)
+
+	count := 0
+	for _, pane := range v.panes {
+		header := text.Trim("── "+pane.result.Model+" ", v.width)
+		frame.WriteString(header + strings.Repeat("─", v.width-text.RuneWidthWithoutEscSequences(header)) + "\n")
+
+		var wrapped []string
+		if v.lines > 0 {
+			wrapped = strings.Split(text.WrapHard(pane.answer.String(), v.width), "\n")
+			if len(wrapped) > v.lines {
+				wrapped = wrapped[len(wrapped)-v.lines:]
+			}
+		}
+		for i := 0; i < v.lines; i++ {
+			if i < len(wrapped) {
+				frame.WriteString(wrapped[i])
+			}
+			frame.WriteString("\n")
+		}
+
+		frame.WriteString(text.Trim(v.status(pane), v.width) + "\n")
+		count += v.lines + 2
+	}
+
+	fmt.Print(frame.String())
+	v.drawn = count
+}
+
+// status describes the progress of a pane, or its result once done
+func (v *multiView) status(pane *multiPane) string {
+	if pane.done {
+		return multiStatus(*pane.result)
+	}
+	return fmt.Sprintf("… %s · %d chars", time.Since(v.start).Round(100*time.Millisecond), pane.answer.Len())
+}
+
+// clear removes the last frame so the full answers can be printed in its place
+func (v *multiView) clear() {
+	var frame strings.Builder
+	v.moveToTop(&frame)
+	fmt.Print(frame.String())
+	v.drawn = 0
+}
+
+// moveToTop moves the cursor to the first line of the last frame and erases it
+func (v *multiView) moveToTop(frame *strings.Builder) {
+	if v.drawn > 0 {
+		fmt.Fprintf(frame, "\033[%dA", v.drawn)
+	}
+	frame.WriteString("\r\033[J")
+}
@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/pokitpeng/ai/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// modelTestCmd checks that models answer
+var modelTestCmd = &cobra.Command{
+	Use:   "test [name...]",
+	Short: "Send a test prompt to models",
+	Long: `Send a tiny prompt to one or more models in parallel and report whether they answer,
+the time to the first token, the generation speed and any error.
+Tests the default model when no name is given.
+
+Examples:
+  ai model test
+  ai model test gpt-4o gemini-2.0-flash
+  ai model test --all`,
+	Run: func(cmd *cobra.Command, args []string) {
+		all, _ := cmd.Flags().GetBool("all")
+
+		names := args
+		if all {
+			names = names[:0]
+			for name := range modelManager.ListModels() {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		} else if len(names) == 0 {
+			names = []string{modelManager.GetDefaultModelName()}
+		}
+		if len(names) == 0 || names[0] == "" {
+			fmt.Println("No models configured. Use 'ai model add' to add a model.")
+			return
+		}
+
+		results := probeModels(names)
+		printProbeTable(results)
+
+		for _, result := range results {
+			if result.Err != nil {
+				os.Exit(1)
+			}
+		}
+	},
+}
+
+// probeModels tests models in parallel, returning the results in the order of names
+func probeModels(names []string) []models.ProbeResult {
+	results := make([]models.ProbeResult, len(names))
+
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+
+			model, err := modelManager.GetModel(name)
+			if err != nil {
+				results[i] = models.ProbeResult{Model: name, Err: err}
+				return
+			}
+			results[i] = models.Probe(context.Background(), model)
+		}(i, name)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// printProbeTable renders the results of model tests
+func printProbeTable(results []models.ProbeResult) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetStyle(table.StyleLight)
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 2, Align: text.AlignCenter},
+		{Number: 3, Align: text.AlignRight},
+		{Number: 4, Align: text.AlignRight},
+		{Number: 5, Align: text.AlignRight},
+		{Number: 6, WidthMax: 50, Transformer: truncateString(50)},
+	})
+	t.AppendHeader(table.Row{"Model", "Status", "First Token", "Total", "Tokens/s", "Answer / Error"})
+
+	for _, result := range results {
+		if result.Err != nil {
+			t.AppendRow(table.Row{result.Model, "✗", "-", "-", "-", singleLine(result.Err.Error())})
+			continue
+		}
+
+		speed := "-"
+		if tps := result.TokensPerSecond(); tps > 0 {
+			speed = fmt.Sprintf("%.1f", tps)
+		}
+		t.AppendRow(table.Row{
+			result.Model,
+			"✓",
+			formatDuration(result.TimeToFirstToken),
+			formatDuration(result.Latency),
+			speed,
+			singleLine(result.Answer),
+		})
+	}
+
+	t.Render()
+}
+
+// formatDuration formats a duration in milliseconds, or "-" when it was not measured
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// singleLine joins the lines of a text so it fits in a table cell
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func init() {
+	modelCmd.AddCommand(modelTestCmd)
+
+	modelTestCmd.Flags().Bool("all", false, "Test all configured models")
+}
@@ -0,0 +1,217 @@
+package ai
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/pokitpeng/ai/pkg/models"
+	"github.com/spf13/cobra"
+)
+
+// providerCmd represents the provider subcommand
+var providerCmd = &cobra.Command{
+	Use:   "provider",
+	Short: "Manage shared connection profiles used by models",
+	Long: `Manage connection profiles (URL, API key, headers and transport settings) that several models can share.
+Models reference a profile with 'ai model add <name> --profile <profile>'.`,
+}
+
+// providerListCmd lists connection profiles
+var providerListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List connection profiles",
+	Long:  `List all configured connection profiles and the models that use them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		providers := modelManager.ListProviders()
+
+		if len(providers) == 0 {
+			fmt.Println("No providers configured. Use 'ai provider add' to add a provider.")
+			return
+		}
+
+		names := make([]string, 0, len(providers))
+		for name := range providers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		// Create table
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.SetStyle(table.StyleLight)
+		t.Style().Options.SeparateRows = true
+
+		t.SetColumnConfigs([]table.ColumnConfig{
+			{Number: 3, WidthMax: 30, Transformer: truncateString(30)},
+			{Number: 5, WidthMax: 40, Transformer: truncateString(40)},
+		})
+
+		t.AppendHeader(table.Row{"Name", "Type", "URL", "API Key", "Models"})
+
+		for _, name := range names {
+			provider := providers[name]
+
+			apiKey := maskAPIKey(provider.APIKey)
+			if provider.APIKeyEnv != "" {
+				apiKey = "$" + provider.APIKeyEnv
+			}
+
+			t.AppendRow(table.Row{
+				name,
+				provider.Type,
+				provider.URL,
+				apiKey,
+				strings.Join(modelManager.ModelsUsingProvider(name), ", "),
+			})
+		}
+
+		t.Render()
+	},
+}
+
+// providerAddCmd adds a connection profile
+var providerAddCmd = &cobra.Command{
+	Use:   "add <name> <url>",
+	Short: "Add a connection profile",
+	Long:  `Add a connection profile with a URL, an API key or environment variable holding the key, headers and transport settings.`,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		provider := &models.ProviderConfig{URL: args[1]}
+		if err := applyProviderFlags(cmd, provider); err != nil {
+			fmt.Printf("Failed to add provider: %v\n", err)
+			return
+		}
+
+		if err := modelManager.AddProvider(name, provider); err != nil {
+			fmt.Printf("Failed to add provider: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Provider '%s' added successfully\n", name)
+	},
+}
+
+// providerUpdateCmd updates a connection profile
+var providerUpdateCmd = &cobra.Command{
+	Use:   "update <name>",
+	Short: "Update a connection profile",
+	Long: `Update a connection profile, for example to rotate its API key.
+Every model that uses the profile picks up the change.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		provider, err := modelManager.GetProvider(name)
+		if err != nil {
+			fmt.Printf("Failed to get provider: %v\n", err)
+			return
+		}
+
+		if cmd.Flags().Changed("url") {
+			provider.URL, _ = cmd.Flags().GetString("url")
+		}
+		if err := applyProviderFlags(cmd, provider); err != nil {
+			fmt.Printf("Failed to update provider: %v\n", err)
+			return
+		}
+
+		if err := modelManager.UpdateProvider(name, provider); err != nil {
+			fmt.Printf("Failed to update provider: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Provider '%s' updated successfully\n", name)
+		if names := modelManager.ModelsUsingProvider(name); len(names) > 0 {
+			fmt.Printf("Affected models: %s\n", strings.Join(names, ", "))
+		}
+	},
+}
+
+// providerRemoveCmd removes a connection profile
+var providerRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a connection profile",
+	Long:  `Remove a connection profile. Profiles still used by models cannot be removed.`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		if err := modelManager.RemoveProvider(name); err != nil {
+			fmt.Printf("Failed to remove provider: %v\n", err)
+			return
+		}
+
+		fmt.Printf("Provider '%s' removed successfully\n", name)
+	},
+}
+
+// applyProviderFlags updates a profile from the flags that were set
+func applyProviderFlags(cmd *cobra.Command, provider *models.ProviderConfig) error {
+	if cmd.Flags().Changed("type") {
+		provider.Type, _ = cmd.Flags().GetString("type")
+		if err := models.CheckProvider(provider.Type); err != nil {
+			return err
+		}
+	}
+	if cmd.Flags().Changed("key") {
+		provider.APIKey, _ = cmd.Flags().GetString("key")
+	}
+	if cmd.Flags().Changed("key-env") {
+		provider.APIKeyEnv, _ = cmd.Flags().GetString("key-env")
+	}
+	if cmd.Flags().Changed("timeout") {
+		provider.Timeout, _ = cmd.Flags().GetDuration("timeout")
+	}
+	if cmd.Flags().Changed("proxy") {
+		provider.Proxy, _ = cmd.Flags().GetString("proxy")
+	}
+	if cmd.Flags().Changed("insecure") {
+		provider.InsecureSkipVerify, _ = cmd.Flags().GetBool("insecure")
+	}
+
+	if cmd.Flags().Changed("header") {
+		headers, _ := cmd.Flags().GetStringArray("header")
+		if provider.Headers == nil {
+			provider.Headers = make(map[string]string)
+		}
+		for _, header := range headers {
+			key, value, ok := strings.Cut(header, "=")
+			if !ok {
+				return fmt.Errorf("invalid header %q, expected key=value", header)
+			}
+			// An empty value removes the header
+			if value == "" {
+				delete(provider.Headers, key)
+				continue
+			}
+			provider.Headers[key] = value
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(providerCmd)
+	providerCmd.AddCommand(providerListCmd)
+	providerCmd.AddCommand(providerAddCmd)
+	providerCmd.AddCommand(providerUpdateCmd)
+	providerCmd.AddCommand(providerRemoveCmd)
+
+	// Add flags shared by add and update commands
+	for _, c := range []*cobra.Command{providerAddCmd, providerUpdateCmd} {
+		c.Flags().String("type", "", "API type (openai, anthropic, gemini); detected from name and URL if empty")
+		c.Flags().String("key", "", "API key")
+		c.Flags().String("key-env", "", "Environment variable that holds the API key")
+		c.Flags().StringArray("header", nil, "Extra request header as key=value (repeatable, empty value removes)")
+		c.Flags().Duration("timeout", 0, "Request timeout (e.g. 90s)")
+		c.Flags().String("proxy", "", "HTTP proxy URL")
+		c.Flags().Bool("insecure", false, "Skip TLS certificate verification")
+	}
+	providerUpdateCmd.Flags().String("url", "", "API URL")
+}
@@ -1,14 +1,19 @@
 package ai
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sync"
+	"strings"
 
 	"github.com/pokitpeng/ai/pkg/history"
 	"github.com/pokitpeng/ai/pkg/models"
+	"github.com/pokitpeng/ai/pkg/usage"
 	"github.com/pokitpeng/ai/pkg/util"
 	"github.com/spf13/cobra"
 )
@@ -16,6 +21,8 @@ import (
 var (
 	modelManager   *models.ModelManager
 	historyManager *history.Manager
+	usageLedger    *usage.Ledger
+	projectConfig  *models.ProjectConfig
 )
 
 // Root command
@@ -35,36 +42,79 @@ Examples:
 		// Direct question mode
 		question := args[0]
 
-		// Get default model
-		model, err := modelManager.GetDefaultModel()
+		// Get model
+		model, err := getModel(cmd)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			return
 		}
 
+		output, err := getJSONOutput(cmd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+		if output != nil {
+			// Invalid replies are asked again in the same conversation, so keep the model routed
+			model = routeOnce(cmd, model, models.QuestionRequest(question))
+		} else {
+			explainRoute(cmd, model, models.QuestionRequest(question))
+		}
+
 		// Create context
 		ctx := context.Background()
 
+		// Get project settings
+		chatOptions, err := projectChatOptions()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return
+		}
+
 		// Get history if needed
-		var chatOptions []models.ChatOption
 		noHistory, _ := cmd.Flags().GetBool("no-history")
+		historyEnabled := modelManager.GetDefaults().HistoryEnabled()
 
-		if !noHistory && !historyManager.IsEmpty() {
+		if historyEnabled && !noHistory && !historyManager.IsEmpty() {
 			// Convert history to model messages
 			modelMessages := convertToModelMessages(historyManager.GetMessages())
 			chatOptions = append(chatOptions, models.WithHistory(modelMessages))
 		}
 
+		// JSON output is printed as a whole once the answer is complete
+		outputFormat := getOutputFormat(cmd)
+		if outputFormat == models.OutputJSON {
+			chatOptions = append(chatOptions, models.WithStream(false))
+		}
+
 		// Send question with options
-		response, err := model.Chat(ctx, question, chatOptions...)
+		checkBudget()
+		info := &models.ResponseInfo{}
+		chatOptions = append(chatOptions, models.WithResponseInfo(info), models.WithCacheMode(getCacheMode(cmd)))
+		var response string
+		if output != nil {
+			response, err = output.Chat(ctx, model, question, chatOptions...)
+		} else {
+			response, err = model.Chat(ctx, question, chatOptions...)
+		}
+		reportFallbacks(info, err)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 			return
 		}
+		recordUsage("root", info)
 
 		// Add to history
-		historyManager.AddUserMessage(question)
-		historyManager.AddAssistantMessage(response)
+		if historyEnabled {
+			historyManager.AddUserMessage(question)
+			historyManager.AddAssistantReply(response, newGeneration(info))
+		}
+
+		if output != nil {
+			fmt.Println(response)
+		} else if outputFormat == models.OutputJSON {
+			printJSONAnswer(answerOutput{Model: answeredBy(model, info), Question: question, Answer: response})
+		}
 
 		// Print response, remove this line to disable response printing
 		// fmt.Println(response)
@@ -73,23 +123,196 @@ Examples:
 
 // Initialization function
 func init() {
+	// Managers are created once flags are parsed, so --config can take effect
+	cobra.OnInitialize(initManagers)
+
+	// Add flags
+	rootCmd.PersistentFlags().String("config", "", "Config file (default $AI_CONFIG_DIR/config.yaml, $XDG_CONFIG_HOME/ai/config.yaml or ~/.ai/config.yaml)")
+	rootCmd.PersistentFlags().StringP("model", "m", "", "Model to use instead of the project or default model")
+	rootCmd.PersistentFlags().Bool("no-history", false, "Don't use conversation history")
+	rootCmd.PersistentFlags().StringP("output", "o", "", "Output format (text, json); defaults to the 'output' setting")
+	rootCmd.PersistentFlags().Bool("no-cache", false, "Don't read or write the response cache")
+	rootCmd.PersistentFlags().Bool("refresh", false, "Ignore cached answers and cache the new one")
+	rootCmd.PersistentFlags().Bool("debug", false, "Log requests, responses and timings to stderr (or set "+debugEnv+")")
+	rootCmd.PersistentFlags().String("debug-file", "", "Log requests, responses and timings to a file")
+	rootCmd.PersistentFlags().Bool("explain-route", false, "Show which model the router chose and why")
+	addJSONFlags(rootCmd)
+}
+
+// initManagers creates the model and history managers and loads the project configuration
+func initManagers() {
 	// Create and initialize model manager
-	modelManager = models.NewModelManager()
+	configFile, _ := rootCmd.PersistentFlags().GetString("config")
+	if configFile == "" {
+		configFile = filepath.Join(util.ConfigDir(), "config.yaml")
+	}
+	modelManager = models.NewModelManagerWithFile(configFile)
 	if err := modelManager.Init(); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize model manager: %v\n", err)
 	}
+	setupDebug()
 
 	// Create and initialize history manager
-	homeDir, _ := os.UserHomeDir()
-	historyPath := filepath.Join(homeDir, ".ai", "history")
-	var err error
-	historyManager, err = history.NewManager(historyPath)
+	wd, err := os.Getwd()
+	if err != nil {
+		wd = "."
+	}
+	workDir, branch := util.Workspace(wd)
+	historyOpts := []history.Option{history.WithWorkspace(workDir, branch)}
+	if modelManager.GetDefaults().SessionScopeOrDefault() == models.SessionScopeDirectory {
+		historyOpts = append(historyOpts, history.WithSessionPerWorkspace())
+	}
+	historyManager, err = history.NewManager(historyDir(), historyOpts...)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize history manager: %v\n", err)
 	}
 
-	// Add flags
-	rootCmd.PersistentFlags().Bool("no-history", false, "Don't use conversation history")
+	usageLedger = usage.NewLedger(filepath.Join(util.DataDir(), "usage.jsonl"))
+
+	// Load project-local configuration
+	projectConfig, err = models.LoadProjectConfig(wd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load project config: %v\n", err)
+	}
+}
+
+// historyDir returns the directory holding the conversation history
+func historyDir() string {
+	return filepath.Join(util.DataDir(), "history")
+}
+
+// getModel returns the model to use: the --model flag, then the project model, then the router
+// or the default model. The model falls back to other models when it fails, see
+// ModelManager.GetModelWithFallbacks.
+func getModel(cmd *cobra.Command) (models.Model, error) {
+	name, _ := cmd.Flags().GetString("model")
+	if name == "" && projectConfig != nil {
+		name = projectConfig.Model
+	}
+
+	// Without a named model, the router chooses one when rules are configured
+	if name == "" {
+		router, err := modelManager.Router()
+		if err != nil {
+			return nil, err
+		}
+		if router != nil {
+			return router, nil
+		}
+	}
+
+	model, err := modelManager.GetModelWithFallbacks(name)
+	if err != nil && name != "" {
+		return nil, fmt.Errorf("%w: %s", err, name)
+	}
+	return model, err
+}
+
+// projectChatOptions returns the chat options set by the project configuration
+func projectChatOptions() ([]models.ChatOption, error) {
+	if projectConfig == nil {
+		return nil, nil
+	}
+
+	systemPrompt, err := projectConfig.BuildSystemPrompt()
+	if err != nil {
+		return nil, err
+	}
+	if systemPrompt == "" {
+		return nil, nil
+	}
+
+	return []models.ChatOption{models.WithSystemPrompt(systemPrompt)}, nil
+}
+
+// withProjectPrompt appends the instructions of the project configuration to the system prompt of a command
+func withProjectPrompt(systemPrompt string) (string, error) {
+	if projectConfig == nil {
+		return systemPrompt, nil
+	}
+
+	projectPrompt, err := projectConfig.BuildSystemPrompt()
+	if err != nil {
+		return "", err
+	}
+	if projectPrompt == "" {
+		return systemPrompt, nil
+	}
+	return systemPrompt + "\n\n" + projectPrompt, nil
+}
+
+// getCacheMode returns how the response cache is used, --no-cache taking precedence over --refresh
+func getCacheMode(cmd *cobra.Command) models.CacheMode {
+	if noCache, _ := cmd.Flags().GetBool("no-cache"); noCache {
+		return models.CacheBypass
+	}
+	if refresh, _ := cmd.Flags().GetBool("refresh"); refresh {
+		return models.CacheRefresh
+	}
+	return models.CacheDefault
+}
+
+// answerOutput is the JSON output of a single answer
+type answerOutput struct {
+	Model    string `json:"model"`
+	File     string `json:"file,omitempty"`
+	Language string `json:"language,omitempty"`
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// getOutputFormat returns the output format from the flag or the global setting
+func getOutputFormat(cmd *cobra.Command) string {
+	if output, _ := cmd.Flags().GetString("output"); output != "" {
+		return output
+	}
+	return modelManager.GetDefaults().OutputFormat()
+}
+
+// addJSONFlags adds the flags asking for a reply that is only JSON
+func addJSONFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("json", false, "Ask for a JSON object and print only the validated JSON")
+	cmd.Flags().String("schema", "", "JSON schema file the reply must match (implies --json)")
+	cmd.Flags().Int("json-retries", models.DefaultJSONRetries, "How many times to ask again when the reply is not valid JSON")
+}
+
+// getJSONOutput returns the JSON output asked with --json or --schema, or nil without them
+func getJSONOutput(cmd *cobra.Command) (*models.JSONOutput, error) {
+	asked, _ := cmd.Flags().GetBool("json")
+	schemaFile, _ := cmd.Flags().GetString("schema")
+	if !asked && schemaFile == "" {
+		return nil, nil
+	}
+
+	var output *models.JSONOutput
+	if schemaFile == "" {
+		output = models.NewJSONOutput("", nil)
+	} else {
+		data, err := os.ReadFile(schemaFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		schema, err := models.ParseSchema(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", schemaFile, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(schemaFile), filepath.Ext(schemaFile))
+		output = models.NewJSONOutput(strings.TrimSuffix(name, ".schema"), schema)
+	}
+
+	retries, _ := cmd.Flags().GetInt("json-retries")
+	output.SetRetries(retries)
+	return output, nil
+}
+
+// printJSONAnswer prints an answer as indented JSON
+func printJSONAnswer(answer answerOutput) {
+	data, err := json.MarshalIndent(answer, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to encode output: %v\n", err)
+		return
+	}
+	fmt.Println(string(data))
 }
 
 // Execute executes the root command
@@ -101,29 +324,93 @@ func Execute() {
 }
 
 // askWithFile asks a question based on file content
-func askWithFile(filePath, question string) {
-	// Get file content
-	content, language, err := util.GetFileInfo(filePath)
+func askWithFile(cmd *cobra.Command, filePath, question string) {
+	// Images are sent with the question, text files as their content
+	var content, language string
+	var images []models.ContentPart
+	var err error
+	if util.IsImageFile(filePath) {
+		var data []byte
+		data, language, err = util.ReadImageFile(filePath)
+		images = []models.ContentPart{models.ImageDataPart(language, data)}
+	} else {
+		content, language, err = util.GetFileInfo(filePath)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to read file: %v\n", err)
 		return
 	}
 
-	// Get default model
-	model, err := modelManager.GetDefaultModel()
+	// Get model
+	model, err := getModel(cmd)
 	if err != nil {
-		fmt.Println("No default model set. Please add a model first:")
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Println("Please add a model first:")
 		fmt.Println("  ai model add <model> <url> <apikey>")
 		return
 	}
+	output, err := getJSONOutput(cmd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+	if output != nil {
+		// Invalid replies are asked again in the same conversation, so keep the model routed
+		model = routeOnce(cmd, model, models.FileRequest(question, filePath, content))
+	} else {
+		explainRoute(cmd, model, models.FileRequest(question, filePath, content))
+	}
 
 	// Create context
 	ctx := context.Background()
 
+	// Get project settings
+	chatOptions, err := projectChatOptions()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+
+	// JSON output is printed as a whole once the answer is complete
+	outputFormat := getOutputFormat(cmd)
+	if outputFormat == models.OutputJSON {
+		chatOptions = append(chatOptions, models.WithStream(false))
+	}
+
 	// Execute question
-	resp, err := model.ChatWithFile(ctx, question, filePath, content)
+	checkBudget()
+	info := &models.ResponseInfo{}
+	chatOptions = append(chatOptions, models.WithResponseInfo(info), models.WithCacheMode(getCacheMode(cmd)))
+	if len(images) > 0 {
+		chatOptions = append(chatOptions, models.WithImages(images...))
+	}
+	var resp string
+	switch {
+	case output != nil && len(images) > 0:
+		resp, err = output.Chat(ctx, model, question, chatOptions...)
+	case output != nil:
+		resp, err = output.ChatWithFile(ctx, model, question, filePath, content, chatOptions...)
+	case len(images) > 0:
+		resp, err = model.Chat(ctx, question, chatOptions...)
+	default:
+		resp, err = model.ChatWithFile(ctx, question, filePath, content, chatOptions...)
+	}
+	reportFallbacks(info, err)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Question failed: %v\n", err)
+		if errors.Is(err, models.ErrImagesNotSupported) {
+			fmt.Fprintln(os.Stderr, "Use another model, or 'ai model options <name> --vision' if this one reads images.")
+		}
+		return
+	}
+	recordUsage("file", info)
+
+	if output != nil {
+		fmt.Println(resp)
+		return
+	}
+	if outputFormat == models.OutputJSON {
+		printJSONAnswer(answerOutput{Model: answeredBy(model, info), File: filePath, Language: language, Question: question, Answer: resp})
 		return
 	}
 
@@ -133,63 +420,84 @@ func askWithFile(filePath, question string) {
 	fmt.Println(resp)
 }
 
-// askMultiModels asks multiple models simultaneously
-func askMultiModels(modelNames []string, question string) {
-	var wg sync.WaitGroup
-	responsesCh := make(chan struct {
-		modelName string
-		response  string
-		err       error
-	}, len(modelNames))
+// explainRoute shows on standard error which model the router chose and why, with --explain-route
+func explainRoute(cmd *cobra.Command, model models.Model, request models.RouteRequest) {
+	if explain, _ := cmd.Flags().GetBool("explain-route"); !explain {
+		return
+	}
 
-	// Create context
-	ctx := context.Background()
+	router, ok := model.(*models.Router)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Route: %s, not routed (a model was named or no router rules are configured)\n", model.Name())
+		return
+	}
 
-	// Ask all models in parallel
-	for _, name := range modelNames {
-		wg.Add(1)
-		go func(modelName string) {
-			defer wg.Done()
-
-			model, err := modelManager.GetModel(modelName)
-			if err != nil {
-				responsesCh <- struct {
-					modelName string
-					response  string
-					err       error
-				}{modelName, "", err}
-				return
-			}
-
-			// Disable streaming output
-			resp, err := model.Chat(ctx, question, models.WithStream(false))
-
-			responsesCh <- struct {
-				modelName string
-				response  string
-				err       error
-			}{modelName, resp, err}
-		}(name)
-	}
-
-	// Wait for all goroutines to complete
-	go func() {
-		wg.Wait()
-		close(responsesCh)
-	}()
-
-	// Collect and display results
-	fmt.Printf("Question: %s\n\n", question)
+	decision := router.Route(request)
+	if decision.Rule == "" {
+		fmt.Fprintf(os.Stderr, "Route: %s, the router default (%s)\n", decision.Model.Name(), decision.Reason)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Route: %s, rule %q (%s)\n", decision.Model.Name(), decision.Rule, decision.Reason)
+}
 
-	for resp := range responsesCh {
-		fmt.Printf("===== Model: %s =====\n", resp.modelName)
-		if resp.err != nil {
-			fmt.Printf("Error: %v\n", resp.err)
-		} else {
-			fmt.Println(resp.response)
-		}
+// routeOnce explains the route of a request and returns the model the router chose for it,
+// for commands that ask a model several times about the same request
+func routeOnce(cmd *cobra.Command, model models.Model, request models.RouteRequest) models.Model {
+	explainRoute(cmd, model, request)
+	if router, ok := model.(*models.Router); ok {
+		return router.Route(request).Model
+	}
+	return model
+}
+
+// confirm asks a yes or no question on the terminal, no being the default
+func confirm(stdin *bufio.Reader, question string) bool {
+	fmt.Print(question + " [y/N] ")
+	line, err := stdin.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false
+	}
+	if err != nil {
+		// Without input, nothing is approved
 		fmt.Println()
 	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
+
+// reportFallbacks tells on standard error which models failed before another one answered
+func reportFallbacks(info *models.ResponseInfo, err error) {
+	for _, fallback := range info.Fallbacks {
+		fmt.Fprintf(os.Stderr, "Model %s failed, trying the next one: %v\n", fallback.Model, fallback.Err)
+	}
+	if len(info.Fallbacks) > 0 && err == nil {
+		fmt.Fprintf(os.Stderr, "Answered by %s\n", info.Model)
+	}
+}
+
+// answeredBy returns the name of the model that answered, which differs from the
+// requested model after a fallback
+func answeredBy(model models.Model, info *models.ResponseInfo) string {
+	if info.Model != "" {
+		return info.Model
+	}
+	return model.Name()
+}
+
+// newGeneration converts the details of a response into a history record
+func newGeneration(info *models.ResponseInfo) *history.Generation {
+	temperature := info.Temperature
+	return &history.Generation{
+		Model:            info.Model,
+		Provider:         info.Provider,
+		Temperature:      &temperature,
+		PromptTokens:     info.Usage.PromptTokens,
+		CompletionTokens: info.Usage.CompletionTokens,
+		TotalTokens:      info.Usage.TotalTokens,
+		LatencyMS:        info.Latency.Milliseconds(),
+		FinishReason:     info.FinishReason,
+		Cached:           info.Cached,
+	}
 }
 
 // convertToModelMessages converts history messages to model messages
@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/pokitpeng/ai/pkg/history"
 	"github.com/spf13/cobra"
 )
 
@@ -16,16 +19,16 @@ var sessionCmd = &cobra.Command{
 	Long:  `View, switch or delete historical conversation sessions.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		// Default to showing session list
-		listSessions()
+		listSessions(cmd)
 	},
 }
 
 var sessionListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all historical sessions",
-	Long:  `List all available historical sessions, including creation time and preview content.`,
+	Long:  `List all available historical sessions, including creation time and preview content. With --here, only list the sessions started in the current git repository or directory.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		listSessions()
+		listSessions(cmd)
 	},
 }
 
@@ -36,7 +39,21 @@ var switchCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		sessionIdentifier := args[0]
-		switchToSession(sessionIdentifier)
+		switchToSession(cmd, sessionIdentifier)
+	},
+}
+
+var showCmd = &cobra.Command{
+	Use:   "show [session_id or number]",
+	Short: "Show the messages of a session",
+	Long:  `Show the messages of a session, with the model, options, token usage and latency of each answer. Shows the current session when no session is specified.`,
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sessionIdentifier := historyManager.GetCurrentSessionID()
+		if len(args) > 0 {
+			sessionIdentifier = args[0]
+		}
+		showSession(cmd, sessionIdentifier)
 	},
 }
 
@@ -47,7 +64,7 @@ var deleteCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		sessionIdentifier := args[0]
-		deleteSession(sessionIdentifier)
+		deleteSession(cmd, sessionIdentifier)
 	},
 }
 
@@ -55,12 +72,23 @@ func init() {
 	rootCmd.AddCommand(sessionCmd)
 	sessionCmd.AddCommand(sessionListCmd)
 	sessionCmd.AddCommand(switchCmd)
+	sessionCmd.AddCommand(showCmd)
 	sessionCmd.AddCommand(deleteCmd)
+
+	sessionCmd.PersistentFlags().Bool("here", false, "Only use the sessions of the current git repository or directory")
+}
+
+// getSessions returns the sessions to list and number, honoring --here
+func getSessions(cmd *cobra.Command) ([]history.SessionInfo, error) {
+	if here, _ := cmd.Flags().GetBool("here"); here {
+		return historyManager.ListWorkspaceSessions()
+	}
+	return historyManager.ListSessions()
 }
 
 // List all sessions
-func listSessions() {
-	sessions, err := historyManager.ListSessions()
+func listSessions(cmd *cobra.Command) {
+	sessions, err := getSessions(cmd)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to get session list: %v\n", err)
 		return
@@ -71,6 +99,21 @@ func listSessions() {
 		return
 	}
 
+	printSessionTable(sessions)
+
+	// Numbers refer to the filtered list, so they need --here as well
+	flag := ""
+	if here, _ := cmd.Flags().GetBool("here"); here {
+		flag = "--here "
+	}
+
+	fmt.Println("✓ indicates current session")
+	fmt.Printf("Use 'ai session switch %s<number or ID>' to switch session\n", flag)
+	fmt.Printf("Use 'ai session delete %s<number or ID>' to delete session\n", flag)
+}
+
+// printSessionTable renders sessions as a numbered table, marking the current session
+func printSessionTable(sessions []history.SessionInfo) {
 	currentID := historyManager.GetCurrentSessionID()
 
 	// Create table
@@ -93,12 +136,13 @@ func listSessions() {
 		{Number: 2, WidthMax: 8, WidthMin: 8, Align: text.AlignCenter},           // No.
 		{Number: 3, WidthMax: 30, WidthMin: 30},                                  // ID
 		{Number: 4, WidthMax: 20, WidthMin: 20},                                  // Updated At
-		{Number: 5, WidthMax: 10, WidthMin: 10, Align: text.AlignCenter},         // Messages
-		{Number: 6, WidthMax: 40, WidthMin: 20, Transformer: truncateString(40)}, // Preview
+		{Number: 5, WidthMax: 15, Transformer: truncateString(15)},               // Branch
+		{Number: 6, WidthMax: 10, WidthMin: 10, Align: text.AlignCenter},         // Messages
+		{Number: 7, WidthMax: 40, WidthMin: 20, Transformer: truncateString(40)}, // Preview
 	})
 
 	// Add header
-	t.AppendHeader(table.Row{"Current", "No.", "ID", "Updated At", "Messages", "Preview"})
+	t.AppendHeader(table.Row{"Current", "No.", "ID", "Updated At", "Branch", "Messages", "Preview"})
 
 	// Add data rows
 	for i, session := range sessions {
@@ -117,6 +161,7 @@ func listSessions() {
 			i + 1,
 			session.ID,
 			timeStr,
+			session.GitBranch,
 			session.MessageCount,
 			session.Preview,
 		})
@@ -124,30 +169,125 @@ func listSessions() {
 
 	// Render table
 	t.Render()
+}
 
-	fmt.Println("✓ indicates current session")
-	fmt.Println("Use 'ai session switch <number or ID>' to switch session")
-	fmt.Println("Use 'ai session delete <number or ID>' to delete session")
+// resolveSessionID turns a number from the session list into a session ID.
+// Other identifiers are returned unchanged.
+func resolveSessionID(cmd *cobra.Command, identifier string) (string, error) {
+	index, err := strconv.Atoi(identifier)
+	if err != nil {
+		return identifier, nil
+	}
+
+	sessions, err := getSessions(cmd)
+	if err != nil {
+		return "", fmt.Errorf("failed to get session list: %w", err)
+	}
+
+	// Check if the number is valid
+	if index < 1 || index > len(sessions) {
+		return "", fmt.Errorf("invalid session number: %d", index)
+	}
+
+	// Use the session ID corresponding to the number
+	return sessions[index-1].ID, nil
 }
 
-// Switch to the specified session
-func switchToSession(identifier string) {
-	// Check if it's a number (number)
-	if index, err := strconv.Atoi(identifier); err == nil {
-		sessions, err := historyManager.ListSessions()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to get session list: %v\n", err)
-			return
+// Show the messages of the specified session
+func showSession(cmd *cobra.Command, identifier string) {
+	identifier, err := resolveSessionID(cmd, identifier)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+
+	session, err := historyManager.GetSession(identifier)
+	if err != nil && identifier == historyManager.GetCurrentSessionID() {
+		// A new current session is not stored until its first message
+		session, err = &history.Session{ID: identifier, Messages: historyManager.GetMessages()}, nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load session: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Session: %s\n", session.ID)
+	if !session.CreatedAt.IsZero() {
+		fmt.Printf("Created: %s\n", session.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	if session.WorkDir != "" {
+		if session.GitBranch != "" {
+			fmt.Printf("Directory: %s (%s)\n", session.WorkDir, session.GitBranch)
+		} else {
+			fmt.Printf("Directory: %s\n", session.WorkDir)
 		}
+	}
 
-		// Check if the number is valid
-		if index < 1 || index > len(sessions) {
-			fmt.Fprintf(os.Stderr, "Invalid session number: %d\n", index)
-			return
+	if len(session.Messages) == 0 {
+		fmt.Println("\nNo messages")
+		return
+	}
+
+	for _, msg := range session.Messages {
+		fmt.Printf("\n[%s] %s", msg.Timestamp.Format("2006-01-02 15:04:05"), msg.Role)
+		if details := formatGeneration(msg.Generation); details != "" {
+			fmt.Printf(" | %s", details)
 		}
+		fmt.Println()
+		for _, call := range msg.ToolCalls {
+			fmt.Println(formatToolCall(call))
+		}
+		fmt.Println(msg.Content)
+	}
+}
+
+// formatToolCall summarizes a tool call and its result on one line
+func formatToolCall(call history.ToolCall) string {
+	result := singleLine(call.Output)
+	if call.Error != "" {
+		result = "error: " + call.Error
+	}
+	return fmt.Sprintf("→ %s %s: %s", call.Name, call.Arguments, text.Trim(result, 80))
+}
 
-		// Use the session ID corresponding to the number
-		identifier = sessions[index-1].ID
+// formatGeneration summarizes how an answer was generated on one line
+func formatGeneration(generation *history.Generation) string {
+	if generation == nil {
+		return ""
+	}
+
+	var details []string
+	if generation.Model != "" {
+		model := generation.Model
+		if generation.Provider != "" {
+			model += " (" + generation.Provider + ")"
+		}
+		details = append(details, model)
+	}
+	if generation.Temperature != nil {
+		details = append(details, "temperature "+strconv.FormatFloat(*generation.Temperature, 'f', -1, 64))
+	}
+	if generation.TotalTokens > 0 {
+		details = append(details, fmt.Sprintf("tokens %d in / %d out", generation.PromptTokens, generation.CompletionTokens))
+	}
+	if generation.LatencyMS > 0 {
+		details = append(details, (time.Duration(generation.LatencyMS) * time.Millisecond).String())
+	}
+	if generation.FinishReason != "" {
+		details = append(details, "finish "+generation.FinishReason)
+	}
+	if generation.Cached {
+		details = append(details, "cached")
+	}
+	return strings.Join(details, " | ")
+}
+
+// Switch to the specified session
+func switchToSession(cmd *cobra.Command, identifier string) {
+	identifier, err := resolveSessionID(cmd, identifier)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
 	}
 
 	// Switch session
@@ -160,23 +300,11 @@ func switchToSession(identifier string) {
 }
 
 // Delete the specified session
-func deleteSession(identifier string) {
-	// Check if it's a number (number)
-	if index, err := strconv.Atoi(identifier); err == nil {
-		sessions, err := historyManager.ListSessions()
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to get session list: %v\n", err)
-			return
-		}
-
-		// Check if the number is valid
-		if index < 1 || index > len(sessions) {
-			fmt.Fprintf(os.Stderr, "Invalid session number: %d\n", index)
-			return
-		}
-
-		// Use the session ID corresponding to the number
-		identifier = sessions[index-1].ID
+func deleteSession(cmd *cobra.Command, identifier string) {
+	identifier, err := resolveSessionID(cmd, identifier)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
 	}
 
 	// Check if it's the current session
@@ -0,0 +1,186 @@
+package ai
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"github.com/pokitpeng/ai/pkg/models"
+	"github.com/pokitpeng/ai/pkg/usage"
+	"github.com/spf13/cobra"
+)
+
+// budgetWarningRatio is the share of the monthly budget from which a warning is shown
+const budgetWarningRatio = 0.8
+
+// usageCmd reports token usage and cost
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show token usage and cost",
+	Long: `Show the token usage and cost of the requests made with this tool, grouped by model, day or session.
+
+Costs are computed with the pricing configured for each model at the time of the request:
+  ai model options gpt-4o --input-price 2.5 --output-price 10
+
+Examples:
+  ai usage
+  ai usage --since 7d --by day
+  ai usage --since 2024-05-01 --by session --csv > usage.csv`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		by, _ := cmd.Flags().GetString("by")
+		asCSV, _ := cmd.Flags().GetBool("csv")
+
+		since, err := usage.ParseSince(sinceFlag, time.Now())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		entries, err := usageLedger.Load(since)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read usage: %v\n", err)
+			os.Exit(1)
+		}
+
+		rows, total, err := usage.Summarize(entries, by)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		if asCSV {
+			if err := writeUsageCSV(by, rows); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to write CSV: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		if len(rows) == 0 {
+			fmt.Printf("No usage recorded since %s\n", since.Format("2006-01-02 15:04"))
+			return
+		}
+
+		printUsageTable(by, rows, total)
+
+		if budget := modelManager.GetDefaults().MonthlyBudget; budget > 0 {
+			spent, err := usageLedger.MonthlyCost(time.Now())
+			if err == nil {
+				fmt.Printf("This month: $%.2f of $%.2f budget\n", spent, budget)
+			}
+		}
+	},
+}
+
+// printUsageTable renders a usage report with a total row
+func printUsageTable(by string, rows []usage.Row, total usage.Row) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.SetStyle(table.StyleLight)
+
+	t.SetColumnConfigs([]table.ColumnConfig{
+		{Number: 2, Align: text.AlignRight, AlignFooter: text.AlignRight},
+		{Number: 3, Align: text.AlignRight, AlignFooter: text.AlignRight},
+		{Number: 4, Align: text.AlignRight, AlignFooter: text.AlignRight},
+		{Number: 5, Align: text.AlignRight, AlignFooter: text.AlignRight},
+		{Number: 6, Align: text.AlignRight, AlignFooter: text.AlignRight},
+	})
+
+	t.AppendHeader(table.Row{by, "Requests", "Input Tokens", "Output Tokens", "Total Tokens", "Cost (USD)"})
+	for _, row := range rows {
+		t.AppendRow(usageRow(row))
+	}
+	t.AppendFooter(usageRow(total))
+
+	t.Render()
+}
+
+func usageRow(row usage.Row) table.Row {
+	return table.Row{row.Key, row.Requests, row.PromptTokens, row.CompletionTokens, row.TotalTokens, fmt.Sprintf("%.4f", row.Cost)}
+}
+
+// writeUsageCSV prints a usage report as CSV on stdout
+func writeUsageCSV(by string, rows []usage.Row) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{by, "requests", "input_tokens", "output_tokens", "total_tokens", "cost_usd"})
+	for _, row := range rows {
+		w.Write([]string{
+			row.Key,
+			strconv.Itoa(row.Requests),
+			strconv.Itoa(row.PromptTokens),
+			strconv.Itoa(row.CompletionTokens),
+			strconv.Itoa(row.TotalTokens),
+			strconv.FormatFloat(row.Cost, 'f', 6, 64),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// checkBudget warns on stderr when this month's spending approaches or exceeds the monthly budget
+func checkBudget() {
+	budget := modelManager.GetDefaults().MonthlyBudget
+	if budget <= 0 {
+		return
+	}
+
+	spent, err := usageLedger.MonthlyCost(time.Now())
+	if err != nil {
+		slog.Warn("failed to read usage", "error", err)
+		return
+	}
+
+	switch {
+	case spent >= budget:
+		fmt.Fprintf(os.Stderr, "Warning: monthly budget exceeded, $%.2f spent of $%.2f\n", spent, budget)
+	case spent >= budget*budgetWarningRatio:
+		fmt.Fprintf(os.Stderr, "Warning: $%.2f of the $%.2f monthly budget spent\n", spent, budget)
+	}
+}
+
+// recordUsage adds a model call to the usage ledger. Cached answers cost nothing and are not recorded.
+func recordUsage(command string, info *models.ResponseInfo) {
+	if info.Cached {
+		return
+	}
+
+	var cost float64
+	if config, err := modelManager.GetModelConfig(info.Model); err == nil {
+		cost = config.Pricing.Cost(info.Usage)
+	}
+
+	var sessionID string
+	if historyManager != nil {
+		sessionID = historyManager.GetCurrentSessionID()
+	}
+
+	err := usageLedger.Record(usage.Entry{
+		Time:             time.Now(),
+		Command:          command,
+		Model:            info.Model,
+		Provider:         info.Provider,
+		SessionID:        sessionID,
+		PromptTokens:     info.Usage.PromptTokens,
+		CompletionTokens: info.Usage.CompletionTokens,
+		TotalTokens:      info.Usage.TotalTokens,
+		Cost:             cost,
+	})
+	if err != nil {
+		slog.Warn("failed to record usage", "error", err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+
+	usageCmd.Flags().String("since", "30d", "Start of the report: days (7d), a duration (12h) or a date (2024-05-01)")
+	usageCmd.Flags().String("by", usage.ByModel, "Group by model, day or session")
+	usageCmd.Flags().Bool("csv", false, "Print the report as CSV")
+}
@@ -0,0 +1,610 @@
+// Package agent gives a model tools to work on the files of a directory: reading
+// files, listing directories, searching, running allowlisted commands and
+// proposing patches. Tools with side effects run only once approved.
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pokitpeng/ai/pkg/models"
+	"github.com/pokitpeng/ai/pkg/util"
+)
+
+// Limits keeping tool output within what a model can take
+const (
+	maxReadBytes   = 100 * 1024
+	maxOutputBytes = 32 * 1024
+	maxEntries     = 500
+	maxMatches     = 100
+	maxLineLength  = 200
+	commandTimeout = time.Minute
+)
+
+// DefaultCommands are the commands run_command may run unless others are allowed.
+// Commands able to run others or delete files, such as find or go, must be allowed explicitly.
+var DefaultCommands = []string{"ls", "cat", "head", "tail", "wc", "grep", "git"}
+
+// Errors returned by the tools
+var (
+	ErrOutsideSandbox    = errors.New("path is outside the working directory")
+	ErrCommandNotAllowed = errors.New("command is not allowed")
+	ErrDeclined          = errors.New("declined by the user")
+	ErrPatchDoesNotApply = errors.New("patch does not apply")
+	ErrMissingArgument   = errors.New("missing argument")
+
+	// errDryRun reports that an action was recorded instead of run
+	errDryRun = errors.New("dry run")
+)
+
+// dryRunOutput tells the model that an action was only proposed
+const dryRunOutput = "dry run: the action was recorded for the user but not applied, continue as if it had been"
+
+// Action is a tool call about to run
+type Action struct {
+	Tool string
+	// Summary describes the action on one line
+	Summary string
+	// Detail shows the command or the change of an action with side effects
+	Detail string
+	// SideEffect reports that the action changes something, so it needs approval
+	SideEffect bool
+}
+
+// ApproveFunc asks whether an action with side effects may run
+type ApproveFunc func(action Action) bool
+
+// Sandbox runs the tools of an agent within a root directory
+type Sandbox struct {
+	root     string
+	commands map[string]bool
+	observe  func(Action)
+	approve  ApproveFunc
+	dryRun   bool
+	proposed []Action
+}
+
+// Option configures a Sandbox
+type Option func(*Sandbox)
+
+// WithCommands sets the commands run_command may run, DefaultCommands by default
+func WithCommands(commands ...string) Option {
+	return func(s *Sandbox) {
+		s.commands = make(map[string]bool, len(commands))
+		for _, command := range commands {
+			s.commands[command] = true
+		}
+	}
+}
+
+// WithObserver calls observe before each action runs, or is proposed in a dry run
+func WithObserver(observe func(Action)) Option {
+	return func(s *Sandbox) {
+		s.observe = observe
+	}
+}
+
+// WithApproval asks approve before each action with side effects.
+// Without it, such actions are declined.
+func WithApproval(approve ApproveFunc) Option {
+	return func(s *Sandbox) {
+		s.approve = approve
+	}
+}
+
+// WithDryRun records the actions with side effects instead of running them, see Proposed
+func WithDryRun() Option {
+	return func(s *Sandbox) {
+		s.dryRun = true
+	}
+}
+
+// NewSandbox creates a sandbox limited to the root directory
+func NewSandbox(root string, options ...Option) (*Sandbox, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Sandbox{root: real}
+	WithCommands(DefaultCommands...)(s)
+	for _, option := range options {
+		option(s)
+	}
+	return s, nil
+}
+
+// Root returns the directory the sandbox is limited to
+func (s *Sandbox) Root() string {
+	return s.root
+}
+
+// Proposed returns the actions with side effects recorded in a dry run
+func (s *Sandbox) Proposed() []Action {
+	return s.proposed
+}
+
+// SystemPrompt tells the model how to work with the tools of the sandbox
+func (s *Sandbox) SystemPrompt() string {
+	commands := make([]string, 0, len(s.commands))
+	for command := range s.commands {
+		commands = append(commands, command)
+	}
+	sort.Strings(commands)
+
+	return fmt.Sprintf(`You are a coding agent working in a local project directory. Use the tools to look at the files before answering, and don't guess their content.
+Paths, including those given to commands, are relative to the project directory and can't leave it. Change files only with propose_patch, and run only these commands with run_command: %s. Commands run without a shell, so pipes, redirections and variables don't work.
+The user approves every patch and command, and may decline some. When done, answer with a short summary of what you found or changed.`, strings.Join(commands, ", "))
+}
+
+// Register adds the tools of the sandbox to a registry
+func (s *Sandbox) Register(registry *models.ToolRegistry) error {
+	tools := []struct {
+		name        string
+		description string
+		parameters  string
+		fn          models.ToolFunc
+	}{
+		{
+			"read_file", "Read a text file of the project",
+			`{"type":"object","properties":{"path":{"type":"string","description":"Path of the file"}},"required":["path"]}`,
+			s.readFile,
+		},
+		{
+			"list_directory", "List the files and directories in a directory of the project",
+			`{"type":"object","properties":{"path":{"type":"string","description":"Path of the directory, the project directory when empty"}}}`,
+			s.listDirectory,
+		},
+		{
+			"grep", "Search the files of the project for a regular expression, returning the matching lines",
+			`{"type":"object","properties":{"pattern":{"type":"string","description":"Regular expression, in Go syntax"},"path":{"type":"string","description":"File or directory to search, the project directory when empty"}},"required":["pattern"]}`,
+			s.grep,
+		},
+		{
+			"run_command", "Run an allowed command in the project directory and return its output",
+			`{"type":"object","properties":{"command":{"type":"string","description":"Command line, such as 'go test ./...'"}},"required":["command"]}`,
+			s.runCommand,
+		},
+		{
+			"propose_patch", "Propose a change to a file: replace the search text, found exactly once, with the replacement. An empty search text creates a new file.",
+			`{"type":"object","properties":{"path":{"type":"string","description":"Path of the file"},"search":{"type":"string","description":"Exact text to replace, with enough lines to be unique"},"replace":{"type":"string","description":"Replacement text"},"description":{"type":"string","description":"Why the change is made"}},"required":["path","search","replace"]}`,
+			s.proposePatch,
+		},
+	}
+
+	for _, tool := range tools {
+		if err := registry.Register(tool.name, tool.description, json.RawMessage(tool.parameters), tool.fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFile returns the content of a text file
+func (s *Sandbox) readFile(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := parseArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	if err := required("path", args.Path); err != nil {
+		return "", err
+	}
+	path, err := s.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+	s.notify(Action{Tool: "read_file", Summary: "Read " + s.display(path)})
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", args.Path)
+	}
+	if info.Size() > maxReadBytes {
+		return "", fmt.Errorf("%s is too large to read (%d bytes), use grep to find the relevant lines", args.Path, info.Size())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if isBinary(data) {
+		return "", fmt.Errorf("%s is not a text file", args.Path)
+	}
+	return string(data), nil
+}
+
+// listDirectory returns the entries of a directory, directories ending with a slash
+func (s *Sandbox) listDirectory(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	if err := parseArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	path, err := s.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+	s.notify(Action{Tool: "list_directory", Summary: "List " + s.display(path)})
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "(empty directory)", nil
+	}
+
+	var b strings.Builder
+	for i, entry := range entries {
+		if i == maxEntries {
+			fmt.Fprintf(&b, "... %d more entries\n", len(entries)-maxEntries)
+			break
+		}
+		if entry.IsDir() {
+			fmt.Fprintf(&b, "%s/\n", entry.Name())
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			fmt.Fprintf(&b, "%s (%d bytes)\n", entry.Name(), info.Size())
+		} else {
+			fmt.Fprintf(&b, "%s\n", entry.Name())
+		}
+	}
+	return b.String(), nil
+}
+
+// grep returns the lines matching a regular expression as path:line: text
+func (s *Sandbox) grep(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path"`
+	}
+	if err := parseArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	if err := required("pattern", args.Pattern); err != nil {
+		return "", err
+	}
+	pattern, err := regexp.Compile(args.Pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	path, err := s.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+	s.notify(Action{Tool: "grep", Summary: fmt.Sprintf("Search %s for %q", s.display(path), args.Pattern)})
+
+	var matches []string
+	more := false
+	err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.IsDir() {
+			// Skip hidden directories, such as .git
+			if file != path && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if len(matches) >= maxMatches {
+			more = true
+			return filepath.SkipAll
+		}
+
+		fileMatches, err := grepFile(file, s.display(file), pattern, maxMatches-len(matches))
+		if err == nil {
+			matches = append(matches, fileMatches...)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(matches) == 0 {
+		return "no matches", nil
+	}
+	if more {
+		matches = append(matches, fmt.Sprintf("... stopped after %d matches, narrow the search", maxMatches))
+	}
+	return strings.Join(matches, "\n"), nil
+}
+
+// grepFile returns up to limit matching lines of a text file
+func grepFile(path, name string, pattern *regexp.Regexp, limit int) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isBinary(data) {
+		return nil, nil
+	}
+
+	var matches []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxReadBytes)
+	for line := 1; scanner.Scan() && len(matches) < limit; line++ {
+		text := scanner.Text()
+		if pattern.MatchString(text) {
+			matches = append(matches, fmt.Sprintf("%s:%d: %s", name, line, truncate(text, maxLineLength)))
+		}
+	}
+	return matches, nil
+}
+
+// runCommand runs an allowlisted command in the root directory, once approved
+func (s *Sandbox) runCommand(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Command string `json:"command"`
+	}
+	if err := parseArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	if err := required("command", strings.TrimSpace(args.Command)); err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(args.Command)
+	if !s.commands[fields[0]] {
+		return "", fmt.Errorf("%w: %s", ErrCommandNotAllowed, fields[0])
+	}
+	if err := s.checkArguments(fields[1:]); err != nil {
+		return "", err
+	}
+
+	action := Action{Tool: "run_command", Summary: "Run " + args.Command, Detail: "$ " + args.Command, SideEffect: true}
+	if err := s.confirm(action); errors.Is(err, errDryRun) {
+		return dryRunOutput, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	command := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	command.Dir = s.root
+	output, err := command.CombinedOutput()
+
+	result := truncate(string(output), maxOutputBytes)
+	if err != nil {
+		// A failing command, such as failing tests, is a result for the model
+		result += fmt.Sprintf("\n(command failed: %v)", err)
+	}
+	if result == "" {
+		result = "(no output)"
+	}
+	return result, nil
+}
+
+// checkArguments fails when an argument of a command, or the value of a --flag=value,
+// is a path leading outside of the root
+func (s *Sandbox) checkArguments(args []string) error {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			_, value, found := strings.Cut(arg, "=")
+			if !found {
+				continue
+			}
+			arg = value
+		}
+		if filepath.IsAbs(arg) || slices.Contains(strings.Split(filepath.ToSlash(arg), "/"), "..") {
+			return fmt.Errorf("%w: %s", ErrOutsideSandbox, arg)
+		}
+		if _, err := s.resolve(arg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// proposePatch replaces a unique text of a file, or creates a file, once approved
+func (s *Sandbox) proposePatch(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Path        string `json:"path"`
+		Search      string `json:"search"`
+		Replace     string `json:"replace"`
+		Description string `json:"description"`
+	}
+	if err := parseArguments(arguments, &args); err != nil {
+		return "", err
+	}
+	if err := required("path", args.Path); err != nil {
+		return "", err
+	}
+	path, err := s.resolve(args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	perm := os.FileMode(0644)
+	var content string
+	info, statErr := os.Stat(path)
+	switch {
+	case args.Search == "":
+		if statErr == nil {
+			return "", fmt.Errorf("%w: %s already exists, give the text to replace", ErrPatchDoesNotApply, args.Path)
+		}
+		content = args.Replace
+	case statErr != nil:
+		return "", statErr
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		perm = info.Mode().Perm()
+		switch count := strings.Count(string(data), args.Search); count {
+		case 0:
+			return "", fmt.Errorf("%w: the search text was not found in %s", ErrPatchDoesNotApply, args.Path)
+		case 1:
+			content = strings.Replace(string(data), args.Search, args.Replace, 1)
+		default:
+			return "", fmt.Errorf("%w: the search text was found %d times in %s, include more lines", ErrPatchDoesNotApply, count, args.Path)
+		}
+	}
+
+	summary := "Patch " + s.display(path)
+	if args.Search == "" {
+		summary = "Create " + s.display(path)
+	}
+	if args.Description != "" {
+		summary += ": " + args.Description
+	}
+	action := Action{Tool: "propose_patch", Summary: summary, Detail: patchDetail(args.Search, args.Replace), SideEffect: true}
+	if err := s.confirm(action); errors.Is(err, errDryRun) {
+		return dryRunOutput, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := util.WriteFileAtomic(path, []byte(content), perm); err != nil {
+		return "", err
+	}
+	return "patch applied to " + s.display(path), nil
+}
+
+// confirm shows an action with side effects and asks for approval. In a dry run,
+// the action is recorded instead and errDryRun returned.
+func (s *Sandbox) confirm(action Action) error {
+	s.notify(action)
+	if s.dryRun {
+		s.proposed = append(s.proposed, action)
+		return errDryRun
+	}
+	if s.approve == nil || !s.approve(action) {
+		return ErrDeclined
+	}
+	return nil
+}
+
+// notify tells the observer about an action
+func (s *Sandbox) notify(action Action) {
+	if s.observe != nil {
+		s.observe(action)
+	}
+}
+
+// resolve returns the real path of a path relative to the root, failing when it
+// leads outside of the root, including through symbolic links
+func (s *Sandbox) resolve(path string) (string, error) {
+	full := path
+	if !filepath.IsAbs(full) {
+		full = filepath.Join(s.root, full)
+	}
+
+	real, err := evalExisting(filepath.Clean(full))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(s.root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrOutsideSandbox, path)
+	}
+	return real, nil
+}
+
+// display returns a path relative to the root for messages
+func (s *Sandbox) display(path string) string {
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// evalExisting follows the symbolic links of the existing part of a path,
+// so that files about to be created are checked too
+func evalExisting(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return real, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	realParent, err := evalExisting(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realParent, filepath.Base(path)), nil
+}
+
+// parseArguments decodes the JSON arguments of a tool call into args
+func parseArguments(arguments json.RawMessage, args any) error {
+	if err := json.Unmarshal(arguments, args); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// required fails when the value of a required argument is empty
+func required(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("%w: %s", ErrMissingArgument, name)
+	}
+	return nil
+}
+
+// patchDetail shows the lines a patch removes and adds
+func patchDetail(search, replace string) string {
+	var b strings.Builder
+	for _, line := range util.SplitLines(search) {
+		b.WriteString("- " + line + "\n")
+	}
+	for _, line := range util.SplitLines(replace) {
+		b.WriteString("+ " + line + "\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// isBinary reports whether data looks like a binary file
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0
+}
+
+// truncate shortens text to at most limit bytes, without splitting a character
+func truncate(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	return strings.ToValidUTF8(text[:limit], "") + "... (truncated)"
+}
@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestSandbox creates a sandbox in a directory holding a small project
+func newTestSandbox(t *testing.T, options ...Option) *Sandbox {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":        "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n",
+		"pkg/util.go":    "package pkg\n\n// TODO: remove\nfunc Util() {}\n",
+		".git/config":    "TODO in git\n",
+		"data/image.bin": "\x00\x01TODO",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewSandbox(root, options...)
+	if err != nil {
+		t.Fatalf("NewSandbox failed: %v", err)
+	}
+	return s
+}
+
+// call runs a tool of the sandbox with JSON arguments
+func call(t *testing.T, tool func(context.Context, json.RawMessage) (string, error), arguments string) (string, error) {
+	t.Helper()
+	return tool(context.Background(), json.RawMessage(arguments))
+}
+
+func TestSandbox_Resolve(t *testing.T) {
+	s := newTestSandbox(t)
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(s.Root(), "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"main.go", "pkg/../main.go", "new/file.go", ""} {
+		if _, err := s.resolve(path); err != nil {
+			t.Errorf("resolve(%q) failed: %v", path, err)
+		}
+	}
+	for _, path := range []string{"../secret", "/etc/passwd", "link/file", "pkg/../../secret"} {
+		if _, err := s.resolve(path); !errors.Is(err, ErrOutsideSandbox) {
+			t.Errorf("resolve(%q) = %v, want ErrOutsideSandbox", path, err)
+		}
+	}
+}
+
+func TestSandbox_ReadTools(t *testing.T) {
+	var observed []string
+	s := newTestSandbox(t, WithObserver(func(action Action) { observed = append(observed, action.Summary) }))
+
+	content, err := call(t, s.readFile, `{"path":"main.go"}`)
+	if err != nil || !strings.Contains(content, "println") {
+		t.Errorf("Expected the file content, got %q, %v", content, err)
+	}
+	if _, err := call(t, s.readFile, `{"path":"data/image.bin"}`); err == nil {
+		t.Error("Expected an error for a binary file")
+	}
+	if _, err := call(t, s.readFile, `{}`); !errors.Is(err, ErrMissingArgument) {
+		t.Errorf("Expected ErrMissingArgument, got %v", err)
+	}
+
+	listing, err := call(t, s.listDirectory, `{}`)
+	if err != nil || !strings.Contains(listing, "pkg/\n") || !strings.Contains(listing, "main.go (") {
+		t.Errorf("Unexpected listing %q, %v", listing, err)
+	}
+
+	// Hidden directories and binary files are skipped
+	matches, err := call(t, s.grep, `{"pattern":"TODO"}`)
+	if err != nil || matches != "pkg/util.go:3: // TODO: remove" {
+		t.Errorf("Unexpected matches %q, %v", matches, err)
+	}
+
+	if len(observed) != 4 || observed[0] != "Read main.go" {
+		t.Errorf("Expected every action to be observed, got %v", observed)
+	}
+}
+
+func TestSandbox_RunCommand(t *testing.T) {
+	var approved []string
+	s := newTestSandbox(t, WithCommands("ls"), WithApproval(func(action Action) bool {
+		approved = append(approved, action.Summary)
+		return true
+	}))
+
+	output, err := call(t, s.runCommand, `{"command":"ls pkg"}`)
+	if err != nil || strings.TrimSpace(output) != "util.go" || len(approved) != 1 {
+		t.Errorf("Expected the approved command to run, got %q, %v", output, err)
+	}
+	if _, err := call(t, s.runCommand, `{"command":"rm -rf pkg"}`); !errors.Is(err, ErrCommandNotAllowed) {
+		t.Errorf("Expected ErrCommandNotAllowed, got %v", err)
+	}
+
+	// Arguments can't reach files outside of the root
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(s.Root(), "link")); err != nil {
+		t.Fatal(err)
+	}
+	for _, command := range []string{"ls ../..", "ls /", "ls pkg/../..", "ls link", "ls --color=/etc"} {
+		if _, err := call(t, s.runCommand, `{"command":"`+command+`"}`); !errors.Is(err, ErrOutsideSandbox) {
+			t.Errorf("Expected ErrOutsideSandbox for %q, got %v", command, err)
+		}
+	}
+	if len(approved) != 1 {
+		t.Errorf("Expected no approval to be asked for rejected commands, got %v", approved)
+	}
+
+	// Without approval nothing runs
+	s = newTestSandbox(t, WithCommands("ls"))
+	if _, err := call(t, s.runCommand, `{"command":"ls"}`); !errors.Is(err, ErrDeclined) {
+		t.Errorf("Expected ErrDeclined, got %v", err)
+	}
+}
+
+func TestSandbox_ProposePatch(t *testing.T) {
+	approve := true
+	s := newTestSandbox(t, WithApproval(func(Action) bool { return approve }))
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(s.Root(), name))
+		return string(data)
+	}
+
+	if _, err := call(t, s.proposePatch, `{"path":"main.go","search":"\"hello\"","replace":"\"world\""}`); err != nil {
+		t.Fatalf("proposePatch failed: %v", err)
+	}
+	if !strings.Contains(read("main.go"), `println("world")`) {
+		t.Errorf("Expected the patch to be applied, got %q", read("main.go"))
+	}
+
+	if _, err := call(t, s.proposePatch, `{"path":"docs/new.md","search":"","replace":"# New\n"}`); err != nil || read("docs/new.md") != "# New\n" {
+		t.Errorf("Expected the file to be created, got %q, %v", read("docs/new.md"), err)
+	}
+
+	if _, err := call(t, s.proposePatch, `{"path":"main.go","search":"missing","replace":"x"}`); !errors.Is(err, ErrPatchDoesNotApply) {
+		t.Errorf("Expected ErrPatchDoesNotApply, got %v", err)
+	}
+
+	approve = false
+	if _, err := call(t, s.proposePatch, `{"path":"main.go","search":"world","replace":"there"}`); !errors.Is(err, ErrDeclined) {
+		t.Errorf("Expected ErrDeclined, got %v", err)
+	}
+	if !strings.Contains(read("main.go"), "world") {
+		t.Error("Expected the declined patch not to be applied")
+	}
+}
+
+func TestSandbox_DryRun(t *testing.T) {
+	s := newTestSandbox(t, WithCommands("go"), WithDryRun(), WithApproval(func(Action) bool {
+		t.Error("Expected no approval to be asked in a dry run")
+		return true
+	}))
+
+	output, err := call(t, s.proposePatch, `{"path":"main.go","search":"hello","replace":"world","description":"greet the world"}`)
+	if err != nil || output != dryRunOutput {
+		t.Errorf("Expected the patch to be proposed only, got %q, %v", output, err)
+	}
+	if _, err := call(t, s.runCommand, `{"command":"go test ./..."}`); err != nil {
+		t.Errorf("Expected the command to be proposed only, got %v", err)
+	}
+
+	proposed := s.Proposed()
+	if len(proposed) != 2 || proposed[0].Summary != "Patch main.go: greet the world" || proposed[0].Detail != "- hello\n+ world" {
+		t.Errorf("Unexpected proposed actions %+v", proposed)
+	}
+	data, _ := os.ReadFile(filepath.Join(s.Root(), "main.go"))
+	if !strings.Contains(string(data), "hello") {
+		t.Error("Expected the file to be unchanged in a dry run")
+	}
+}
@@ -0,0 +1,179 @@
+package edit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pokitpeng/ai/pkg/util"
+)
+
+// maxBackups is the number of edits kept to undo
+const maxBackups = 20
+
+// Errors of undoing edits
+var (
+	ErrNoBackup         = errors.New("no edit to undo")
+	ErrChangedSinceEdit = errors.New("file changed since the edit")
+)
+
+// Backup holds the files changed by an edit as they were before it
+type Backup struct {
+	ID          string       `json:"id"`
+	Time        time.Time    `json:"time"`
+	Instruction string       `json:"instruction"`
+	Files       []BackupFile `json:"files"`
+}
+
+// BackupFile is the content of a file before an edit
+type BackupFile struct {
+	// Path is the absolute path of the file
+	Path     string `json:"path"`
+	Original string `json:"original"`
+	// EditedSHA256 is the hash of the content written by the edit
+	EditedSHA256 string `json:"edited_sha256"`
+}
+
+// Backups stores the backups of the latest edits, one JSON file each
+type Backups struct {
+	dir string
+}
+
+// NewBackups creates a backup store in dir
+func NewBackups(dir string) *Backups {
+	return &Backups{dir: dir}
+}
+
+// Save records the content of files before an edit, and a hash of the edited content
+func (b *Backups) Save(instruction string, files []File, edited map[string]string) (*Backup, error) {
+	now := time.Now()
+	backup := &Backup{
+		ID:          fmt.Sprintf("%s-%09d", now.Format("20060102-150405"), now.Nanosecond()),
+		Time:        now,
+		Instruction: instruction,
+	}
+	for _, file := range files {
+		content, ok := edited[file.Name]
+		if !ok {
+			continue
+		}
+		path, err := filepath.Abs(file.Name)
+		if err != nil {
+			return nil, err
+		}
+		backup.Files = append(backup.Files, BackupFile{Path: path, Original: file.Content, EditedSHA256: hash(content)})
+	}
+
+	// Backups hold the contents of the user's files, keep them private like the response cache
+	if err := os.MkdirAll(b.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := util.WriteFileAtomic(filepath.Join(b.dir, backup.ID+".json"), data, 0600); err != nil {
+		return nil, err
+	}
+
+	b.prune()
+	return backup, nil
+}
+
+// Latest returns the backup of the latest edit
+func (b *Backups) Latest() (*Backup, error) {
+	ids, err := b.ids()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrNoBackup
+	}
+
+	data, err := os.ReadFile(filepath.Join(b.dir, ids[len(ids)-1]+".json"))
+	if err != nil {
+		return nil, err
+	}
+	var backup Backup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	return &backup, nil
+}
+
+// Undo restores the files of the latest edit and removes its backup. It fails
+// without changing anything when a file was changed since the edit.
+func (b *Backups) Undo() (*Backup, error) {
+	backup, err := b.Latest()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range backup.Files {
+		data, err := os.ReadFile(file.Path)
+		if err != nil {
+			return nil, err
+		}
+		if hash(string(data)) != file.EditedSHA256 {
+			return nil, fmt.Errorf("%w: %s", ErrChangedSinceEdit, file.Path)
+		}
+	}
+
+	restored := make(map[string]string, len(backup.Files))
+	for _, file := range backup.Files {
+		restored[file.Path] = file.Original
+	}
+	if err := WriteFiles(restored); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(filepath.Join(b.dir, backup.ID+".json")); err != nil {
+		return nil, err
+	}
+	return backup, nil
+}
+
+// ids returns the IDs of the backups, oldest first
+func (b *Backups) ids() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// prune removes the oldest backups beyond maxBackups
+func (b *Backups) prune() {
+	ids, err := b.ids()
+	if err != nil {
+		return
+	}
+	for len(ids) > maxBackups {
+		os.Remove(filepath.Join(b.dir, ids[0]+".json"))
+		ids = ids[1:]
+	}
+}
+
+// hash returns the SHA-256 of content in hex
+func hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
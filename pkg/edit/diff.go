@@ -0,0 +1,210 @@
+package edit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pokitpeng/ai/pkg/util"
+)
+
+// diffContext is the number of unchanged lines shown around changes
+const diffContext = 3
+
+// opKind is the kind of a line in a diff
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// diffOp is a line kept, deleted or inserted
+type diffOp struct {
+	kind opKind
+	line string
+}
+
+// Diff returns the unified diff of the changes to a file, empty when there are none
+func Diff(name, before, after string) string {
+	if before == after {
+		return ""
+	}
+	ops := diffLines(util.SplitLines(before), util.SplitLines(after))
+
+	// Group the changes with their context into hunks of op indexes
+	var hunks [][2]int
+	for i, op := range ops {
+		if op.kind == opEqual {
+			continue
+		}
+		start, end := max(i-diffContext, 0), min(i+diffContext+1, len(ops))
+		if len(hunks) > 0 && start <= hunks[len(hunks)-1][1] {
+			hunks[len(hunks)-1][1] = end
+		} else {
+			hunks = append(hunks, [2]int{start, end})
+		}
+	}
+
+	// Line numbers before each op
+	oldLines, newLines := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldLines[i+1], newLines[i+1] = oldLines[i], newLines[i]
+		if op.kind != opInsert {
+			oldLines[i+1]++
+		}
+		if op.kind != opDelete {
+			newLines[i+1]++
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", name, name)
+	for _, hunk := range hunks {
+		start, end := hunk[0], hunk[1]
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n",
+			hunkRange(oldLines[start], oldLines[end]-oldLines[start]),
+			hunkRange(newLines[start], newLines[end]-newLines[start]))
+		for _, op := range ops[start:end] {
+			prefix := " "
+			switch op.kind {
+			case opDelete:
+				prefix = "-"
+			case opInsert:
+				prefix = "+"
+			}
+			b.WriteString(prefix + op.line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// hunkRange formats the start and length of a hunk, the start counting from 1
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// diffLines returns a shortest edit script from a to b, with the linear space variant of
+// Myers' algorithm: the lines both ends have in common are kept, then the middle snake of
+// the shortest path splits what is left into two halves diffed in turn
+func diffLines(a, b []string) []diffOp {
+	var ops []diffOp
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, diffOp{opEqual, a[prefix]})
+		prefix++
+	}
+	a, b = a[prefix:], b[prefix:]
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-suffix-1] == b[len(b)-suffix-1] {
+		suffix++
+	}
+	common := a[len(a)-suffix:]
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	if x, y, ok := middleSnake(a, b); ok {
+		ops = append(ops, diffLines(a[:x], b[:y])...)
+		ops = append(ops, diffLines(a[x:], b[y:])...)
+	} else {
+		for _, line := range a {
+			ops = append(ops, diffOp{opDelete, line})
+		}
+		for _, line := range b {
+			ops = append(ops, diffOp{opInsert, line})
+		}
+	}
+
+	for _, line := range common {
+		ops = append(ops, diffOp{opEqual, line})
+	}
+	return ops
+}
+
+// middleSnake searches the shortest edit script from a to b from both ends at once and
+// returns where the two paths meet. It fails when a or b is empty, or they have no line in common.
+func middleSnake(a, b []string) (int, int, bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+
+	// forward and backward hold the furthest x reached on each diagonal k = x - y,
+	// counted from the start and from the end
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	forward, backward := make([]int, 2*maxD+2), make([]int, 2*maxD+2)
+	for i := range forward {
+		forward[i], backward[i] = -1, -1
+	}
+	forward[offset+1], backward[offset+1] = 0, 0
+
+	// When the difference of the lengths is odd, the paths meet on a forward step
+	delta := n - m
+	odd := delta%2 != 0
+
+	// Diagonals that left the edit graph are not searched again
+	var forwardStart, forwardEnd, backwardStart, backwardEnd int
+	for d := 0; d < maxD; d++ {
+		for k := -d + forwardStart; k <= d-forwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			switch {
+			case x > n:
+				forwardEnd += 2
+			case y > m:
+				forwardStart += 2
+			case odd:
+				i := offset + delta - k
+				if i >= 0 && i < len(backward) && backward[i] != -1 && x >= n-backward[i] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + backwardStart; k <= d-backwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			switch {
+			case x > n:
+				backwardEnd += 2
+			case y > m:
+				backwardStart += 2
+			case !odd:
+				i := offset + delta - k
+				if i >= 0 && i < len(forward) && forward[i] != -1 && forward[i] >= n-x {
+					return forward[i], forward[i] - (i - offset), true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,213 @@
+// Package edit asks a model for changes to files as SEARCH/REPLACE blocks,
+// checks that they apply, shows them as a unified diff and keeps backups to undo them.
+package edit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pokitpeng/ai/pkg/util"
+)
+
+// Markers of a SEARCH/REPLACE block
+const (
+	searchMarker  = "<<<<<<< SEARCH"
+	dividerMarker = "======="
+	replaceMarker = ">>>>>>> REPLACE"
+)
+
+// SystemPrompt asks the model to answer with SEARCH/REPLACE blocks only
+const SystemPrompt = `You edit files following an instruction. Reply only with SEARCH/REPLACE blocks, one per change, in this format:
+
+path/of/the/file
+<<<<<<< SEARCH
+exact lines of the current file
+=======
+the lines replacing them
+>>>>>>> REPLACE
+
+The SEARCH part must match the current file exactly, whitespace and indentation included, and only once: include enough lines to make it unique. Write the file path exactly as given. Keep the blocks small. To delete lines, leave the REPLACE part empty. Write nothing outside the blocks.`
+
+// Errors of edits that don't apply
+var (
+	ErrNoEdits        = errors.New("no SEARCH/REPLACE blocks found")
+	ErrMalformedBlock = errors.New("malformed SEARCH/REPLACE block")
+	ErrUnknownFile    = errors.New("edit of a file that was not given")
+	ErrNotFound       = errors.New("search text not found")
+	ErrAmbiguous      = errors.New("search text found more than once")
+)
+
+// File is a file to edit
+type File struct {
+	Name     string
+	Language string
+	Content  string
+}
+
+// Block replaces the search text of a file
+type Block struct {
+	File    string
+	Search  string
+	Replace string
+}
+
+// LoadFiles reads the files to edit
+func LoadFiles(names []string) ([]File, error) {
+	files := make([]File, 0, len(names))
+	for _, name := range names {
+		content, language, err := util.GetFileInfo(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Name: name, Language: language, Content: content})
+	}
+	return files, nil
+}
+
+// Prompt builds the question asking for the edits of the files
+func Prompt(instruction string, files []File) string {
+	var b strings.Builder
+	for _, file := range files {
+		fmt.Fprintf(&b, "file: %s (%s)\n%s\n", file.Name, file.Language, file.Content)
+		if !strings.HasSuffix(file.Content, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	fmt.Fprintf(&b, "instruction: %s", instruction)
+	return b.String()
+}
+
+// Parse reads the SEARCH/REPLACE blocks of an answer. The line before a block names its file.
+func Parse(answer string) ([]Block, error) {
+	lines := strings.Split(strings.ReplaceAll(answer, "\r\n", "\n"), "\n")
+
+	var blocks []Block
+	fileName := ""
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		switch {
+		case line == searchMarker:
+			if fileName == "" {
+				return nil, fmt.Errorf("%w: no file name before line %d", ErrMalformedBlock, i+1)
+			}
+			block, next, err := parseBlock(lines, i+1)
+			if err != nil {
+				return nil, err
+			}
+			block.File = fileName
+			blocks = append(blocks, block)
+			i = next
+		case line == dividerMarker || line == replaceMarker:
+			return nil, fmt.Errorf("%w: unexpected %q on line %d", ErrMalformedBlock, line, i+1)
+		case line != "" && !strings.HasPrefix(line, "```"):
+			// Code fences around blocks are ignored
+			fileName = strings.Trim(strings.TrimSpace(line), "`*")
+		}
+	}
+
+	if len(blocks) == 0 {
+		return nil, ErrNoEdits
+	}
+	return blocks, nil
+}
+
+// parseBlock reads the search and replace parts of a block starting at line start,
+// and returns the index of its last line
+func parseBlock(lines []string, start int) (Block, int, error) {
+	var search, replace []string
+	inReplace := false
+	for i := start; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		switch {
+		case line == dividerMarker && !inReplace:
+			inReplace = true
+		case line == replaceMarker && inReplace:
+			return Block{Search: joinLines(search), Replace: joinLines(replace)}, i, nil
+		case line == searchMarker || line == replaceMarker:
+			return Block{}, 0, fmt.Errorf("%w: unexpected %q on line %d", ErrMalformedBlock, line, i+1)
+		case inReplace:
+			replace = append(replace, lines[i])
+		default:
+			search = append(search, lines[i])
+		}
+	}
+	return Block{}, 0, fmt.Errorf("%w: block starting on line %d is not closed", ErrMalformedBlock, start)
+}
+
+// joinLines joins lines, each ending with a newline
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Apply applies the blocks in order to the files and returns the content of the changed files
+func Apply(files []File, blocks []Block) (map[string]string, error) {
+	contents := make(map[string]string, len(files))
+	for _, file := range files {
+		contents[file.Name] = file.Content
+	}
+
+	changed := make(map[string]string)
+	for i, block := range blocks {
+		content, ok := contents[block.File]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownFile, block.File)
+		}
+		if block.Search == "" {
+			return nil, fmt.Errorf("%w: block %d of %s has an empty SEARCH part", ErrMalformedBlock, i+1, block.File)
+		}
+
+		// The last line of a file may have no newline
+		missingNewline := !strings.HasSuffix(content, "\n")
+		if missingNewline {
+			content += "\n"
+		}
+
+		switch count := strings.Count(content, block.Search); count {
+		case 0:
+			return nil, fmt.Errorf("%w: block %d of %s, starting with %q", ErrNotFound, i+1, block.File, firstLine(block.Search))
+		case 1:
+			content = strings.Replace(content, block.Search, block.Replace, 1)
+		default:
+			return nil, fmt.Errorf("%w: block %d of %s matches %d times", ErrAmbiguous, i+1, block.File, count)
+		}
+
+		if missingNewline {
+			content = strings.TrimSuffix(content, "\n")
+		}
+		contents[block.File] = content
+		changed[block.File] = content
+	}
+
+	// Blocks may cancel each other out
+	for _, file := range files {
+		if content, ok := changed[file.Name]; ok && content == file.Content {
+			delete(changed, file.Name)
+		}
+	}
+	return changed, nil
+}
+
+// WriteFiles writes the edited files, keeping their permissions
+func WriteFiles(contents map[string]string) error {
+	for name, content := range contents {
+		perm := os.FileMode(0644)
+		if info, err := os.Stat(name); err == nil {
+			perm = info.Mode().Perm()
+		}
+		if err := util.WriteFileAtomic(name, []byte(content), perm); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// firstLine returns the first line of text
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(text, "\n")
+	return line
+}
@@ -0,0 +1,222 @@
+package edit
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	answer := "Here are the changes:\n```go\nmain.go\n<<<<<<< SEARCH\nfunc main() {\n\tprintln(\"hello\")\n=======\nfunc main() {\n\tprintln(\"world\")\n>>>>>>> REPLACE\n```\n\n`util.go`\n<<<<<<< SEARCH\n// TODO\n=======\n>>>>>>> REPLACE\n"
+
+	blocks, err := Parse(answer)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Block{
+		{File: "main.go", Search: "func main() {\n\tprintln(\"hello\")\n", Replace: "func main() {\n\tprintln(\"world\")\n"},
+		{File: "util.go", Search: "// TODO\n", Replace: ""},
+	}
+	if len(blocks) != len(want) {
+		t.Fatalf("Expected %d blocks, got %+v", len(want), blocks)
+	}
+	for i := range want {
+		if blocks[i] != want[i] {
+			t.Errorf("Block %d = %+v, want %+v", i, blocks[i], want[i])
+		}
+	}
+
+	malformed := map[string]error{
+		"Sure, I'll do that.":                            ErrNoEdits,
+		"<<<<<<< SEARCH\na\n=======\nb\n>>>>>>> REPLACE": ErrMalformedBlock,
+		"main.go\n<<<<<<< SEARCH\na\n=======\nb\n":       ErrMalformedBlock,
+		"main.go\n<<<<<<< SEARCH\na\n>>>>>>> REPLACE\n":  ErrMalformedBlock,
+	}
+	for answer, want := range malformed {
+		if _, err := Parse(answer); !errors.Is(err, want) {
+			t.Errorf("Parse(%q) = %v, want %v", answer, err, want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	files := []File{
+		{Name: "main.go", Content: "package main\n\nfunc main() {\n\tprintln(\"hello\")\n}"},
+		{Name: "util.go", Content: "package main\n"},
+	}
+
+	changed, err := Apply(files, []Block{
+		{File: "main.go", Search: "\tprintln(\"hello\")\n}\n", Replace: "\tprintln(\"world\")\n}\n"},
+		{File: "main.go", Search: "package main\n\n", Replace: "package main\n\nimport \"os\"\n\n"},
+	})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(changed) != 1 || changed["main.go"] != "package main\n\nimport \"os\"\n\nfunc main() {\n\tprintln(\"world\")\n}" {
+		t.Errorf("Unexpected changes %q", changed)
+	}
+
+	failures := map[Block]error{
+		{File: "other.go", Search: "x\n"}:      ErrUnknownFile,
+		{File: "main.go", Search: "missing\n"}: ErrNotFound,
+		{File: "util.go", Search: ""}:          ErrMalformedBlock,
+	}
+	files = append(files, File{Name: "twice.go", Content: "a\na\n"})
+	failures[Block{File: "twice.go", Search: "a\n"}] = ErrAmbiguous
+	for block, want := range failures {
+		if _, err := Apply(files, []Block{block}); !errors.Is(err, want) {
+			t.Errorf("Apply(%+v) = %v, want %v", block, err, want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+
+	want := `--- a/x.txt
++++ b/x.txt
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -9,3 +9,4 @@
+ i
+ j
+ k
++l
+`
+	if got := Diff("x.txt", before, after); got != want {
+		t.Errorf("Diff =\n%s\nwant\n%s", got, want)
+	}
+	if Diff("x.txt", before, before) != "" {
+		t.Error("Expected no diff for unchanged content")
+	}
+	if got := Diff("new.txt", "", "x\n"); got != "--- a/new.txt\n+++ b/new.txt\n@@ -0,0 +1 @@\n+x\n" {
+		t.Errorf("Unexpected diff of a new file %q", got)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	// lcs returns the length of the longest common subsequence, which a shortest edit script keeps
+	lcs := func(a, b []string) int {
+		lengths := make([][]int, len(a)+1)
+		for i := range lengths {
+			lengths[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					lengths[i][j] = lengths[i+1][j+1] + 1
+				} else {
+					lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+				}
+			}
+		}
+		return lengths[0][0]
+	}
+
+	random := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		result := make([]string, random.Intn(12))
+		for i := range result {
+			result[i] = string(rune('a' + random.Intn(4)))
+		}
+		return result
+	}
+	for i := 0; i < 500; i++ {
+		a, b := lines(), lines()
+		var before, after []string
+		kept := 0
+		for _, op := range diffLines(a, b) {
+			if op.kind != opInsert {
+				before = append(before, op.line)
+			}
+			if op.kind != opDelete {
+				after = append(after, op.line)
+			}
+			if op.kind == opEqual {
+				kept++
+			}
+		}
+		if !slices.Equal(before, a) || !slices.Equal(after, b) {
+			t.Fatalf("diffLines(%v, %v) doesn't turn one into the other", a, b)
+		}
+		if want := lcs(a, b); kept != want {
+			t.Fatalf("diffLines(%v, %v) keeps %d lines, want %d", a, b, kept, want)
+		}
+	}
+
+	// Rewriting a large file diffs in linear space
+	a, b := make([]string, 5000), make([]string, 5000)
+	for i := range a {
+		a[i], b[i] = fmt.Sprintf("old %d", i), fmt.Sprintf("new %d", i)
+	}
+	if ops := diffLines(a, b); len(ops) != 10000 {
+		t.Errorf("Expected every line to change, got %d ops", len(ops))
+	}
+}
+
+func TestBackups(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(name, []byte("before\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	backups := NewBackups(filepath.Join(dir, "backups"))
+	if _, err := backups.Undo(); !errors.Is(err, ErrNoBackup) {
+		t.Errorf("Expected ErrNoBackup, got %v", err)
+	}
+
+	files, err := LoadFiles([]string{name})
+	if err != nil {
+		t.Fatalf("LoadFiles failed: %v", err)
+	}
+	edited := map[string]string{name: "after\n"}
+	if _, err := backups.Save("change it", files, edited); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(dir, "backups"))
+	if len(entries) != 1 {
+		t.Fatalf("Expected one backup file, got %d", len(entries))
+	}
+	if info, _ := entries[0].Info(); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a private backup file, got %v", info.Mode())
+	}
+
+	if err := WriteFiles(edited); err != nil {
+		t.Fatalf("WriteFiles failed: %v", err)
+	}
+
+	// A file changed after the edit is not overwritten
+	os.WriteFile(name, []byte("changed by hand\n"), 0600)
+	if _, err := backups.Undo(); !errors.Is(err, ErrChangedSinceEdit) {
+		t.Errorf("Expected ErrChangedSinceEdit, got %v", err)
+	}
+
+	os.WriteFile(name, []byte("after\n"), 0600)
+	backup, err := backups.Undo()
+	if err != nil || backup.Instruction != "change it" {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	data, _ := os.ReadFile(name)
+	info, _ := os.Stat(name)
+	if string(data) != "before\n" || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the file to be restored with its mode, got %q %v", data, info.Mode())
+	}
+	if _, err := backups.Latest(); !errors.Is(err, ErrNoBackup) {
+		t.Errorf("Expected the backup to be removed, got %v", err)
+	}
+	if !strings.HasPrefix(backup.Files[0].Path, dir) {
+		t.Errorf("Expected an absolute path, got %s", backup.Files[0].Path)
+	}
+}
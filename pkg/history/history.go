@@ -1,29 +1,65 @@
 package history
 
 import (
-	"encoding/json"
 	"errors"
+	"log/slog"
 	"math/rand"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 )
 
+// SessionVersion is the current session file format version
+//
+// Version history:
+//   - 0: messages have role, content and timestamp only
+//   - 1: assistant messages record how they were generated
+//   - 2: assistant messages list the tools called for them
+const SessionVersion = 2
+
 // Message represents a single message in the conversation
 type Message struct {
 	Role      string    `json:"role"`      // "user" or "assistant"
 	Content   string    `json:"content"`   // message content
 	Timestamp time.Time `json:"timestamp"` // when the message was sent
+
+	// Generation describes how an assistant message was produced, nil when unknown
+	Generation *Generation `json:"generation,omitempty"`
+	// ToolCalls are the tools the model called before giving an assistant message
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+}
+
+// ToolCall records a tool called by the model and its result
+type ToolCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments,omitempty"`
+	Output    string `json:"output,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Generation records the model, options and usage behind an assistant message
+type Generation struct {
+	Model            string   `json:"model,omitempty"`
+	Provider         string   `json:"provider,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	PromptTokens     int      `json:"prompt_tokens,omitempty"`
+	CompletionTokens int      `json:"completion_tokens,omitempty"`
+	TotalTokens      int      `json:"total_tokens,omitempty"`
+	LatencyMS        int64    `json:"latency_ms,omitempty"`
+	FinishReason     string   `json:"finish_reason,omitempty"`
+	Cached           bool     `json:"cached,omitempty"` // answered from the response cache
 }
 
 // Session represents a conversation session
 type Session struct {
-	ID        string    `json:"id"`         // unique session ID
-	Messages  []Message `json:"messages"`   // messages in this session
-	CreatedAt time.Time `json:"created_at"` // when the session was created
-	UpdatedAt time.Time `json:"updated_at"` // when the session was last updated
+	Version   int       `json:"version,omitempty"`    // session format version
+	ID        string    `json:"id"`                   // unique session ID
+	Messages  []Message `json:"messages"`             // messages in this session
+	CreatedAt time.Time `json:"created_at"`           // when the session was created
+	UpdatedAt time.Time `json:"updated_at"`           // when the session was last updated
+	WorkDir   string    `json:"work_dir,omitempty"`   // git root or directory the session was started in
+	GitBranch string    `json:"git_branch,omitempty"` // git branch checked out when the session was last used
 }
 
 // SessionInfo contains basic information about a session
@@ -33,12 +69,37 @@ type SessionInfo struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 	Preview      string    `json:"preview"`
 	MessageCount int       `json:"message_count"`
+	WorkDir      string    `json:"work_dir,omitempty"`
+	GitBranch    string    `json:"git_branch,omitempty"`
 }
 
 // Manager handles conversation history
 type Manager struct {
 	currentSession *Session
-	storagePath    string
+	store          Store
+
+	workDir      string // workspace new sessions are recorded in
+	gitBranch    string // branch checked out in the workspace
+	perWorkspace bool   // keep one active session per workspace
+}
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithWorkspace records the workspace directory and git branch in the sessions
+func WithWorkspace(dir, branch string) Option {
+	return func(m *Manager) {
+		m.workDir = dir
+		m.gitBranch = branch
+	}
+}
+
+// WithSessionPerWorkspace keeps a separate active session for each workspace
+// instead of one shared everywhere. Requires WithWorkspace.
+func WithSessionPerWorkspace() Option {
+	return func(m *Manager) {
+		m.perWorkspace = true
+	}
 }
 
 // IsEmpty checks if the current session has any messages
@@ -46,56 +107,170 @@ func (m *Manager) IsEmpty() bool {
 	return m.currentSession == nil || len(m.currentSession.Messages) == 0
 }
 
-// NewManager creates a new history manager
-func NewManager(storagePath string) (*Manager, error) {
+// NewManager creates a new history manager using the store found in storagePath.
+// JSON files are used unless the history has been migrated to SQLite.
+func NewManager(storagePath string, opts ...Option) (*Manager, error) {
 	// Create storage directory if it doesn't exist
 	if err := os.MkdirAll(storagePath, 0755); err != nil {
 		return nil, err
 	}
 
-	// Create sessions directory if it doesn't exist
-	sessionsPath := filepath.Join(storagePath, "sessions")
-	if err := os.MkdirAll(sessionsPath, 0755); err != nil {
+	store, err := OpenStore(storagePath, DetectStore(storagePath))
+	if err != nil {
 		return nil, err
 	}
 
+	return NewManagerWithStore(store, opts...), nil
+}
+
+// NewManagerWithStore creates a new history manager backed by store
+func NewManagerWithStore(store Store, opts ...Option) *Manager {
 	manager := &Manager{
-		storagePath: storagePath,
+		store: store,
+	}
+	for _, opt := range opts {
+		opt(manager)
 	}
 
 	// Try to load the current session
-	if err := manager.loadCurrentSession(); err != nil {
+	session, err := store.LoadCurrent(manager.scope())
+	if err != nil && manager.perWorkspace {
+		// Pick up the latest session started in this workspace
+		session, err = manager.latestWorkspaceSession()
+	}
+	if err != nil {
 		// If there's no current session, create a new one
 		manager.New()
+	} else {
+		manager.currentSession = session
 	}
 
-	return manager, nil
+	return manager
+}
+
+// scope returns the key the active session is stored under
+func (m *Manager) scope() string {
+	if m.perWorkspace {
+		return m.workDir
+	}
+	return ""
+}
+
+// latestWorkspaceSession loads the most recently updated session of the workspace
+// and makes it the active one
+func (m *Manager) latestWorkspaceSession() (*Session, error) {
+	sessions, err := m.ListWorkspaceSessions()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	session, err := m.store.LoadSession(sessions[0].ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.store.SetCurrent(m.scope(), session); err != nil {
+		slog.Warn("failed to save current session", "error", err)
+	}
+	return session, nil
+}
+
+// WorkDir returns the workspace directory of the manager
+func (m *Manager) WorkDir() string {
+	return m.workDir
+}
+
+// Store returns the store the history is kept in
+func (m *Manager) Store() Store {
+	return m.store
+}
+
+// Close releases the store
+func (m *Manager) Close() error {
+	return m.store.Close()
 }
 
 // AddUserMessage adds a user message to the current session
 func (m *Manager) AddUserMessage(content string) {
-	m.currentSession.Messages = append(m.currentSession.Messages, Message{
+	m.appendMessage(Message{
 		Role:      "user",
 		Content:   content,
 		Timestamp: time.Now(),
 	})
-	m.currentSession.UpdatedAt = time.Now()
-	m.saveCurrentSession()
-	// Also save to sessions directory
-	m.saveSessionToFile(m.currentSession)
 }
 
 // AddAssistantMessage adds an assistant message to the current session
 func (m *Manager) AddAssistantMessage(content string) {
-	m.currentSession.Messages = append(m.currentSession.Messages, Message{
-		Role:      "assistant",
-		Content:   content,
-		Timestamp: time.Now(),
+	m.AddAssistantReply(content, nil)
+}
+
+// AddAssistantReply adds an assistant message together with how it was generated
+func (m *Manager) AddAssistantReply(content string, generation *Generation) {
+	m.appendMessage(Message{
+		Role:       "assistant",
+		Content:    content,
+		Timestamp:  time.Now(),
+		Generation: generation,
+	})
+}
+
+// AddAssistantReplyWithTools adds an assistant message together with how it was
+// generated and the tools called for it
+func (m *Manager) AddAssistantReplyWithTools(content string, generation *Generation, toolCalls []ToolCall) {
+	m.appendMessage(Message{
+		Role:       "assistant",
+		Content:    content,
+		Timestamp:  time.Now(),
+		Generation: generation,
+		ToolCalls:  toolCalls,
+	})
+}
+
+// appendMessage adds a message to the current session and saves it
+func (m *Manager) appendMessage(msg Message) {
+	// Track the branch the session's workspace is on
+	if m.workDir != "" && m.currentSession.WorkDir == m.workDir {
+		m.currentSession.GitBranch = m.gitBranch
+	}
+
+	if err := m.store.AppendMessages(m.currentSession, msg); err != nil {
+		slog.Warn("failed to save session", "session", m.currentSession.ID, "error", err)
+	}
+}
+
+// GetSession returns a session by ID
+func (m *Manager) GetSession(sessionID string) (*Session, error) {
+	return m.store.LoadSession(sessionID)
+}
+
+// mergeMessages returns the union of two message lists ordered by timestamp
+func mergeMessages(a, b []Message) []Message {
+	type messageKey struct {
+		timestamp int64
+		role      string
+		content   string
+	}
+
+	seen := make(map[messageKey]bool, len(a)+len(b))
+	merged := make([]Message, 0, len(a)+len(b))
+	for _, list := range [][]Message{a, b} {
+		for _, msg := range list {
+			key := messageKey{msg.Timestamp.UnixNano(), msg.Role, msg.Content}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged = append(merged, msg)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Timestamp.Before(merged[j].Timestamp)
 	})
-	m.currentSession.UpdatedAt = time.Now()
-	m.saveCurrentSession()
-	// Also save to sessions directory
-	m.saveSessionToFile(m.currentSession)
+
+	return merged
 }
 
 // GetMessages returns all messages in the current session
@@ -106,75 +281,55 @@ func (m *Manager) GetMessages() []Message {
 // New creates a new session
 func (m *Manager) New() {
 	m.currentSession = &Session{
+		Version:   SessionVersion,
 		ID:        generateSessionID(),
 		Messages:  []Message{},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
+		WorkDir:   m.workDir,
+		GitBranch: m.gitBranch,
+	}
+
+	if err := m.store.SetCurrent(m.scope(), m.currentSession); err != nil {
+		slog.Warn("failed to save current session", "error", err)
 	}
-	m.saveCurrentSession()
 }
 
 // ListSessions returns a list of all available sessions
 func (m *Manager) ListSessions() ([]SessionInfo, error) {
-	sessionsPath := filepath.Join(m.storagePath, "sessions")
-	files, err := os.ReadDir(sessionsPath)
+	return m.store.ListSessions()
+}
+
+// ListWorkspaceSessions returns the sessions started in the manager's workspace
+func (m *Manager) ListWorkspaceSessions() ([]SessionInfo, error) {
+	sessions, err := m.store.ListSessions()
 	if err != nil {
 		return nil, err
 	}
 
-	var sessions []SessionInfo
-	for _, file := range files {
-		if !file.IsDir() && strings.HasSuffix(file.Name(), ".json") {
-			sessionID := strings.TrimSuffix(file.Name(), ".json")
-			session, err := m.loadSessionFromFile(sessionID)
-			if err != nil {
-				continue // Skip sessions that can't be loaded
-			}
-
-			// Create a preview from the first user message
-			preview := ""
-			messageCount := len(session.Messages)
-			if messageCount > 0 {
-				for _, msg := range session.Messages {
-					if msg.Role == "user" {
-						// Truncate long messages
-						if len(msg.Content) > 50 {
-							preview = msg.Content[:50] + "..."
-						} else {
-							preview = msg.Content
-						}
-						break
-					}
-				}
-			}
-
-			sessions = append(sessions, SessionInfo{
-				ID:           session.ID,
-				CreatedAt:    session.CreatedAt,
-				UpdatedAt:    session.UpdatedAt,
-				Preview:      preview,
-				MessageCount: messageCount,
-			})
+	var here []SessionInfo
+	for _, session := range sessions {
+		if session.WorkDir != "" && session.WorkDir == m.workDir {
+			here = append(here, session)
 		}
 	}
+	return here, nil
+}
 
-	// Sort sessions by UpdatedAt (most recent first)
-	sort.Slice(sessions, func(i, j int) bool {
-		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
-	})
-
-	return sessions, nil
+// SearchSessions returns the sessions with a message matching the query
+func (m *Manager) SearchSessions(query string) ([]SessionInfo, error) {
+	return m.store.SearchSessions(query)
 }
 
 // SwitchSession switches to a different session
 func (m *Manager) SwitchSession(sessionID string) error {
-	session, err := m.loadSessionFromFile(sessionID)
+	session, err := m.store.LoadSession(sessionID)
 	if err != nil {
 		return err
 	}
 
 	m.currentSession = session
-	return m.saveCurrentSession()
+	return m.store.SetCurrent(m.scope(), session)
 }
 
 // DeleteSession deletes a session
@@ -184,8 +339,7 @@ func (m *Manager) DeleteSession(sessionID string) error {
 		return errors.New("cannot delete the current session")
 	}
 
-	sessionPath := filepath.Join(m.storagePath, "sessions", sessionID+".json")
-	return os.Remove(sessionPath)
+	return m.store.DeleteSession(sessionID)
 }
 
 // GetCurrentSessionID returns the ID of the current session
@@ -196,54 +350,17 @@ func (m *Manager) GetCurrentSessionID() string {
 	return m.currentSession.ID
 }
 
-// Internal methods for saving and loading sessions
-func (m *Manager) saveCurrentSession() error {
-	sessionPath := filepath.Join(m.storagePath, "current_session.json")
-	data, err := json.MarshalIndent(m.currentSession, "", "  ")
-	if err != nil {
-		return err
+// migrateSession upgrades a session read from an older file to the current version
+func migrateSession(session *Session) {
+	if session.Version < 1 {
+		// Messages written before version 1 have no generation details, which
+		// is what a nil Generation means, so only the version changes
+		session.Version = 1
 	}
-	return os.WriteFile(sessionPath, data, 0644)
-}
-
-func (m *Manager) loadCurrentSession() error {
-	sessionPath := filepath.Join(m.storagePath, "current_session.json")
-	data, err := os.ReadFile(sessionPath)
-	if err != nil {
-		return err
+	if session.Version < 2 {
+		// Older messages called no tools
+		session.Version = 2
 	}
-
-	session := &Session{}
-	if err := json.Unmarshal(data, session); err != nil {
-		return err
-	}
-
-	m.currentSession = session
-	return nil
-}
-
-func (m *Manager) saveSessionToFile(session *Session) error {
-	sessionPath := filepath.Join(m.storagePath, "sessions", session.ID+".json")
-	data, err := json.MarshalIndent(session, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(sessionPath, data, 0644)
-}
-
-func (m *Manager) loadSessionFromFile(sessionID string) (*Session, error) {
-	sessionPath := filepath.Join(m.storagePath, "sessions", sessionID+".json")
-	data, err := os.ReadFile(sessionPath)
-	if err != nil {
-		return nil, err
-	}
-
-	session := &Session{}
-	if err := json.Unmarshal(data, session); err != nil {
-		return nil, err
-	}
-
-	return session, nil
 }
 
 // Helper function to generate a unique session ID
@@ -0,0 +1,172 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestManager_ConcurrentWriters(t *testing.T) {
+	storagePath := t.TempDir()
+
+	// Create the shared session
+	first, err := NewManager(storagePath)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	sessionID := first.GetCurrentSessionID()
+
+	const writers = 8
+	const messagesPerWriter = 20
+
+	// Each manager stands for a separate process using the same session
+	managers := make([]*Manager, writers)
+	for i := range managers {
+		managers[i], err = NewManager(storagePath)
+		if err != nil {
+			t.Fatalf("NewManager failed: %v", err)
+		}
+		if managers[i].GetCurrentSessionID() != sessionID {
+			t.Fatalf("Expected all managers to share session %s", sessionID)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, manager := range managers {
+		wg.Add(1)
+		go func(writer int, manager *Manager) {
+			defer wg.Done()
+			for j := 0; j < messagesPerWriter; j++ {
+				manager.AddUserMessage(fmt.Sprintf("writer %d message %d", writer, j))
+			}
+		}(i, manager)
+	}
+	wg.Wait()
+
+	session, err := first.Store().LoadSession(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to load session: %v", err)
+	}
+	if len(session.Messages) != writers*messagesPerWriter {
+		t.Errorf("Expected %d messages, got %d", writers*messagesPerWriter, len(session.Messages))
+	}
+
+	seen := make(map[string]bool)
+	for _, msg := range session.Messages {
+		seen[msg.Content] = true
+	}
+	for i := 0; i < writers; i++ {
+		for j := 0; j < messagesPerWriter; j++ {
+			if content := fmt.Sprintf("writer %d message %d", i, j); !seen[content] {
+				t.Errorf("Missing message %q", content)
+			}
+		}
+	}
+
+	// No temporary files are left behind
+	files, _ := os.ReadDir(filepath.Join(storagePath, "sessions"))
+	if len(files) != 1 {
+		t.Errorf("Expected only the session file, got %d files", len(files))
+	}
+}
+
+func TestMergeMessages(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	manager.AddUserMessage("a")
+	manager.AddAssistantMessage("b")
+
+	disk := manager.GetMessages()
+	merged := mergeMessages(disk, disk)
+	if len(merged) != 2 {
+		t.Errorf("Expected duplicates to be removed, got %d messages", len(merged))
+	}
+}
+
+func TestManager_Generation(t *testing.T) {
+	storagePath := t.TempDir()
+
+	// A session written before messages recorded their generation
+	old := `{
+  "id": "20240101-120000-abcdef",
+  "messages": [
+    {"role": "user", "content": "hello", "timestamp": "2024-01-01T12:00:00Z"},
+    {"role": "assistant", "content": "hi", "timestamp": "2024-01-01T12:00:01Z"}
+  ],
+  "created_at": "2024-01-01T12:00:00Z",
+  "updated_at": "2024-01-01T12:00:01Z"
+}`
+	if err := os.MkdirAll(filepath.Join(storagePath, "sessions"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"current_session.json", "sessions/20240101-120000-abcdef.json"} {
+		if err := os.WriteFile(filepath.Join(storagePath, path), []byte(old), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager, err := NewManager(storagePath)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if manager.GetCurrentSessionID() != "20240101-120000-abcdef" {
+		t.Fatalf("Expected the old session to be loaded, got %s", manager.GetCurrentSessionID())
+	}
+
+	temperature := 0.7
+	manager.AddUserMessage("how much did that cost?")
+	manager.AddAssistantReply("not much", &Generation{
+		Model:            "gpt-4o",
+		Provider:         "openai",
+		Temperature:      &temperature,
+		PromptTokens:     10,
+		CompletionTokens: 2,
+		TotalTokens:      12,
+		LatencyMS:        350,
+		FinishReason:     "stop",
+	})
+
+	session, err := manager.GetSession("20240101-120000-abcdef")
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if session.Version != SessionVersion {
+		t.Errorf("Expected session to be migrated to version %d, got %d", SessionVersion, session.Version)
+	}
+	if len(session.Messages) != 4 {
+		t.Fatalf("Expected 4 messages, got %d", len(session.Messages))
+	}
+	if session.Messages[1].Generation != nil {
+		t.Errorf("Expected old messages to have no generation details")
+	}
+	generation := session.Messages[3].Generation
+	if generation == nil || generation.Model != "gpt-4o" || generation.TotalTokens != 12 || *generation.Temperature != 0.7 {
+		t.Errorf("Expected generation details to be stored, got %+v", generation)
+	}
+}
+
+func TestManager_ToolCalls(t *testing.T) {
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	manager.AddUserMessage("what does main.go do?")
+	manager.AddAssistantReplyWithTools("it starts the server", &Generation{Model: "gpt-4o"}, []ToolCall{
+		{Name: "read_file", Arguments: `{"path":"main.go"}`, Output: "package main"},
+		{Name: "run_command", Arguments: `{"command":"rm -rf /"}`, Error: "declined by the user"},
+	})
+
+	session, err := manager.GetSession(manager.GetCurrentSessionID())
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	calls := session.Messages[1].ToolCalls
+	if len(calls) != 2 || calls[0].Output != "package main" || calls[1].Error != "declined by the user" {
+		t.Errorf("Expected the tool calls to be stored, got %+v", calls)
+	}
+}
@@ -0,0 +1,212 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pokitpeng/ai/pkg/util"
+)
+
+// JSONStore keeps each session in its own JSON file, with a copy of the
+// active session in current_session.json
+type JSONStore struct {
+	storagePath string
+}
+
+// NewJSONStore creates a JSON store in the history directory
+func NewJSONStore(storagePath string) (*JSONStore, error) {
+	// Create the sessions directory if it doesn't exist
+	if err := os.MkdirAll(filepath.Join(storagePath, "sessions"), 0755); err != nil {
+		return nil, err
+	}
+
+	return &JSONStore{storagePath: storagePath}, nil
+}
+
+// LoadCurrent reads current_session.json, or the file of a workspace scope in current/.
+// The session file is preferred when it exists, as it has the latest messages.
+func (s *JSONStore) LoadCurrent(scope string) (*Session, error) {
+	current, err := readSessionFile(s.currentPath(scope))
+	if err != nil {
+		return nil, err
+	}
+
+	if session, err := s.LoadSession(current.ID); err == nil {
+		return session, nil
+	}
+	return current, nil
+}
+
+// SetCurrent writes current_session.json, or the file of a workspace scope
+func (s *JSONStore) SetCurrent(scope string, session *Session) error {
+	unlock, err := s.lock()
+	if err == nil {
+		defer unlock()
+	}
+
+	path := s.currentPath(scope)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeSessionFile(path, session)
+}
+
+// LoadSession reads a session file
+func (s *JSONStore) LoadSession(sessionID string) (*Session, error) {
+	return readSessionFile(s.sessionPath(sessionID))
+}
+
+// SaveSession writes a session file
+func (s *JSONStore) SaveSession(session *Session) error {
+	unlock, err := s.lock()
+	if err == nil {
+		defer unlock()
+	}
+	return writeSessionFile(s.sessionPath(session.ID), session)
+}
+
+// AppendMessages adds messages to a session and saves it.
+// Messages written to the same session by other processes are merged in, not overwritten.
+func (s *JSONStore) AppendMessages(session *Session, messages ...Message) error {
+	unlock, err := s.lock()
+	if err != nil {
+		slog.Warn("failed to lock history, writing without lock", "error", err)
+	} else {
+		defer unlock()
+	}
+
+	// Merge with the copy on disk so messages written by other processes are kept
+	if onDisk, err := s.LoadSession(session.ID); err == nil {
+		session.Messages = mergeMessages(onDisk.Messages, session.Messages)
+	}
+
+	session.Messages = append(session.Messages, messages...)
+	session.UpdatedAt = time.Now()
+
+	// Keep current_session.json in sync unless another process switched sessions
+	if current, err := readSessionFile(s.currentPath("")); err == nil && current.ID == session.ID {
+		if err := s.saveCurrentSession(session); err != nil {
+			slog.Warn("failed to save current session", "error", err)
+		}
+	}
+
+	return writeSessionFile(s.sessionPath(session.ID), session)
+}
+
+// ListSessions loads every session file to build the session list
+func (s *JSONStore) ListSessions() ([]SessionInfo, error) {
+	return s.findSessions(func(*Session) bool { return true })
+}
+
+// SearchSessions scans every session file for messages containing the query, ignoring case
+func (s *JSONStore) SearchSessions(query string) ([]SessionInfo, error) {
+	query = strings.ToLower(query)
+	return s.findSessions(func(session *Session) bool {
+		for _, msg := range session.Messages {
+			if strings.Contains(strings.ToLower(msg.Content), query) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// DeleteSession removes a session file
+func (s *JSONStore) DeleteSession(sessionID string) error {
+	unlock, err := s.lock()
+	if err == nil {
+		defer unlock()
+	}
+	return os.Remove(s.sessionPath(sessionID))
+}
+
+// Close does nothing, files are not kept open
+func (s *JSONStore) Close() error {
+	return nil
+}
+
+// findSessions returns the sessions accepted by match, most recently updated first
+func (s *JSONStore) findSessions(match func(*Session) bool) ([]SessionInfo, error) {
+	files, err := os.ReadDir(filepath.Join(s.storagePath, "sessions"))
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []SessionInfo
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+
+		sessionID := strings.TrimSuffix(file.Name(), ".json")
+		session, err := s.LoadSession(sessionID)
+		if err != nil {
+			// Skip sessions that can't be loaded, but let the user know
+			slog.Warn("skipping unreadable session", "session", sessionID, "error", err)
+			continue
+		}
+
+		if match(session) {
+			sessions = append(sessions, newSessionInfo(session))
+		}
+	}
+
+	// Sort sessions by UpdatedAt (most recent first)
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+
+	return sessions, nil
+}
+
+// lock takes the advisory lock that serializes writes between processes
+func (s *JSONStore) lock() (func(), error) {
+	return util.LockFile(filepath.Join(s.storagePath, ".lock"))
+}
+
+func (s *JSONStore) sessionPath(sessionID string) string {
+	return filepath.Join(s.storagePath, "sessions", sessionID+".json")
+}
+
+// currentPath returns the file recording the current session of a scope
+func (s *JSONStore) currentPath(scope string) string {
+	if scope == "" {
+		return filepath.Join(s.storagePath, "current_session.json")
+	}
+	hash := sha256.Sum256([]byte(scope))
+	return filepath.Join(s.storagePath, "current", hex.EncodeToString(hash[:8])+".json")
+}
+
+func (s *JSONStore) saveCurrentSession(session *Session) error {
+	return writeSessionFile(s.currentPath(""), session)
+}
+
+func writeSessionFile(path string, session *Session) error {
+	data, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteFileAtomic(path, data, 0644)
+}
+
+func readSessionFile(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{}
+	if err := json.Unmarshal(data, session); err != nil {
+		return nil, err
+	}
+	migrateSession(session)
+
+	return session, nil
+}
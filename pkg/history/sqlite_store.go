@@ -0,0 +1,328 @@
+package history
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// Pure-Go SQLite driver, registered as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the tables used by SQLiteStore.
+//
+// The data columns hold the JSON encoding of the session (without messages) and of
+// each message, so fields added to Session and Message need no schema change.
+// The other columns are copies used for listing, ordering and searching.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS sessions (
+	id            TEXT PRIMARY KEY,
+	created_at    INTEGER NOT NULL,
+	updated_at    INTEGER NOT NULL,
+	preview       TEXT NOT NULL DEFAULT '',
+	message_count INTEGER NOT NULL DEFAULT 0,
+	data          TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_updated_at ON sessions(updated_at);
+
+CREATE TABLE IF NOT EXISTS messages (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id TEXT NOT NULL,
+	timestamp  INTEGER NOT NULL,
+	content    TEXT NOT NULL,
+	data       TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS messages_session ON messages(session_id, timestamp);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(content, content='messages', content_rowid='id');
+CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages BEGIN
+	INSERT INTO messages_fts(rowid, content) VALUES (new.id, new.content);
+END;
+CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
+	INSERT INTO messages_fts(messages_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+
+CREATE TABLE IF NOT EXISTS state (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+`
+
+// sessionInfoColumns selects the fields of a SessionInfo from the sessions table
+const sessionInfoColumns = `id, created_at, updated_at, preview, message_count,
+	COALESCE(json_extract(data, '$.work_dir'), ''), COALESCE(json_extract(data, '$.git_branch'), '')`
+
+// SQLiteStore keeps sessions in a SQLite database. Appending a message inserts a
+// single row, and listing sessions reads only the sessions table.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens or creates the SQLite database at path
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	// Wait for other processes instead of failing with "database is locked"
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	// A single connection serializes writes within the process
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history database: %w", err)
+	}
+
+	return &SQLiteStore{db: db}, nil
+}
+
+// LoadCurrent returns the session recorded as current in a scope
+func (s *SQLiteStore) LoadCurrent(scope string) (*Session, error) {
+	var sessionID string
+	err := s.db.QueryRow(`SELECT value FROM state WHERE key = ?`, currentKey(scope)).Scan(&sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, os.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.LoadSession(sessionID)
+}
+
+// SetCurrent records the session as current in a scope, creating it if needed
+func (s *SQLiteStore) SetCurrent(scope string, session *Session) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if err := insertSession(tx, session); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`INSERT INTO state (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value`, currentKey(scope), session.ID)
+		return err
+	})
+}
+
+// LoadSession reads a session and its messages
+func (s *SQLiteStore) LoadSession(sessionID string) (*Session, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM sessions WHERE id = ?`, sessionID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("session %s: %w", sessionID, os.ErrNotExist)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{}
+	if err := json.Unmarshal([]byte(data), session); err != nil {
+		return nil, err
+	}
+
+	session.Messages, err = s.loadMessages(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	migrateSession(session)
+
+	return session, nil
+}
+
+// SaveSession replaces a session and all of its messages
+func (s *SQLiteStore) SaveSession(session *Session) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM messages WHERE session_id = ?`, session.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM sessions WHERE id = ?`, session.ID); err != nil {
+			return err
+		}
+		if err := insertSession(tx, session); err != nil {
+			return err
+		}
+		return appendRows(tx, session, session.Messages)
+	})
+}
+
+// AppendMessages inserts the messages and reloads the session's messages,
+// which picks up messages written by other processes
+func (s *SQLiteStore) AppendMessages(session *Session, messages ...Message) error {
+	session.UpdatedAt = time.Now()
+
+	err := s.inTx(func(tx *sql.Tx) error {
+		if err := insertSession(tx, session); err != nil {
+			return err
+		}
+		return appendRows(tx, session, messages)
+	})
+	if err != nil {
+		return err
+	}
+
+	stored, err := s.loadMessages(session.ID)
+	if err != nil {
+		return err
+	}
+	session.Messages = stored
+	return nil
+}
+
+// ListSessions returns the sessions that have messages, most recently updated first
+func (s *SQLiteStore) ListSessions() ([]SessionInfo, error) {
+	return s.querySessions(`SELECT ` + sessionInfoColumns + `
+		FROM sessions WHERE message_count > 0 ORDER BY updated_at DESC`)
+}
+
+// SearchSessions uses the full-text index to find sessions. Every word of the
+// query must appear in the same message.
+func (s *SQLiteStore) SearchSessions(query string) ([]SessionInfo, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	return s.querySessions(`SELECT `+sessionInfoColumns+`
+		FROM sessions WHERE id IN (
+			SELECT m.session_id FROM messages_fts f JOIN messages m ON m.id = f.rowid
+			WHERE messages_fts MATCH ?
+		) ORDER BY updated_at DESC`, match)
+}
+
+// DeleteSession removes a session and its messages
+func (s *SQLiteStore) DeleteSession(sessionID string) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`DELETE FROM sessions WHERE id = ?`, sessionID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("session %s: %w", sessionID, os.ErrNotExist)
+		}
+		_, err = tx.Exec(`DELETE FROM messages WHERE session_id = ?`, sessionID)
+		return err
+	})
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// inTx runs fn in a transaction, committing when it succeeds
+func (s *SQLiteStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) loadMessages(sessionID string) ([]Message, error) {
+	rows, err := s.db.Query(`SELECT data FROM messages WHERE session_id = ? ORDER BY timestamp, id`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var msg Message
+		if err := json.Unmarshal([]byte(data), &msg); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
+
+func (s *SQLiteStore) querySessions(query string, args ...any) ([]SessionInfo, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []SessionInfo
+	for rows.Next() {
+		var info SessionInfo
+		var createdAt, updatedAt int64
+		if err := rows.Scan(&info.ID, &createdAt, &updatedAt, &info.Preview, &info.MessageCount,
+			&info.WorkDir, &info.GitBranch); err != nil {
+			return nil, err
+		}
+		info.CreatedAt = time.Unix(0, createdAt)
+		info.UpdatedAt = time.Unix(0, updatedAt)
+		sessions = append(sessions, info)
+	}
+	return sessions, rows.Err()
+}
+
+// insertSession stores the session metadata. An existing session keeps its
+// messages, only its metadata is updated.
+func insertSession(tx *sql.Tx, session *Session) error {
+	metadata := *session
+	metadata.Messages = nil
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO sessions (id, created_at, updated_at, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET updated_at = MAX(updated_at, excluded.updated_at), data = excluded.data`,
+		session.ID, session.CreatedAt.UnixNano(), session.UpdatedAt.UnixNano(), string(data))
+	return err
+}
+
+// appendRows inserts messages and refreshes the session's preview and message count
+func appendRows(tx *sql.Tx, session *Session, messages []Message) error {
+	for _, msg := range messages {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO messages (session_id, timestamp, content, data) VALUES (?, ?, ?, ?)`,
+			session.ID, msg.Timestamp.UnixNano(), msg.Content, string(data)); err != nil {
+			return err
+		}
+	}
+
+	_, err := tx.Exec(`UPDATE sessions SET
+		message_count = (SELECT COUNT(*) FROM messages WHERE session_id = ?1),
+		preview = CASE WHEN preview = '' THEN ?2 ELSE preview END
+		WHERE id = ?1`, session.ID, sessionPreview(messages))
+	return err
+}
+
+// currentKey returns the state key recording the current session of a scope
+func currentKey(scope string) string {
+	if scope == "" {
+		return "current_session"
+	}
+	return "current_session:" + scope
+}
+
+// ftsQuery turns free text into an FTS5 query matching all of its words
+func ftsQuery(query string) string {
+	words := strings.Fields(query)
+	for i, word := range words {
+		// Quote each word so FTS5 operators and punctuation are taken literally
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,171 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Store types accepted by OpenStore and Migrate
+const (
+	StoreJSON   = "json"
+	StoreSQLite = "sqlite"
+)
+
+// Store persists conversation sessions
+type Store interface {
+	// LoadCurrent returns the session that is currently active in a scope.
+	// The empty scope is the global one, other scopes are workspace directories.
+	LoadCurrent(scope string) (*Session, error)
+	// SetCurrent makes a session the active one in a scope
+	SetCurrent(scope string, session *Session) error
+	// LoadSession returns the session with the given ID
+	LoadSession(sessionID string) (*Session, error)
+	// SaveSession stores a whole session, replacing any existing copy
+	SaveSession(session *Session) error
+	// AppendMessages adds messages to a session. The session is updated in place
+	// with messages written by other processes in the meantime.
+	AppendMessages(session *Session, messages ...Message) error
+	// ListSessions returns all sessions, most recently updated first
+	ListSessions() ([]SessionInfo, error)
+	// SearchSessions returns the sessions with a message matching the query,
+	// most recently updated first
+	SearchSessions(query string) ([]SessionInfo, error)
+	// DeleteSession removes a session
+	DeleteSession(sessionID string) error
+	// Close releases the resources held by the store
+	Close() error
+}
+
+// sqliteFile is the name of the SQLite database inside the history directory
+const sqliteFile = "history.db"
+
+// DetectStore returns the type of store in use in the history directory.
+// A SQLite database takes precedence over JSON files.
+func DetectStore(storagePath string) string {
+	if _, err := os.Stat(filepath.Join(storagePath, sqliteFile)); err == nil {
+		return StoreSQLite
+	}
+	return StoreJSON
+}
+
+// OpenStore opens a store of the given type in the history directory
+func OpenStore(storagePath, storeType string) (Store, error) {
+	switch storeType {
+	case StoreJSON:
+		return NewJSONStore(storagePath)
+	case StoreSQLite:
+		return NewSQLiteStore(filepath.Join(storagePath, sqliteFile))
+	default:
+		return nil, fmt.Errorf("unknown history store %q, expected %s or %s", storeType, StoreJSON, StoreSQLite)
+	}
+}
+
+// Migrate copies all sessions in the history directory to a store of the given type
+// and returns the number of sessions copied. After migrating to JSON the SQLite
+// database is renamed to history.db.bak so the JSON files are used again.
+func Migrate(storagePath, to string) (int, error) {
+	from := DetectStore(storagePath)
+	if from == to {
+		return 0, fmt.Errorf("history is already stored as %s", to)
+	}
+
+	src, err := OpenStore(storagePath, from)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dst, err := OpenStore(storagePath, to)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	count, err := copySessions(src, dst)
+	if err != nil {
+		return count, err
+	}
+
+	if to == StoreJSON {
+		dbPath := filepath.Join(storagePath, sqliteFile)
+		src.Close()
+		if err := os.Rename(dbPath, dbPath+".bak"); err != nil {
+			return count, fmt.Errorf("failed to back up %s: %w", dbPath, err)
+		}
+		// Remove the write-ahead log files left next to the database
+		os.Remove(dbPath + "-wal")
+		os.Remove(dbPath + "-shm")
+	}
+
+	return count, nil
+}
+
+// copySessions copies every session and the current session selections from src to dst:
+// the global one and those of the workspaces the sessions belong to
+func copySessions(src, dst Store) (int, error) {
+	infos, err := src.ListSessions()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	count := 0
+	for _, info := range infos {
+		session, err := src.LoadSession(info.ID)
+		if err != nil {
+			return count, fmt.Errorf("failed to load session %s: %w", info.ID, err)
+		}
+		if err := dst.SaveSession(session); err != nil {
+			return count, fmt.Errorf("failed to save session %s: %w", info.ID, err)
+		}
+		count++
+	}
+
+	// Workspace scopes are the directories of the sessions, the JSON store only keeps their hash
+	scopes := []string{""}
+	seen := map[string]bool{"": true}
+	for _, info := range infos {
+		if !seen[info.WorkDir] {
+			seen[info.WorkDir] = true
+			scopes = append(scopes, info.WorkDir)
+		}
+	}
+	for _, scope := range scopes {
+		current, err := src.LoadCurrent(scope)
+		if err != nil {
+			continue
+		}
+		if err := dst.SetCurrent(scope, current); err != nil {
+			return count, fmt.Errorf("failed to set current session: %w", err)
+		}
+	}
+
+	return count, nil
+}
+
+// newSessionInfo summarizes a session, using the first user message as preview
+func newSessionInfo(session *Session) SessionInfo {
+	return SessionInfo{
+		ID:           session.ID,
+		CreatedAt:    session.CreatedAt,
+		UpdatedAt:    session.UpdatedAt,
+		Preview:      sessionPreview(session.Messages),
+		MessageCount: len(session.Messages),
+		WorkDir:      session.WorkDir,
+		GitBranch:    session.GitBranch,
+	}
+}
+
+// sessionPreview returns the first user message, truncated to 50 bytes
+func sessionPreview(messages []Message) string {
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			// Truncate long messages
+			if len(msg.Content) > 50 {
+				return msg.Content[:50] + "..."
+			}
+			return msg.Content
+		}
+	}
+	return ""
+}
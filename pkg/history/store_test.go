@@ -0,0 +1,226 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSQLiteStore_ConcurrentWriters(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "history.db")
+
+	store, err := NewSQLiteStore(dbPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	defer store.Close()
+	first := NewManagerWithStore(store)
+	sessionID := first.GetCurrentSessionID()
+
+	const writers = 4
+	const messagesPerWriter = 10
+
+	// Each manager has its own connection, like a separate process
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		store, err := NewSQLiteStore(dbPath)
+		if err != nil {
+			t.Fatalf("NewSQLiteStore failed: %v", err)
+		}
+		defer store.Close()
+		manager := NewManagerWithStore(store)
+		if manager.GetCurrentSessionID() != sessionID {
+			t.Fatalf("Expected all managers to share session %s", sessionID)
+		}
+
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for j := 0; j < messagesPerWriter; j++ {
+				manager.AddUserMessage(fmt.Sprintf("writer %d message %d", writer, j))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	session, err := store.LoadSession(sessionID)
+	if err != nil {
+		t.Fatalf("Failed to load session: %v", err)
+	}
+	if len(session.Messages) != writers*messagesPerWriter {
+		t.Errorf("Expected %d messages, got %d", writers*messagesPerWriter, len(session.Messages))
+	}
+
+	sessions, err := store.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].MessageCount != writers*messagesPerWriter {
+		t.Errorf("Expected one session with %d messages, got %+v", writers*messagesPerWriter, sessions)
+	}
+}
+
+func TestStores_Search(t *testing.T) {
+	for _, storeType := range []string{StoreJSON, StoreSQLite} {
+		t.Run(storeType, func(t *testing.T) {
+			store, err := OpenStore(t.TempDir(), storeType)
+			if err != nil {
+				t.Fatalf("OpenStore failed: %v", err)
+			}
+			defer store.Close()
+
+			manager := NewManagerWithStore(store)
+			manager.AddUserMessage("How do I reverse a linked list?")
+			manager.AddAssistantMessage("Walk the list and flip each pointer.")
+			first := manager.GetCurrentSessionID()
+
+			manager.New()
+			manager.AddUserMessage("Explain goroutines")
+
+			results, err := manager.SearchSessions("linked list")
+			if err != nil {
+				t.Fatalf("SearchSessions failed: %v", err)
+			}
+			if len(results) != 1 || results[0].ID != first {
+				t.Errorf("Expected session %s, got %+v", first, results)
+			}
+			if results[0].Preview != "How do I reverse a linked list?" {
+				t.Errorf("Unexpected preview %q", results[0].Preview)
+			}
+
+			if results, _ := manager.SearchSessions("kubernetes"); len(results) != 0 {
+				t.Errorf("Expected no results, got %+v", results)
+			}
+
+			if err := manager.DeleteSession(first); err != nil {
+				t.Fatalf("DeleteSession failed: %v", err)
+			}
+			if results, _ := manager.SearchSessions("pointer"); len(results) != 0 {
+				t.Errorf("Expected deleted session to be gone, got %+v", results)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	storagePath := t.TempDir()
+
+	manager, err := NewManager(storagePath)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	manager.AddUserMessage("first question")
+	manager.AddAssistantMessage("first answer")
+	manager.New()
+	manager.AddUserMessage("second question")
+	current := manager.GetCurrentSessionID()
+
+	// The selection of a workspace points to its older session
+	workspace := func() *Manager {
+		store, err := OpenStore(storagePath, DetectStore(storagePath))
+		if err != nil {
+			t.Fatalf("OpenStore failed: %v", err)
+		}
+		return NewManagerWithStore(store, WithWorkspace("/src/a", "main"), WithSessionPerWorkspace())
+	}
+	project := workspace()
+	project.AddUserMessage("question about a")
+	selected := project.GetCurrentSessionID()
+	project.New()
+	project.AddUserMessage("another question about a")
+	if err := project.SwitchSession(selected); err != nil {
+		t.Fatalf("SwitchSession failed: %v", err)
+	}
+	project.Close()
+
+	count, err := Migrate(storagePath, StoreSQLite)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("Expected 4 sessions to be migrated, got %d", count)
+	}
+	if DetectStore(storagePath) != StoreSQLite {
+		t.Fatalf("Expected the SQLite store to be used after migrating")
+	}
+	if _, err := Migrate(storagePath, StoreSQLite); err == nil {
+		t.Errorf("Expected error when migrating to the store in use")
+	}
+
+	migrated, err := NewManager(storagePath)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	if migrated.GetCurrentSessionID() != current {
+		t.Errorf("Expected current session %s, got %s", current, migrated.GetCurrentSessionID())
+	}
+	sessions, _ := migrated.ListSessions()
+	if len(sessions) != 4 {
+		t.Errorf("Expected 4 sessions, got %d", len(sessions))
+	}
+	migrated.Close()
+	project = workspace()
+	if project.GetCurrentSessionID() != selected {
+		t.Errorf("Expected the workspace selection %s to be migrated, got %s", selected, project.GetCurrentSessionID())
+	}
+	project.Close()
+
+	// And back again
+	if _, err := Migrate(storagePath, StoreJSON); err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if DetectStore(storagePath) != StoreJSON {
+		t.Errorf("Expected the JSON store to be used after migrating back")
+	}
+	if _, err := os.Stat(filepath.Join(storagePath, "history.db.bak")); err != nil {
+		t.Errorf("Expected the database to be kept as a backup: %v", err)
+	}
+}
+
+func TestManager_SessionPerWorkspace(t *testing.T) {
+	for _, storeType := range []string{StoreJSON, StoreSQLite} {
+		t.Run(storeType, func(t *testing.T) {
+			storagePath := t.TempDir()
+			newManager := func(opts ...Option) *Manager {
+				store, err := OpenStore(storagePath, storeType)
+				if err != nil {
+					t.Fatalf("OpenStore failed: %v", err)
+				}
+				t.Cleanup(func() { store.Close() })
+				return NewManagerWithStore(store, opts...)
+			}
+
+			projectA := newManager(WithWorkspace("/src/a", "main"), WithSessionPerWorkspace())
+			projectA.AddUserMessage("question about a")
+			projectB := newManager(WithWorkspace("/src/b", "dev"), WithSessionPerWorkspace())
+			projectB.AddUserMessage("question about b")
+
+			if projectA.GetCurrentSessionID() == projectB.GetCurrentSessionID() {
+				t.Fatalf("Expected each workspace to have its own session")
+			}
+
+			// Restarting in a workspace picks its session again
+			again := newManager(WithWorkspace("/src/a", "feature"), WithSessionPerWorkspace())
+			if again.GetCurrentSessionID() != projectA.GetCurrentSessionID() {
+				t.Errorf("Expected session %s, got %s", projectA.GetCurrentSessionID(), again.GetCurrentSessionID())
+			}
+			again.AddAssistantMessage("answer about a")
+
+			sessions, err := again.ListWorkspaceSessions()
+			if err != nil {
+				t.Fatalf("ListWorkspaceSessions failed: %v", err)
+			}
+			if len(sessions) != 1 || sessions[0].WorkDir != "/src/a" || sessions[0].GitBranch != "feature" {
+				t.Errorf("Expected the session of /src/a on branch feature, got %+v", sessions)
+			}
+
+			// The global session is not affected
+			global := newManager(WithWorkspace("/src/a", "main"))
+			if id := global.GetCurrentSessionID(); id == projectA.GetCurrentSessionID() || id == projectB.GetCurrentSessionID() {
+				t.Errorf("Expected the global session to be separate")
+			}
+		})
+	}
+}
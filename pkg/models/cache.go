@@ -0,0 +1,344 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pokitpeng/ai/pkg/util"
+)
+
+// Default response cache limits
+const (
+	DefaultCacheTTL     = 24 * time.Hour
+	DefaultCacheMaxSize = 100 // MB
+)
+
+// CacheMode controls how a request uses the response cache
+type CacheMode int
+
+const (
+	// CacheDefault answers from the cache when possible and stores new answers
+	CacheDefault CacheMode = iota
+	// CacheBypass neither reads nor writes the cache
+	CacheBypass
+	// CacheRefresh skips cached answers but stores the new one
+	CacheRefresh
+)
+
+// cacheEntry is a cached answer, stored as one JSON file per key
+type cacheEntry struct {
+	Model        string    `json:"model"`
+	Provider     string    `json:"provider"`
+	CreatedAt    time.Time `json:"created_at"`
+	Answer       string    `json:"answer"`
+	Usage        Usage     `json:"usage"`
+	FinishReason string    `json:"finish_reason,omitempty"`
+}
+
+// CacheStats describes the content of the response cache
+type CacheStats struct {
+	Entries int
+	Expired int
+	Size    int64
+	Oldest  time.Time
+	Newest  time.Time
+}
+
+// ResponseCache stores answers on disk, keyed by a hash of the request
+type ResponseCache struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+}
+
+// NewResponseCache creates a cache in dir. Entries older than ttl are ignored, and
+// the oldest entries are removed once the cache grows beyond maxSize bytes.
+// A zero ttl or maxSize uses the defaults.
+func NewResponseCache(dir string, ttl time.Duration, maxSize int64) *ResponseCache {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultCacheMaxSize << 20
+	}
+	return &ResponseCache{dir: dir, ttl: ttl, maxSize: maxSize}
+}
+
+// Dir returns the directory the cache is stored in
+func (c *ResponseCache) Dir() string {
+	return c.dir
+}
+
+// TTL returns how long entries are used
+func (c *ResponseCache) TTL() time.Duration {
+	return c.ttl
+}
+
+// MaxSize returns the size in bytes above which old entries are removed
+func (c *ResponseCache) MaxSize() int64 {
+	return c.maxSize
+}
+
+// get returns the entry stored for key, if it has not expired
+func (c *ResponseCache) get(key string) (*cacheEntry, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		slog.Warn("ignoring invalid cache entry", "key", key, "error", err)
+		return nil, false
+	}
+	if time.Since(entry.CreatedAt) > c.ttl {
+		os.Remove(c.path(key))
+		return nil, false
+	}
+	return &entry, true
+}
+
+// put stores an entry for key and trims the cache to its size limit
+func (c *ResponseCache) put(key string, entry *cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	if err := util.WriteFileAtomic(c.path(key), data, 0600); err != nil {
+		return err
+	}
+	return c.prune()
+}
+
+// prune removes expired entries, then the oldest ones until the cache fits in its size limit
+func (c *ResponseCache) prune() error {
+	files, err := c.files()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	kept := files[:0]
+	for _, file := range files {
+		if time.Since(file.ModTime()) > c.ttl {
+			os.Remove(filepath.Join(c.dir, file.Name()))
+			continue
+		}
+		size += file.Size()
+		kept = append(kept, file)
+	}
+
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].ModTime().Before(kept[j].ModTime())
+	})
+	for _, file := range kept {
+		if size <= c.maxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, file.Name())); err == nil {
+			size -= file.Size()
+		}
+	}
+	return nil
+}
+
+// Stats returns the number, age and size of the cached entries
+func (c *ResponseCache) Stats() (CacheStats, error) {
+	var stats CacheStats
+
+	files, err := c.files()
+	if err != nil {
+		return stats, err
+	}
+
+	for _, file := range files {
+		stats.Entries++
+		stats.Size += file.Size()
+
+		modTime := file.ModTime()
+		if time.Since(modTime) > c.ttl {
+			stats.Expired++
+		}
+		if stats.Oldest.IsZero() || modTime.Before(stats.Oldest) {
+			stats.Oldest = modTime
+		}
+		if modTime.After(stats.Newest) {
+			stats.Newest = modTime
+		}
+	}
+	return stats, nil
+}
+
+// Clear removes all cached entries and returns how many were removed
+func (c *ResponseCache) Clear() (int, error) {
+	files, err := c.files()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, file := range files {
+		if err := os.Remove(filepath.Join(c.dir, file.Name())); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// files lists the entry files of the cache. A missing cache has no entries.
+func (c *ResponseCache) files() ([]os.FileInfo, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []os.FileInfo
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !strings.HasSuffix(dirEntry.Name(), ".json") {
+			continue
+		}
+		file, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// CachedModel answers repeated requests from a ResponseCache and forwards the others to the wrapped model
+type CachedModel struct {
+	baseModel
+	model Model
+	cache *ResponseCache
+}
+
+// NewCachedModel wraps model, created from config, with cache
+func NewCachedModel(model Model, config *ModelConfig, cache *ResponseCache) *CachedModel {
+	return &CachedModel{
+		baseModel: baseModel{config: config},
+		model:     model,
+		cache:     cache,
+	}
+}
+
+// Unwrap returns the model answering requests that are not cached
+func (m *CachedModel) Unwrap() Model {
+	return m.model
+}
+
+func (m *CachedModel) Chat(ctx context.Context, question string, options ...ChatOption) (string, error) {
+	opts := m.chatOptions(options)
+	messages := buildMessages(opts, question)
+
+	return m.cached(messages, opts, options, func(options []ChatOption) (string, error) {
+		return m.model.Chat(ctx, question, options...)
+	})
+}
+
+func (m *CachedModel) ChatWithFile(ctx context.Context, question string, fileName string, fileContent string, options ...ChatOption) (string, error) {
+	opts := m.chatOptions(options)
+	messages := fileMessages(opts, question, fileName, fileContent)
+
+	return m.cached(messages, opts, options, func(options []ChatOption) (string, error) {
+		return m.model.ChatWithFile(ctx, question, fileName, fileContent, options...)
+	})
+}
+
+// cached returns the cached answer for messages, or asks the model and stores its answer
+func (m *CachedModel) cached(messages []Message, opts *ChatOptions, options []ChatOption, ask func([]ChatOption) (string, error)) (string, error) {
+	// Tool calls aren't stored, and tools may answer differently each time
+	if opts.Cache == CacheBypass || len(opts.Tools) > 0 {
+		return ask(options)
+	}
+
+	provider := determineModelType(m.config.Provider, m.config.Name, m.config.URL)
+	key := cacheKey(provider, m.config.Name, messages, opts)
+
+	if opts.Cache != CacheRefresh {
+		if entry, ok := m.cache.get(key); ok {
+			return m.replay(entry, provider, opts), nil
+		}
+	}
+
+	// Collect the response details even when the caller does not ask for them
+	info := opts.ResponseInfo
+	if info == nil {
+		info = &ResponseInfo{}
+		options = append(options[:len(options):len(options)], WithResponseInfo(info))
+	}
+
+	// Truncated answers are not worth keeping
+	answer, err := ask(options)
+	if err != nil || info.FinishReason == "length" {
+		return answer, err
+	}
+
+	entry := &cacheEntry{
+		Model:        m.config.Name,
+		Provider:     provider,
+		CreatedAt:    time.Now(),
+		Answer:       answer,
+		Usage:        info.Usage,
+		FinishReason: info.FinishReason,
+	}
+	if err := m.cache.put(key, entry); err != nil {
+		slog.Warn("failed to cache response", "model", m.config.Name, "error", err)
+	}
+	return answer, nil
+}
+
+// replay returns a cached answer, printing it like a streamed one when streaming
+func (m *CachedModel) replay(entry *cacheEntry, provider string, opts *ChatOptions) string {
+	info := opts.ResponseInfo
+	start := info.start(provider, m.config.Name, opts)
+	if info != nil {
+		info.FinishReason = entry.FinishReason
+		info.Cached = true
+	}
+	info.finish(start)
+
+	if opts.Stream {
+		info.firstToken()
+		emitChunk(opts.StreamHandler, entry.Answer)
+		endStream(opts.StreamHandler)
+	}
+	return entry.Answer
+}
+
+// cacheKey hashes everything that decides the answer of a request.
+// Streaming only changes how the answer is delivered and is not part of the key.
+func cacheKey(provider, model string, messages []Message, opts *ChatOptions) string {
+	data, _ := json.Marshal(struct {
+		Provider    string    `json:"provider"`
+		Model       string    `json:"model"`
+		Messages    []Message `json:"messages"`
+		Temperature float64   `json:"temperature"`
+		MaxTokens   int       `json:"max_tokens"`
+
+		ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	}{provider, model, messages, opts.Temperature, opts.MaxTokens, opts.ResponseFormat})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
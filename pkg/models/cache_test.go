@@ -0,0 +1,132 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCachedModel(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		json.NewEncoder(w).Encode(GeminiResponse{
+			Candidates: []GeminiCandidate{
+				{Content: GeminiContent{Role: "model", Parts: []GeminiPart{{Text: "answer"}}}, FinishReason: "STOP"},
+			},
+			UsageMetadata: &GeminiUsageMetadata{PromptTokenCount: 4, CandidatesTokenCount: 1, TotalTokenCount: 5},
+		})
+	}))
+	defer server.Close()
+
+	config := &ModelConfig{Name: "gemini-test", URL: server.URL, APIKey: "test-api-key"}
+	cache := NewResponseCache(t.TempDir(), time.Hour, 0)
+	model := NewCachedModel(NewGeminiModel(config), config, cache)
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		options    []ChatOption
+		wantCalled bool
+	}{
+		{name: "first request", wantCalled: true},
+		{name: "same request", wantCalled: false},
+		{name: "streaming is not part of the key", options: []ChatOption{WithStream(true)}, wantCalled: false},
+		{name: "other temperature", options: []ChatOption{WithTemperature(1)}, wantCalled: true},
+		{name: "other history", options: []ChatOption{WithHistory([]Message{{Role: "user", Content: "earlier"}})}, wantCalled: true},
+		{name: "bypass", options: []ChatOption{WithCacheMode(CacheBypass)}, wantCalled: true},
+		{name: "refresh", options: []ChatOption{WithCacheMode(CacheRefresh)}, wantCalled: true},
+	}
+
+	for _, tt := range tests {
+		before := requests.Load()
+		info := &ResponseInfo{}
+		answer, err := model.Chat(ctx, "question", append([]ChatOption{WithStream(false), WithResponseInfo(info)}, tt.options...)...)
+		if err != nil {
+			t.Fatalf("%s: Chat failed: %v", tt.name, err)
+		}
+		if answer != "answer" {
+			t.Errorf("%s: unexpected answer %q", tt.name, answer)
+		}
+		if called := requests.Load() > before; called != tt.wantCalled {
+			t.Errorf("%s: API called = %v, want %v", tt.name, called, tt.wantCalled)
+		}
+		if info.Cached == tt.wantCalled {
+			t.Errorf("%s: info.Cached = %v", tt.name, info.Cached)
+		}
+		if info.Model != "gemini-test" || info.Provider != "gemini" {
+			t.Errorf("%s: expected model details to be recorded, got %+v", tt.name, info)
+		}
+	}
+
+	// Questions about a file are cached separately from plain questions
+	before := requests.Load()
+	for i := 0; i < 2; i++ {
+		if _, err := model.ChatWithFile(ctx, "question", "main.go", "package main", WithStream(false)); err != nil {
+			t.Fatalf("ChatWithFile failed: %v", err)
+		}
+	}
+	if got := requests.Load() - before; got != 1 {
+		t.Errorf("Expected one API call for a repeated file question, got %d", got)
+	}
+
+	// Images are part of the key of a file question
+	before = requests.Load()
+	if _, err := model.ChatWithFile(ctx, "question", "main.go", "package main", WithStream(false), WithImages(ImageDataPart("image/png", []byte("png")))); err != nil {
+		t.Fatalf("ChatWithFile failed: %v", err)
+	}
+	if requests.Load() == before {
+		t.Error("Expected a file question with an image not to be answered from the cache")
+	}
+
+	stats, err := cache.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Entries != 5 {
+		t.Errorf("Expected 5 cached answers, got %+v", stats)
+	}
+
+	removed, err := cache.Clear()
+	if err != nil || removed != 5 {
+		t.Errorf("Expected 5 answers removed, got %d, %v", removed, err)
+	}
+	if stats, _ := cache.Stats(); stats.Entries != 0 {
+		t.Errorf("Expected an empty cache after Clear, got %+v", stats)
+	}
+}
+
+func TestResponseCache_Limits(t *testing.T) {
+	dir := t.TempDir()
+	entry := &cacheEntry{Model: "m", CreatedAt: time.Now(), Answer: strings.Repeat("x", 1000)}
+
+	// Entries are removed oldest first once the cache is over its size limit
+	cache := NewResponseCache(dir, time.Hour, 2500)
+	for _, key := range []string{"a", "b", "c"} {
+		if err := cache.put(key, entry); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok := cache.get("a"); ok {
+		t.Errorf("Expected the oldest entry to be removed")
+	}
+	if _, ok := cache.get("c"); !ok {
+		t.Errorf("Expected the newest entry to be kept")
+	}
+
+	// Expired entries are not used
+	expired := *entry
+	expired.CreatedAt = time.Now().Add(-2 * time.Hour)
+	if err := cache.put("old", &expired); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if _, ok := cache.get("old"); ok {
+		t.Errorf("Expected an expired entry to be ignored")
+	}
+}
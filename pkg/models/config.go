@@ -0,0 +1,662 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pokitpeng/ai/pkg/util"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigVersion is the current config.yaml schema version
+//
+// Version history:
+//   - 0: flat map of model configurations
+//   - 1: version, defaults, providers, models and aliases sections
+//   - 2: default model stored in defaults.model instead of default_enabled flags
+const ConfigVersion = 2
+
+// Output formats for answers
+const (
+	OutputText = "text"
+	OutputJSON = "json"
+)
+
+// GroupPrefix marks a group or tag in a model name, as in "ai multi @fast"
+const GroupPrefix = "@"
+
+// Session scopes, deciding which session is active
+const (
+	// SessionScopeGlobal uses one active session everywhere
+	SessionScopeGlobal = "global"
+	// SessionScopeDirectory uses one active session per git repository, or per directory outside of one
+	SessionScopeDirectory = "directory"
+)
+
+// Config is the on-disk layout of config.yaml
+type Config struct {
+	Version   int                        `yaml:"version"`
+	Defaults  *DefaultsConfig            `yaml:"defaults,omitempty"`
+	Providers map[string]*ProviderConfig `yaml:"providers,omitempty"`
+	Models    map[string]*ModelConfig    `yaml:"models"`
+	Aliases   map[string]string          `yaml:"aliases,omitempty"`
+	// Groups are named lists of models, used as @name
+	Groups map[string][]string `yaml:"groups,omitempty"`
+	// Router chooses the model of requests that don't name one
+	Router *RouterConfig `yaml:"router,omitempty"`
+}
+
+// DefaultsConfig stores global settings applied to every model
+type DefaultsConfig struct {
+	Model       string   `yaml:"model,omitempty"`
+	Temperature *float64 `yaml:"temperature,omitempty"`
+	MaxTokens   int      `yaml:"max_tokens,omitempty"`
+	Stream      *bool    `yaml:"stream,omitempty"`
+	History     *bool    `yaml:"history,omitempty"`
+	Output      string   `yaml:"output,omitempty"`
+	// SessionScope is global or directory
+	SessionScope string `yaml:"session_scope,omitempty"`
+	// MonthlyBudget is the spending in USD per calendar month above which a warning is shown
+	MonthlyBudget float64 `yaml:"monthly_budget,omitempty"`
+	// Cache answers repeated requests from disk, it is off by default
+	Cache *bool `yaml:"cache,omitempty"`
+	// CacheTTL is how long cached answers are used
+	CacheTTL time.Duration `yaml:"cache_ttl,omitempty"`
+	// CacheMaxSize is the cache size in MB above which the oldest answers are removed
+	CacheMaxSize int `yaml:"cache_max_size,omitempty"`
+	// Fallbacks are tried after the fallbacks of a model when it fails
+	Fallbacks []string `yaml:"fallbacks,omitempty"`
+}
+
+// HistoryEnabled reports whether conversation history is used, which is the default
+func (d DefaultsConfig) HistoryEnabled() bool {
+	return d.History == nil || *d.History
+}
+
+// OutputFormat returns the configured output format
+func (d DefaultsConfig) OutputFormat() string {
+	if d.Output == "" {
+		return OutputText
+	}
+	return d.Output
+}
+
+// SessionScopeOrDefault returns the configured session scope
+func (d DefaultsConfig) SessionScopeOrDefault() string {
+	if d.SessionScope == "" {
+		return SessionScopeGlobal
+	}
+	return d.SessionScope
+}
+
+// CacheEnabled reports whether the response cache is used
+func (d DefaultsConfig) CacheEnabled() bool {
+	return d.Cache != nil && *d.Cache
+}
+
+// ResponseCache returns the response cache configured by the defaults
+func (d DefaultsConfig) ResponseCache() *ResponseCache {
+	return NewResponseCache(util.CacheDir(), d.CacheTTL, int64(d.CacheMaxSize)<<20)
+}
+
+// hasChatOptions reports whether any chat option is set
+func (d *DefaultsConfig) hasChatOptions() bool {
+	return d != nil && (d.Temperature != nil || d.MaxTokens > 0 || d.Stream != nil)
+}
+
+// ChatOptions returns the global default chat options
+func (d *DefaultsConfig) ChatOptions() *ChatOptions {
+	opts := DefaultChatOptions()
+	if d == nil {
+		return opts
+	}
+
+	if d.Temperature != nil {
+		opts.Temperature = *d.Temperature
+	}
+	if d.MaxTokens > 0 {
+		opts.MaxTokens = d.MaxTokens
+	}
+	if d.Stream != nil {
+		opts.Stream = *d.Stream
+	}
+	return opts
+}
+
+// ConfigIssue describes a problem found in the config file
+type ConfigIssue struct {
+	Line    int
+	Path    string
+	Message string
+}
+
+func (i ConfigIssue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", i.Line, i.Path, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Path, i.Message)
+}
+
+// ConfigError is returned when the config file cannot be used
+type ConfigError struct {
+	File   string
+	Issues []ConfigIssue
+}
+
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "invalid config %s:", e.File)
+	for _, issue := range e.Issues {
+		b.WriteString("\n  ")
+		b.WriteString(issue.String())
+	}
+	return b.String()
+}
+
+// Supported API types for models and providers
+var knownProviderTypes = map[string]bool{
+	"":          true,
+	"openai":    true,
+	"anthropic": true,
+	"gemini":    true,
+}
+
+// yaml.v3 prefixes decode errors with the line number
+var yamlLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// ParseConfig parses config.yaml, migrating older layouts to the current schema.
+// It reports whether a migration happened.
+func ParseConfig(data []byte) (*Config, bool, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, false, &ConfigError{Issues: []ConfigIssue{yamlIssue(err)}}
+	}
+
+	// An empty file is a new configuration
+	if len(root.Content) == 0 {
+		return newConfig(), false, nil
+	}
+
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return nil, false, &ConfigError{Issues: []ConfigIssue{{Line: doc.Line, Path: "config", Message: "must be a mapping"}}}
+	}
+
+	config := newConfig()
+	migrated := false
+
+	if isLegacyConfig(doc) {
+		// Version 0: a flat map of model configurations
+		if err := decodeStrict(data, &config.Models); err != nil {
+			return nil, false, err
+		}
+		migrated = true
+	} else {
+		if err := decodeStrict(data, config); err != nil {
+			return nil, false, err
+		}
+		if config.Version > ConfigVersion {
+			return nil, false, &ConfigError{Issues: []ConfigIssue{{
+				Line:    valueLine(doc, "version"),
+				Path:    "version",
+				Message: fmt.Sprintf("version %d is newer than supported version %d, please upgrade ai", config.Version, ConfigVersion),
+			}}}
+		}
+		if config.Version < ConfigVersion {
+			migrated = true
+		}
+	}
+
+	config.migrateDefaultModel()
+	config.Version = ConfigVersion
+	config.normalize()
+
+	return config, migrated, nil
+}
+
+// migrateDefaultModel moves default_enabled flags into defaults.model.
+// When several models are flagged, the first one in name order wins.
+func (c *Config) migrateDefaultModel() {
+	for _, name := range sortedKeys(c.Models) {
+		model := c.Models[name]
+		if model == nil || !model.DefaultEnabled {
+			continue
+		}
+		if c.Defaults == nil {
+			c.Defaults = &DefaultsConfig{}
+		}
+		if c.Defaults.Model == "" {
+			c.Defaults.Model = name
+		}
+		model.DefaultEnabled = false
+	}
+}
+
+// ValidateConfig checks config.yaml and returns every problem found
+func ValidateConfig(data []byte) []ConfigIssue {
+	config, _, err := ParseConfig(data)
+	if err != nil {
+		var configErr *ConfigError
+		if errors.As(err, &configErr) {
+			return configErr.Issues
+		}
+		return []ConfigIssue{{Path: "config", Message: err.Error()}}
+	}
+
+	// Locate nodes to report line numbers
+	var root yaml.Node
+	_ = yaml.Unmarshal(data, &root)
+	var doc, modelsNode, providersNode, aliasesNode, groupsNode, routerNode, defaultsNode *yaml.Node
+	if len(root.Content) > 0 {
+		doc = root.Content[0]
+		if isLegacyConfig(doc) {
+			modelsNode = doc
+		} else {
+			modelsNode = mappingValue(doc, "models")
+			providersNode = mappingValue(doc, "providers")
+			aliasesNode = mappingValue(doc, "aliases")
+			groupsNode = mappingValue(doc, "groups")
+			routerNode = mappingValue(doc, "router")
+			defaultsNode = mappingValue(doc, "defaults")
+		}
+	}
+
+	var issues []ConfigIssue
+	addIssue := func(node *yaml.Node, field, path, message string) {
+		issues = append(issues, ConfigIssue{Line: valueLine(node, field), Path: path, Message: message})
+	}
+
+	if config.Defaults != nil && config.Defaults.Temperature != nil && !validTemperature(*config.Defaults.Temperature) {
+		addIssue(defaultsNode, "temperature", "defaults.temperature", "must be between 0 and 2")
+	}
+	if config.Defaults != nil && config.Defaults.MaxTokens < 0 {
+		addIssue(defaultsNode, "max_tokens", "defaults.max_tokens", "must not be negative")
+	}
+	if config.Defaults != nil && config.Defaults.Model != "" {
+		if _, exists := config.Models[config.Defaults.Model]; !exists {
+			addIssue(defaultsNode, "model", "defaults.model", fmt.Sprintf("model %q is not defined", config.Defaults.Model))
+		}
+	}
+	if config.Defaults != nil && !validOutputFormat(config.Defaults.Output) {
+		addIssue(defaultsNode, "output", "defaults.output", fmt.Sprintf("unknown output format %q", config.Defaults.Output))
+	}
+	if config.Defaults != nil && config.Defaults.MonthlyBudget < 0 {
+		addIssue(defaultsNode, "monthly_budget", "defaults.monthly_budget", "must not be negative")
+	}
+	if config.Defaults != nil && !validSessionScope(config.Defaults.SessionScope) {
+		addIssue(defaultsNode, "session_scope", "defaults.session_scope", fmt.Sprintf("unknown session scope %q", config.Defaults.SessionScope))
+	}
+	if config.Defaults != nil && config.Defaults.CacheTTL < 0 {
+		addIssue(defaultsNode, "cache_ttl", "defaults.cache_ttl", "must not be negative")
+	}
+	if config.Defaults != nil && config.Defaults.CacheMaxSize < 0 {
+		addIssue(defaultsNode, "cache_max_size", "defaults.cache_max_size", "must not be negative")
+	}
+	if config.Defaults != nil {
+		for _, fallback := range config.Defaults.Fallbacks {
+			if !config.definesModel(fallback) {
+				addIssue(defaultsNode, "fallbacks", "defaults.fallbacks", fmt.Sprintf("model %q is not defined", fallback))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(config.Providers) {
+		provider := config.Providers[name]
+		node := mappingValue(providersNode, name)
+		path := "providers." + name
+
+		if provider == nil {
+			addIssue(providersNode, name, path, "must not be empty")
+			continue
+		}
+		if !knownProviderTypes[strings.ToLower(provider.Type)] {
+			addIssue(node, "type", path+".type", fmt.Sprintf("unknown type %q", provider.Type))
+		}
+		if provider.URL == "" && strings.ToLower(provider.Type) != "gemini" {
+			addIssue(providersNode, name, path+".url", "is required")
+		}
+		if provider.Timeout < 0 {
+			addIssue(node, "timeout", path+".timeout", "must not be negative")
+		}
+	}
+
+	for _, name := range sortedKeys(config.Models) {
+		model := config.Models[name]
+		node := mappingValue(modelsNode, name)
+		path := "models." + name
+
+		if model == nil {
+			addIssue(modelsNode, name, path, "must not be empty")
+			continue
+		}
+		if !knownProviderTypes[strings.ToLower(model.Provider)] {
+			addIssue(node, "provider", path+".provider", fmt.Sprintf("unknown provider %q", model.Provider))
+		}
+		if model.Profile != "" {
+			if _, exists := config.Providers[model.Profile]; !exists {
+				addIssue(node, "profile", path+".profile", fmt.Sprintf("profile %q is not defined in providers", model.Profile))
+			}
+		} else if model.URL == "" && determineModelType(model.Provider, model.Name, "") != "gemini" {
+			addIssue(modelsNode, name, path+".url", "is required when no profile is set")
+		}
+		if opts := model.DefaultChatOptions; opts != nil {
+			optsNode := mappingValue(node, "default_chat_options")
+			if !validTemperature(opts.Temperature) {
+				addIssue(optsNode, "temperature", path+".default_chat_options.temperature", "must be between 0 and 2")
+			}
+			if opts.MaxTokens < 0 {
+				addIssue(optsNode, "maxtokens", path+".default_chat_options.maxtokens", "must not be negative")
+			}
+		}
+		for _, tag := range model.Tags {
+			if !validGroupName(tag) {
+				addIssue(node, "tags", path+".tags", fmt.Sprintf("invalid tag %q", tag))
+			}
+		}
+		for _, fallback := range model.Fallbacks {
+			if !config.definesModel(fallback) {
+				addIssue(node, "fallbacks", path+".fallbacks", fmt.Sprintf("model %q is not defined", fallback))
+			}
+		}
+		if pricing := model.Pricing; pricing != nil {
+			pricingNode := mappingValue(node, "pricing")
+			if pricing.Input < 0 {
+				addIssue(pricingNode, "input", path+".pricing.input", "must not be negative")
+			}
+			if pricing.Output < 0 {
+				addIssue(pricingNode, "output", path+".pricing.output", "must not be negative")
+			}
+		}
+	}
+
+	for _, alias := range sortedKeys(config.Aliases) {
+		target := config.Aliases[alias]
+		path := "aliases." + alias
+
+		if _, exists := config.Models[alias]; exists {
+			addIssue(aliasesNode, alias, path, "shadows a model with the same name")
+		}
+		if _, exists := config.Models[target]; !exists {
+			addIssue(aliasesNode, alias, path, fmt.Sprintf("target model %q is not defined", target))
+		}
+	}
+
+	for _, group := range sortedKeys(config.Groups) {
+		path := "groups." + group
+
+		if !validGroupName(group) {
+			addIssue(groupsNode, group, path, "must not be empty or contain '@', ',' or spaces")
+		}
+		if len(config.Groups[group]) == 0 {
+			addIssue(groupsNode, group, path, "must list at least one model")
+		}
+		for _, member := range config.Groups[group] {
+			if strings.HasPrefix(member, GroupPrefix) || !config.definesModel(member) {
+				addIssue(groupsNode, group, path, fmt.Sprintf("model %q is not defined", member))
+			}
+		}
+	}
+
+	if router := config.Router; router != nil {
+		if router.Default != "" && !config.definesModel(router.Default) {
+			addIssue(routerNode, "default", "router.default", fmt.Sprintf("model %q is not defined", router.Default))
+		}
+		for i, rule := range router.Rules {
+			path := fmt.Sprintf("router.rules[%d]", i)
+			line := valueLine(routerNode, "rules")
+			if rulesNode := mappingValue(routerNode, "rules"); rulesNode != nil && i < len(rulesNode.Content) {
+				line = rulesNode.Content[i].Line
+			}
+			addRuleIssue := func(message string) {
+				issues = append(issues, ConfigIssue{Line: line, Path: path, Message: message})
+			}
+
+			if rule.Model == "" {
+				addRuleIssue("model is required")
+			} else if !config.definesModel(rule.Model) {
+				addRuleIssue(fmt.Sprintf("model %q is not defined", rule.Model))
+			}
+			if rule.Command != "" && rule.Command != RouteCommandRoot && rule.Command != RouteCommandFile {
+				addRuleIssue(fmt.Sprintf("unknown command %q, expected %s or %s", rule.Command, RouteCommandRoot, RouteCommandFile))
+			}
+			if rule.MinLength < 0 || rule.MaxLength < 0 || rule.MinFiles < 0 {
+				addRuleIssue("lengths and file counts must not be negative")
+			}
+			if rule.MaxLength > 0 && rule.MaxLength < rule.MinLength {
+				addRuleIssue("max_length is less than min_length")
+			}
+		}
+	}
+
+	return issues
+}
+
+// MarshalConfig serializes the configuration in the current schema
+func MarshalConfig(config *Config) ([]byte, error) {
+	config.Version = ConfigVersion
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// mergeConfig merges two configurations derived from base.
+// Entries changed in ours win, other entries are taken from theirs.
+func mergeConfig(base, ours, theirs *Config) *Config {
+	merged := newConfig()
+	merged.Providers = mergeMap(base.Providers, ours.Providers, theirs.Providers)
+	merged.Models = mergeMap(base.Models, ours.Models, theirs.Models)
+	merged.Aliases = mergeMap(base.Aliases, ours.Aliases, theirs.Aliases)
+	merged.Groups = mergeMap(base.Groups, ours.Groups, theirs.Groups)
+
+	merged.Defaults = theirs.Defaults
+	if !equalYAML(base.Defaults, ours.Defaults) {
+		merged.Defaults = ours.Defaults
+	}
+	merged.Router = theirs.Router
+	if !equalYAML(base.Router, ours.Router) {
+		merged.Router = ours.Router
+	}
+
+	return merged
+}
+
+// mergeMap performs a three-way merge of map entries
+func mergeMap[V any](base, ours, theirs map[string]V) map[string]V {
+	merged := make(map[string]V, len(theirs))
+	for k, v := range theirs {
+		merged[k] = v
+	}
+
+	keys := make(map[string]bool, len(base)+len(ours))
+	for k := range base {
+		keys[k] = true
+	}
+	for k := range ours {
+		keys[k] = true
+	}
+
+	for k := range keys {
+		baseValue, inBase := base[k]
+		oursValue, inOurs := ours[k]
+		if inBase == inOurs && equalYAML(baseValue, oursValue) {
+			// Unchanged on our side, keep theirs
+			continue
+		}
+		if inOurs {
+			merged[k] = oursValue
+		} else {
+			delete(merged, k)
+		}
+	}
+
+	return merged
+}
+
+// equalYAML reports whether two values serialize to the same YAML
+func equalYAML(a, b interface{}) bool {
+	dataA, errA := yaml.Marshal(a)
+	dataB, errB := yaml.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
+
+// newConfig creates an empty configuration
+func newConfig() *Config {
+	return &Config{
+		Version:   ConfigVersion,
+		Providers: make(map[string]*ProviderConfig),
+		Models:    make(map[string]*ModelConfig),
+		Aliases:   make(map[string]string),
+		Groups:    make(map[string][]string),
+	}
+}
+
+// normalize fills in values that older files may leave empty
+func (c *Config) normalize() {
+	if c.Providers == nil {
+		c.Providers = make(map[string]*ProviderConfig)
+	}
+	if c.Models == nil {
+		c.Models = make(map[string]*ModelConfig)
+	}
+	if c.Aliases == nil {
+		c.Aliases = make(map[string]string)
+	}
+	if c.Groups == nil {
+		c.Groups = make(map[string][]string)
+	}
+
+	for name, model := range c.Models {
+		if model != nil && model.Name == "" {
+			model.Name = name
+		}
+	}
+}
+
+// definesModel reports whether name is a model, an alias, or a group or tag written @name
+func (c *Config) definesModel(name string) bool {
+	if group, isGroup := strings.CutPrefix(name, GroupPrefix); isGroup {
+		if _, exists := c.Groups[group]; exists {
+			return true
+		}
+		for _, model := range c.Models {
+			if model != nil && slices.Contains(model.Tags, group) {
+				return true
+			}
+		}
+		return false
+	}
+
+	_, isModel := c.Models[name]
+	_, isAlias := c.Aliases[name]
+	return isModel || isAlias
+}
+
+// isLegacyConfig reports whether the document is the unversioned flat map of models
+func isLegacyConfig(doc *yaml.Node) bool {
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		switch doc.Content[i].Value {
+		case "version", "defaults", "models", "providers", "aliases", "groups", "router":
+			return false
+		}
+	}
+	return true
+}
+
+// decodeStrict decodes YAML, rejecting unknown fields
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			issues := make([]ConfigIssue, 0, len(typeErr.Errors))
+			for _, msg := range typeErr.Errors {
+				issues = append(issues, yamlIssue(errors.New(msg)))
+			}
+			return &ConfigError{Issues: issues}
+		}
+		return &ConfigError{Issues: []ConfigIssue{yamlIssue(err)}}
+	}
+	return nil
+}
+
+// yamlIssue converts a yaml.v3 error message into an issue with a line number
+func yamlIssue(err error) ConfigIssue {
+	msg := err.Error()
+	if match := yamlLinePattern.FindStringSubmatch(msg); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return ConfigIssue{Line: line, Path: "config", Message: match[2]}
+	}
+	return ConfigIssue{Path: "config", Message: strings.TrimPrefix(msg, "yaml: ")}
+}
+
+// mappingValue returns the value node for a key of a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// valueLine returns the line of a key in a mapping node, or of the node itself
+func valueLine(node *yaml.Node, key string) int {
+	if node == nil {
+		return 0
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == key {
+				return node.Content[i].Line
+			}
+		}
+	}
+	return node.Line
+}
+
+func validTemperature(temp float64) bool {
+	return temp >= 0 && temp <= 2
+}
+
+func validOutputFormat(format string) bool {
+	return format == "" || format == OutputText || format == OutputJSON
+}
+
+func validSessionScope(scope string) bool {
+	return scope == "" || scope == SessionScopeGlobal || scope == SessionScopeDirectory
+}
+
+// validGroupName reports whether a group or tag name can be used as @name in model lists
+func validGroupName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, GroupPrefix+", \t")
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig_MigratesLegacy(t *testing.T) {
+	config, migrated, err := ParseConfig([]byte(`gpt-4o:
+  url: https://api.openai.com
+  api_key: sk-test
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if !migrated {
+		t.Errorf("Expected legacy config to be migrated")
+	}
+	if config.Version != ConfigVersion {
+		t.Errorf("Expected version %d, got %d", ConfigVersion, config.Version)
+	}
+	if config.Models["gpt-4o"] == nil || config.Models["gpt-4o"].Name != "gpt-4o" {
+		t.Errorf("Expected model name to default to its key, got %+v", config.Models["gpt-4o"])
+	}
+
+	data, err := MarshalConfig(config)
+	if err != nil {
+		t.Fatalf("MarshalConfig failed: %v", err)
+	}
+	if !strings.HasPrefix(string(data), fmt.Sprintf("version: %d\n", ConfigVersion)) {
+		t.Errorf("Expected marshaled config to start with the version, got:\n%s", data)
+	}
+
+	_, migrated, err = ParseConfig(data)
+	if err != nil || migrated {
+		t.Errorf("Expected current config to load without migration, migrated=%v err=%v", migrated, err)
+	}
+}
+
+func TestParseConfig_RejectsNewerVersion(t *testing.T) {
+	if _, _, err := ParseConfig([]byte("version: 99\nmodels: {}\n")); err == nil {
+		t.Errorf("Expected error for unsupported version")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		issues []string
+	}{
+		{
+			name:   "valid",
+			config: "version: 2\nproviders:\n  gw:\n    url: https://gw\nmodels:\n  a:\n    profile: gw\naliases:\n  fast: a\n",
+		},
+		{
+			name:   "syntax error",
+			config: "version: 1\nmodels:\n  a: [\n",
+			issues: []string{"line 3:"},
+		},
+		{
+			name:   "unknown field",
+			config: "version: 1\nmodels:\n  a:\n    url: https://x\n    colour: red\n",
+			issues: []string{"line 5: config: field colour not found"},
+		},
+		{
+			name:   "semantic errors",
+			config: "version: 1\nmodels:\n  a:\n    profile: missing\n  b:\n    name: b\naliases:\n  fast: zzz\n",
+			issues: []string{
+				"line 4: models.a.profile:",
+				"line 5: models.b.url: is required",
+				"line 8: aliases.fast: target model",
+			},
+		},
+		{
+			name:   "groups and tags",
+			config: "version: 2\nmodels:\n  a:\n    url: https://a\n    tags: [local, \"@bad\"]\naliases:\n  fast: a\ngroups:\n  ok: [a, fast]\n  broken: [a, zzz]\n  empty: []\n",
+			issues: []string{
+				"line 5: models.a.tags: invalid tag",
+				"line 10: groups.broken: model",
+				"line 11: groups.empty: must list",
+			},
+		},
+		{
+			name:   "fallbacks",
+			config: "version: 2\ndefaults:\n  fallbacks: [\"@none\"]\nmodels:\n  a:\n    url: https://a\n    tags: [local]\n    fallbacks: [\"@local\", zzz]\n",
+			issues: []string{
+				"line 3: defaults.fallbacks: model \"@none\"",
+				"line 8: models.a.fallbacks: model \"zzz\"",
+			},
+		},
+		{
+			name:   "router",
+			config: "version: 2\nmodels:\n  a:\n    url: https://a\nrouter:\n  default: zzz\n  rules:\n    - model: a\n      command: edit\n    - model: \"@none\"\n      min_length: 100\n      max_length: 10\n",
+			issues: []string{
+				"line 6: router.default: model",
+				"line 8: router.rules[0]: unknown command",
+				"line 10: router.rules[1]: model",
+				"line 10: router.rules[1]: max_length",
+			},
+		},
+		{
+			name:   "negative price and budget",
+			config: "version: 2\ndefaults:\n  monthly_budget: -5\nmodels:\n  a:\n    url: https://a\n    pricing:\n      input: -1\n      output: 10\n",
+			issues: []string{
+				"line 3: defaults.monthly_budget:",
+				"line 8: models.a.pricing.input:",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := ValidateConfig([]byte(tt.config))
+			if len(issues) != len(tt.issues) {
+				t.Fatalf("Expected %d issues, got %v", len(tt.issues), issues)
+			}
+			for i, issue := range issues {
+				if !strings.HasPrefix(issue.String(), tt.issues[i]) {
+					t.Errorf("Expected issue %q to start with %q", issue.String(), tt.issues[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseConfig_SingleDefaultModel(t *testing.T) {
+	config, migrated, err := ParseConfig([]byte(`version: 1
+models:
+  b:
+    url: https://b
+    default_enabled: true
+  a:
+    url: https://a
+    default_enabled: true
+`))
+	if err != nil {
+		t.Fatalf("ParseConfig failed: %v", err)
+	}
+	if !migrated {
+		t.Errorf("Expected version 1 config to be migrated")
+	}
+	if config.Defaults == nil || config.Defaults.Model != "a" {
+		t.Fatalf("Expected default model to be a, got %+v", config.Defaults)
+	}
+	for name, model := range config.Models {
+		if model.DefaultEnabled {
+			t.Errorf("Expected default_enabled to be cleared on %s", name)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Content part types
+const (
+	ContentText  = "text"
+	ContentImage = "image"
+)
+
+// ErrImagesNotSupported is returned when a question with images is sent to a model that only reads text
+var ErrImagesNotSupported = errors.New("model does not support images")
+
+// ContentPart is a part of a message made of text and images
+type ContentPart struct {
+	// Type is ContentText or ContentImage
+	Type string
+	Text string
+	// ImageURL is the address of an image: an http(s) URL or a data URL holding base64 data
+	ImageURL string
+}
+
+// TextPart returns a content part of text
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: ContentText, Text: text}
+}
+
+// ImageURLPart returns a content part of an image the provider downloads
+func ImageURLPart(url string) ContentPart {
+	return ContentPart{Type: ContentImage, ImageURL: url}
+}
+
+// ImageDataPart returns a content part of an image sent with the request
+func ImageDataPart(mimeType string, data []byte) ContentPart {
+	return ContentPart{Type: ContentImage, ImageURL: "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)}
+}
+
+// imageData returns the MIME type and base64 data of an image sent with the request,
+// ok being false for an image given by URL
+func (p ContentPart) imageData() (mimeType, data string, ok bool) {
+	rest, found := strings.CutPrefix(p.ImageURL, "data:")
+	if !found {
+		return "", "", false
+	}
+	mimeType, data, found = strings.Cut(rest, ";base64,")
+	return mimeType, data, found
+}
+
+// hasImages reports whether messages contain images
+func hasImages(messages []Message) bool {
+	for _, message := range messages {
+		for _, part := range message.Parts {
+			if part.Type == ContentImage {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkImages returns ErrImagesNotSupported when messages contain images the model can't read
+func checkImages(config *ModelConfig, messages []Message) error {
+	if hasImages(messages) && !config.SupportsImages() {
+		return fmt.Errorf("%w: %s", ErrImagesNotSupported, config.Name)
+	}
+	return nil
+}
+
+// visionModelName matches the names of models known to read images
+var visionModelName = regexp.MustCompile(`gpt-4o|gpt-4\.1|gpt-4-turbo|gpt-4-vision|gpt-5|^o[1-9]|claude-3|claude-(opus|sonnet|haiku)|gemini|vision|[-_]vl\b|llava|pixtral`)
+
+// SupportsImages reports whether the model reads images: the vision setting, or a guess from
+// the model name when it is not set
+func (c *ModelConfig) SupportsImages() bool {
+	if c.Vision != nil {
+		return *c.Vision
+	}
+	if determineModelType(c.Provider, c.Name, c.URL) == "gemini" {
+		return true
+	}
+
+	// Names may be qualified with their vendor, as in openai/gpt-4o
+	name := strings.ToLower(c.Name)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return visionModelName.MatchString(name)
+}
+
+// openAIContentPart is a content part in the OpenAI format
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+// openAIImageURL is the image of an OpenAI content part
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+// MarshalJSON encodes a message in the OpenAI format, the content being a list of
+// text and image_url parts when the message has parts
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	if len(m.Parts) == 0 {
+		return json.Marshal(plain(m))
+	}
+
+	parts := make([]openAIContentPart, len(m.Parts))
+	for i, part := range m.Parts {
+		if part.Type == ContentImage {
+			parts[i] = openAIContentPart{Type: "image_url", ImageURL: &openAIImageURL{URL: part.ImageURL}}
+		} else {
+			parts[i] = openAIContentPart{Type: "text", Text: part.Text}
+		}
+	}
+	return json.Marshal(struct {
+		plain
+		Content []openAIContentPart `json:"content"`
+	}{plain(m), parts})
+}
+
+// UnmarshalJSON decodes a message in the OpenAI format, with its content as text or parts
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type plain Message
+	var message struct {
+		plain
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return err
+	}
+	*m = Message(message.plain)
+
+	if len(message.Content) == 0 || string(message.Content) == "null" {
+		return nil
+	}
+	if message.Content[0] != '[' {
+		return json.Unmarshal(message.Content, &m.Content)
+	}
+
+	var parts []openAIContentPart
+	if err := json.Unmarshal(message.Content, &parts); err != nil {
+		return err
+	}
+	var text []string
+	for _, part := range parts {
+		if part.Type == "image_url" && part.ImageURL != nil {
+			m.Parts = append(m.Parts, ImageURLPart(part.ImageURL.URL))
+		} else {
+			m.Parts = append(m.Parts, TextPart(part.Text))
+			text = append(text, part.Text)
+		}
+	}
+	m.Content = strings.Join(text, "\n")
+	return nil
+}
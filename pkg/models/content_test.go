@@ -0,0 +1,118 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOpenAIModel_ChatWithImages(t *testing.T) {
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(OpenAIResponse{Choices: []Choice{{Message: Message{Role: "assistant", Content: "A cat."}}}})
+	}))
+	defer server.Close()
+
+	model := NewOpenAIModel(&ModelConfig{Name: "gpt-4o-mini", URL: server.URL})
+	answer, err := model.Chat(context.Background(), "What is this?", WithStream(false),
+		WithImages(ImageDataPart("image/png", []byte("png")), ImageURLPart("https://example.com/cat.jpg")))
+	if err != nil || answer != "A cat." {
+		t.Fatalf("Expected an answer, got %q, %v", answer, err)
+	}
+
+	want := `"content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,cG5n"}},{"type":"image_url","image_url":{"url":"https://example.com/cat.jpg"}}]`
+	if !strings.Contains(string(body), want) {
+		t.Errorf("Expected the question as content parts, got %s", body)
+	}
+
+	// The parts are read back from a request
+	var request OpenAIRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		t.Fatalf("Failed to decode request: %v", err)
+	}
+	question := request.Messages[len(request.Messages)-1]
+	if question.Content != "What is this?" || len(question.Parts) != 3 || question.Parts[2].ImageURL != "https://example.com/cat.jpg" {
+		t.Errorf("Unexpected decoded message %+v", question)
+	}
+
+	// Images are sent with a question about a file too
+	if _, err := model.ChatWithFile(context.Background(), "What is this?", "notes.txt", "a cat", WithStream(false),
+		WithImages(ImageDataPart("image/png", []byte("png")))); err != nil {
+		t.Fatalf("ChatWithFile failed: %v", err)
+	}
+	if !strings.Contains(string(body), `{"type":"image_url","image_url":{"url":"data:image/png;base64,cG5n"}}`) {
+		t.Errorf("Expected the image with the file question, got %s", body)
+	}
+
+	// Models that only read text fail before sending anything
+	body = nil
+	model = NewOpenAIModel(&ModelConfig{Name: "deepseek-chat", URL: server.URL})
+	if _, err := model.Chat(context.Background(), "What is this?", WithImages(ImageURLPart("https://example.com/cat.jpg"))); !errors.Is(err, ErrImagesNotSupported) {
+		t.Errorf("Expected ErrImagesNotSupported, got %v", err)
+	}
+	if _, err := model.ChatWithFile(context.Background(), "What is this?", "notes.txt", "a cat", WithImages(ImageURLPart("https://example.com/cat.jpg"))); !errors.Is(err, ErrImagesNotSupported) {
+		t.Errorf("Expected ErrImagesNotSupported with a file, got %v", err)
+	}
+	if body != nil {
+		t.Error("Expected no request for a model without image support")
+	}
+}
+
+func TestModelConfig_SupportsImages(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		config ModelConfig
+		want   bool
+	}{
+		{ModelConfig{Name: "gpt-4o"}, true},
+		{ModelConfig{Name: "openai/gpt-4.1-mini"}, true},
+		{ModelConfig{Name: "o3"}, true},
+		{ModelConfig{Name: "claude-3-5-sonnet-latest"}, true},
+		{ModelConfig{Name: "qwen2.5-vl-72b"}, true},
+		{ModelConfig{Name: "flash", Provider: "gemini"}, true},
+		{ModelConfig{Name: "gpt-3.5-turbo"}, false},
+		{ModelConfig{Name: "deepseek-chat"}, false},
+		{ModelConfig{Name: "llama3", Vision: &yes}, true},
+		{ModelConfig{Name: "gpt-4o", Vision: &no}, false},
+	}
+	for _, test := range tests {
+		if got := test.config.SupportsImages(); got != test.want {
+			t.Errorf("SupportsImages(%s) = %v, want %v", test.config.Name, got, test.want)
+		}
+	}
+}
+
+func TestImageSerialization(t *testing.T) {
+	message := Message{Role: "user", Content: "What is this?", Parts: []ContentPart{
+		TextPart("What is this?"),
+		ImageDataPart("image/png", []byte("png")),
+		ImageURLPart("https://example.com/cat.jpg"),
+	}}
+
+	// Both Anthropic methods check the model reads images
+	anthropic := NewAnthropicModel(&ModelConfig{Name: "claude-2.1"})
+	image := WithImages(ImageURLPart("https://example.com/cat.jpg"))
+	if _, err := anthropic.Chat(context.Background(), "What is this?", image); !errors.Is(err, ErrImagesNotSupported) {
+		t.Errorf("Expected ErrImagesNotSupported from Chat, got %v", err)
+	}
+	if _, err := anthropic.ChatWithFile(context.Background(), "What is this?", "notes.txt", "cat", image); !errors.Is(err, ErrImagesNotSupported) {
+		t.Errorf("Expected ErrImagesNotSupported from ChatWithFile, got %v", err)
+	}
+
+	// Gemini takes the image data inline
+	request := buildGeminiRequest([]Message{message}, &ChatOptions{})
+	parts := request.Contents[0].Parts
+	if len(parts) != 2 || parts[0].Text != "What is this?" || parts[1].InlineData == nil || parts[1].InlineData.Data != "cG5n" {
+		t.Errorf("Unexpected Gemini parts %+v", parts)
+	}
+	model := NewGeminiModel(&ModelConfig{Name: "gemini-2.0-flash"})
+	if _, err := model.Chat(context.Background(), "What is this?", WithImages(ImageURLPart("https://example.com/cat.jpg"))); !errors.Is(err, ErrImagesNotSupported) {
+		t.Errorf("Expected image URLs to be refused by Gemini, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// redacted replaces secrets in debug output
+const redacted = "***"
+
+// sensitiveHeaderWords are found in the names of headers holding credentials, such as
+// Authorization, X-Api-Key, X-Auth-Token or Ocp-Apim-Subscription-Key, which custom
+// headers of models and profiles often are
+var sensitiveHeaderWords = []string{"auth", "key", "token", "secret", "cookie", "session", "password"}
+
+// isSensitiveHeader reports whether a header may hold credentials and is redacted in debug output
+func isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveHeaderWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// debugLog receives the HTTP traffic of model requests when debugging is enabled
+var debugLog atomic.Pointer[log.Logger]
+
+// SetDebugOutput logs the requests, response headers, streamed frames and timings
+// of every API call to w, with credentials redacted. A nil w disables debugging.
+func SetDebugOutput(w io.Writer) {
+	if w == nil {
+		debugLog.Store(nil)
+		return
+	}
+	debugLog.Store(log.New(w, "debug: ", log.Ltime|log.Lmicroseconds))
+}
+
+// withDebug makes the client log its traffic when debugging is enabled
+func withDebug(httpClient *http.Client) *http.Client {
+	logger := debugLog.Load()
+	if logger == nil {
+		return httpClient
+	}
+
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	httpClient.Transport = &debugTransport{base: base, log: logger}
+	return httpClient
+}
+
+// debugTransport logs requests and responses passing through it
+type debugTransport struct {
+	base http.RoundTripper
+	log  *log.Logger
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.log.Printf("> %s %s", req.Method, redactURL(req.URL))
+	t.logHeaders(">", req.Header)
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			t.logBody(">", data)
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.log.Printf("! request failed after %s: %v", time.Since(start).Round(time.Millisecond), err)
+		return nil, err
+	}
+
+	t.log.Printf("< %s %s (headers after %s)", resp.Proto, resp.Status, time.Since(start).Round(time.Millisecond))
+	t.logHeaders("<", resp.Header)
+	resp.Body = &debugBody{ReadCloser: resp.Body, log: t.log, start: start}
+	return resp, nil
+}
+
+// logHeaders logs headers in name order, redacting credentials
+func (t *debugTransport) logHeaders(direction string, header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, value := range header[name] {
+			if isSensitiveHeader(name) {
+				value = redactCredential(value)
+			}
+			t.log.Printf("%s %s: %s", direction, name, value)
+		}
+	}
+}
+
+// logBody logs a request body, indented when it is JSON
+func (t *debugTransport) logBody(direction string, data []byte) {
+	var indented bytes.Buffer
+	if json.Indent(&indented, data, "", "  ") == nil {
+		data = indented.Bytes()
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		t.log.Printf("%s %s", direction, line)
+	}
+}
+
+// debugBody logs a response body line by line as it is read, so streamed frames appear as they arrive
+type debugBody struct {
+	io.ReadCloser
+	log     *log.Logger
+	start   time.Time
+	pending []byte
+	size    int
+	done    bool
+}
+
+func (b *debugBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if b.size == 0 {
+			b.log.Printf("< first byte after %s", time.Since(b.start).Round(time.Millisecond))
+		}
+		b.size += n
+		b.pending = append(b.pending, p[:n]...)
+		b.flushLines()
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *debugBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+// flushLines logs the complete lines read so far, skipping the blank lines between frames
+func (b *debugBody) flushLines() {
+	for {
+		i := bytes.IndexByte(b.pending, '\n')
+		if i < 0 {
+			return
+		}
+		if line := strings.TrimRight(string(b.pending[:i]), "\r"); line != "" {
+			b.log.Printf("< %s", line)
+		}
+		b.pending = b.pending[i+1:]
+	}
+}
+
+// finish logs the rest of the body and the total time, once
+func (b *debugBody) finish() {
+	if b.done {
+		return
+	}
+	b.done = true
+
+	if len(b.pending) > 0 {
+		b.log.Printf("< %s", b.pending)
+		b.pending = nil
+	}
+	b.log.Printf("< end of body after %s, %d bytes", time.Since(b.start).Round(time.Millisecond), b.size)
+}
+
+// redactError hides API keys passed as query parameters in the URL of a transport error
+func redactError(err error) error {
+	urlErr, ok := err.(*url.Error)
+	if !ok {
+		return err
+	}
+
+	masked := *urlErr
+	if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+		masked.URL = redactURL(u)
+	} else {
+		// A URL that doesn't parse loses its whole query
+		masked.URL, _, _ = strings.Cut(urlErr.URL, "?")
+	}
+	return &masked
+}
+
+// redactURL hides API keys passed as query parameters
+func redactURL(u *url.URL) string {
+	query := u.Query()
+	if !query.Has("key") {
+		return u.String()
+	}
+
+	query.Set("key", redacted)
+	masked := *u
+	masked.RawQuery = query.Encode()
+	return masked.String()
+}
+
+// redactCredential hides a header credential, keeping its scheme such as Bearer
+func redactCredential(value string) string {
+	if scheme, _, found := strings.Cut(value, " "); found {
+		return scheme + " " + redacted
+	}
+	return redacted
+}
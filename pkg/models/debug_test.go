@@ -0,0 +1,59 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDebugOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"model\":\"gpt-test\",\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	SetDebugOutput(&logs)
+	defer SetDebugOutput(nil)
+
+	model := NewOpenAIModel(&ModelConfig{Name: "gpt-test", URL: server.URL, APIKey: "sk-secret", Headers: map[string]string{
+		"X-Auth-Token":              "token-secret",
+		"Ocp-Apim-Subscription-Key": "subscription-secret",
+		"X-Team":                    "ai",
+	}})
+	if _, err := model.Chat(context.Background(), "hello", WithStream(true)); err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+
+	output := logs.String()
+	for _, want := range []string{
+		"> POST " + server.URL + "/v1/chat/completions",
+		"> Authorization: Bearer ***",
+		"> X-Auth-Token: ***",
+		"> X-Team: ai",
+		`"content": "hello"`,
+		"< Content-Type: text/event-stream",
+		`< data: {"model":"gpt-test"`,
+		"< data: [DONE]",
+		"end of body after",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected debug output to contain %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "secret") {
+		t.Errorf("Expected the API key and custom credentials to be redacted, got:\n%s", output)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	req := httptest.NewRequest("POST", "https://example.com/v1beta/models/gemini:generateContent?alt=sse&key=secret", nil)
+	if got := redactURL(req.URL); strings.Contains(got, "secret") || !strings.Contains(got, "alt=sse") {
+		t.Errorf("Expected the key to be redacted and other parameters kept, got %s", got)
+	}
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RemoteModel describes a model reported by a provider's model-listing endpoint
+type RemoteModel struct {
+	ID            string `json:"id"`
+	OwnedBy       string `json:"owned_by,omitempty"`
+	ContextLength int    `json:"context_length,omitempty"`
+}
+
+// openAIModelList represents the /v1/models response
+type openAIModelList struct {
+	Data []struct {
+		ID      string `json:"id"`
+		OwnedBy string `json:"owned_by"`
+		// Gateways report the context size under different names
+		ContextLength int `json:"context_length"`
+		ContextWindow int `json:"context_window"`
+		MaxModelLen   int `json:"max_model_len"`
+	} `json:"data"`
+}
+
+// geminiModelList represents the Gemini models.list response
+type geminiModelList struct {
+	Models []struct {
+		Name                       string   `json:"name"`
+		InputTokenLimit            int      `json:"inputTokenLimit"`
+		SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	} `json:"models"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// DiscoverModels lists the models served by a provider.
+// The provider is detected from the URL when empty.
+func DiscoverModels(ctx context.Context, provider, apiURL, apiKey string) ([]RemoteModel, error) {
+	if err := CheckProvider(provider); err != nil {
+		return nil, err
+	}
+	httpClient := newHTTPClient(TransportConfig{Timeout: 30 * time.Second})
+
+	var (
+		result []RemoteModel
+		err    error
+	)
+	if determineModelType(provider, "", apiURL) == "gemini" {
+		result, err = discoverGeminiModels(ctx, httpClient, apiURL, apiKey)
+	} else {
+		result, err = discoverOpenAIModels(ctx, httpClient, apiURL, apiKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result, nil
+}
+
+// discoverOpenAIModels calls the OpenAI-compatible /v1/models endpoint
+func discoverOpenAIModels(ctx context.Context, httpClient *http.Client, apiURL, apiKey string) ([]RemoteModel, error) {
+	if !strings.HasSuffix(apiURL, "/") {
+		apiURL += "/"
+	}
+	apiURL += "v1/models"
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	if apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	var list openAIModelList
+	if err := doDiscoverRequest(httpClient, httpReq, &list); err != nil {
+		return nil, err
+	}
+
+	result := make([]RemoteModel, 0, len(list.Data))
+	for _, m := range list.Data {
+		contextLength := m.ContextLength
+		if contextLength == 0 {
+			contextLength = m.ContextWindow
+		}
+		if contextLength == 0 {
+			contextLength = m.MaxModelLen
+		}
+
+		result = append(result, RemoteModel{
+			ID:            m.ID,
+			OwnedBy:       m.OwnedBy,
+			ContextLength: contextLength,
+		})
+	}
+
+	return result, nil
+}
+
+// discoverGeminiModels calls the Gemini models.list endpoint, following pagination
+func discoverGeminiModels(ctx context.Context, httpClient *http.Client, apiURL, apiKey string) ([]RemoteModel, error) {
+	if apiURL == "" {
+		apiURL = defaultGeminiURL
+	}
+	apiURL = strings.TrimSuffix(apiURL, "/") + "/v1beta/models"
+
+	var result []RemoteModel
+	pageToken := ""
+	for {
+		query := url.Values{}
+		query.Set("key", apiKey)
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, "GET", apiURL+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create HTTP request: %w", redactError(err))
+		}
+
+		var list geminiModelList
+		if err := doDiscoverRequest(httpClient, httpReq, &list); err != nil {
+			return nil, err
+		}
+
+		for _, m := range list.Models {
+			// Only keep models that can be used for chat
+			canGenerate := false
+			for _, method := range m.SupportedGenerationMethods {
+				if method == "generateContent" {
+					canGenerate = true
+					break
+				}
+			}
+			if !canGenerate {
+				continue
+			}
+
+			result = append(result, RemoteModel{
+				ID:            strings.TrimPrefix(m.Name, "models/"),
+				OwnedBy:       "google",
+				ContextLength: m.InputTokenLimit,
+			})
+		}
+
+		if list.NextPageToken == "" {
+			return result, nil
+		}
+		pageToken = list.NextPageToken
+	}
+}
+
+// doDiscoverRequest sends a model-listing request and decodes the JSON response
+func doDiscoverRequest(httpClient *http.Client, httpReq *http.Request, v interface{}) error {
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", redactError(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return nil
+}
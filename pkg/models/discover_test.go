@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscoverModels_OpenAI(t *testing.T) {
+	// Create mock server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("Expected path /v1/models, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-api-key" {
+			t.Errorf("Expected bearer token, got %s", r.Header.Get("Authorization"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"object":"list","data":[
+			{"id":"qwen-7b","owned_by":"vllm","max_model_len":32768},
+			{"id":"gpt-4o-mini","owned_by":"openai","context_length":128000},
+			{"id":"llama-3","owned_by":"groq","context_window":8192},
+			{"id":"embed","owned_by":"openai"}
+		]}`))
+	}))
+	defer server.Close()
+
+	result, err := DiscoverModels(context.Background(), "", server.URL, "test-api-key")
+	if err != nil {
+		t.Fatalf("DiscoverModels failed: %v", err)
+	}
+
+	expected := []RemoteModel{
+		{ID: "embed", OwnedBy: "openai"},
+		{ID: "gpt-4o-mini", OwnedBy: "openai", ContextLength: 128000},
+		{ID: "llama-3", OwnedBy: "groq", ContextLength: 8192},
+		{ID: "qwen-7b", OwnedBy: "vllm", ContextLength: 32768},
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d models, got %d", len(expected), len(result))
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Errorf("Expected model %d to be %+v, got %+v", i, expected[i], result[i])
+		}
+	}
+}
+
+func TestDiscoverModels_Gemini(t *testing.T) {
+	// Create mock server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "test-api-key" {
+			t.Errorf("Expected key query parameter")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("pageToken") == "" {
+			w.Write([]byte(`{"models":[{"name":"models/gemini-1.5-flash","inputTokenLimit":1000000,"supportedGenerationMethods":["generateContent"]}],"nextPageToken":"next"}`))
+			return
+		}
+		w.Write([]byte(`{"models":[{"name":"models/text-embedding-004","supportedGenerationMethods":["embedContent"]}]}`))
+	}))
+	defer server.Close()
+
+	result, err := DiscoverModels(context.Background(), "gemini", server.URL, "test-api-key")
+	if err != nil {
+		t.Fatalf("DiscoverModels failed: %v", err)
+	}
+
+	if len(result) != 1 || result[0].ID != "gemini-1.5-flash" || result[0].ContextLength != 1000000 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+}
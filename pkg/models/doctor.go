@@ -0,0 +1,237 @@
+package models
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Names of the checks run by Diagnose
+const (
+	CheckEndpoint       = "Endpoint"
+	CheckDNS            = "DNS"
+	CheckConnection     = "Connection"
+	CheckAuthentication = "Authentication"
+	CheckModel          = "Model"
+)
+
+// Result of a check
+const (
+	CheckPassed  = "ok"
+	CheckFailed  = "failed"
+	CheckSkipped = "skipped"
+)
+
+// dialTimeout limits the connection checks of Diagnose
+const dialTimeout = 10 * time.Second
+
+// Check is the outcome of one step of Diagnose, explained in plain language
+type Check struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+// Diagnose checks that a model can be used: its URL, DNS resolution, the TCP and
+// TLS connection, the API key and the availability of the model, by sending a
+// minimal request. Checks after a failed one are skipped.
+func Diagnose(ctx context.Context, config *ModelConfig) []Check {
+	var checks []Check
+	failed := false
+	add := func(name, status, detail string) {
+		if failed {
+			status, detail = CheckSkipped, "an earlier check failed"
+		}
+		checks = append(checks, Check{Name: name, Status: status, Detail: detail})
+		failed = failed || status == CheckFailed
+	}
+
+	provider := determineModelType(config.Provider, config.Name, config.URL)
+	apiURL := config.URL
+	if apiURL == "" && provider == "gemini" {
+		apiURL = defaultGeminiURL
+	}
+
+	endpoint, err := url.Parse(apiURL)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		add(CheckEndpoint, CheckFailed, fmt.Sprintf("%q is not a valid http(s) URL, check the url of the model", apiURL))
+	} else {
+		add(CheckEndpoint, CheckPassed, fmt.Sprintf("%s API at %s", provider, apiURL))
+	}
+
+	if config.Proxy != "" {
+		add(CheckDNS, CheckSkipped, "requests go through the proxy "+config.Proxy)
+		add(CheckConnection, CheckSkipped, "requests go through the proxy "+config.Proxy)
+	} else if !failed {
+		add(diagnoseDNS(ctx, endpoint.Hostname()))
+		add(diagnoseConnection(ctx, endpoint, config.InsecureSkipVerify))
+	} else {
+		add(CheckDNS, CheckSkipped, "")
+		add(CheckConnection, CheckSkipped, "")
+	}
+
+	if failed {
+		add(CheckAuthentication, CheckSkipped, "")
+		add(CheckModel, CheckSkipped, "")
+		return checks
+	}
+
+	model, err := CreateModel(config)
+	if err != nil {
+		add(CheckAuthentication, CheckFailed, err.Error())
+		add(CheckModel, CheckSkipped, "")
+		return checks
+	}
+
+	info := &ResponseInfo{}
+	_, err = model.Chat(ctx, "Reply with OK.", WithStream(false), WithMaxTokens(16), WithHistory(nil), WithSystemPrompt(""), WithResponseInfo(info))
+	if err == nil {
+		add(CheckAuthentication, CheckPassed, "the API key was accepted")
+		detail := fmt.Sprintf("%s answered in %s", config.Name, info.Latency.Round(time.Millisecond))
+		if info.UpstreamModel != "" {
+			detail += ", served as " + info.UpstreamModel
+		}
+		add(CheckModel, CheckPassed, detail)
+		return checks
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		add(CheckAuthentication, CheckFailed, "the request failed before the API answered: "+explainNetworkError(err))
+		add(CheckModel, CheckSkipped, "")
+		return checks
+	}
+
+	body := strings.ToLower(apiErr.Body)
+	switch {
+	case apiErr.StatusCode == 401 || apiErr.StatusCode == 403 || strings.Contains(body, "api key") || strings.Contains(body, "api_key"):
+		add(CheckAuthentication, CheckFailed, fmt.Sprintf("the API key was rejected (status %d), check the api_key of the model or its provider", apiErr.StatusCode))
+		add(CheckModel, CheckSkipped, "")
+	case apiErr.StatusCode == 429:
+		add(CheckAuthentication, CheckPassed, "the API key was accepted")
+		add(CheckModel, CheckFailed, "rate limited or out of quota (status 429), check the plan and billing of the account")
+	case apiErr.StatusCode == 404 || (apiErr.StatusCode == 400 && strings.Contains(body, "model")):
+		add(CheckAuthentication, CheckPassed, "the API key was accepted")
+		add(CheckModel, CheckFailed, fmt.Sprintf("model %q is not available at this endpoint (status %d)%s", config.Name, apiErr.StatusCode, suggestModels(ctx, provider, apiURL, config)))
+	case apiErr.StatusCode >= 500:
+		add(CheckAuthentication, CheckSkipped, "the server failed before checking the request")
+		add(CheckModel, CheckFailed, fmt.Sprintf("the server returned an error (status %d), try again later: %s", apiErr.StatusCode, apiErr.Body))
+	default:
+		add(CheckAuthentication, CheckPassed, "the API key was accepted")
+		add(CheckModel, CheckFailed, fmt.Sprintf("the request was rejected (status %d): %s", apiErr.StatusCode, apiErr.Body))
+	}
+	return checks
+}
+
+// diagnoseDNS resolves the API host
+func diagnoseDNS(ctx context.Context, host string) (string, string, string) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return CheckDNS, CheckFailed, fmt.Sprintf("cannot resolve %s, check the host name in the URL and your network or DNS settings", host)
+	}
+	return CheckDNS, CheckPassed, fmt.Sprintf("%s resolves to %s", host, strings.Join(addrs, ", "))
+}
+
+// diagnoseConnection opens a TCP connection to the API, with a TLS handshake for https
+func diagnoseConnection(ctx context.Context, endpoint *url.URL, insecureSkipVerify bool) (string, string, string) {
+	address := endpoint.Host
+	if endpoint.Port() == "" {
+		port := "443"
+		if endpoint.Scheme == "http" {
+			port = "80"
+		}
+		address = net.JoinHostPort(endpoint.Hostname(), port)
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	if endpoint.Scheme == "http" {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return CheckConnection, CheckFailed, explainNetworkError(err)
+		}
+		conn.Close()
+		return CheckConnection, CheckPassed, fmt.Sprintf("connected to %s without TLS", address)
+	}
+
+	tlsDialer := &tls.Dialer{
+		NetDialer: dialer,
+		Config:    &tls.Config{ServerName: endpoint.Hostname(), InsecureSkipVerify: insecureSkipVerify},
+	}
+	conn, err := tlsDialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return CheckConnection, CheckFailed, explainNetworkError(err)
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	detail := fmt.Sprintf("connected to %s with %s", address, tls.VersionName(state.Version))
+	if len(state.PeerCertificates) > 0 {
+		detail += ", certificate valid until " + state.PeerCertificates[0].NotAfter.Format("2006-01-02")
+	}
+	if insecureSkipVerify {
+		detail += " (certificate not verified)"
+	}
+	return CheckConnection, CheckPassed, detail
+}
+
+// explainNetworkError describes common connection and certificate errors in plain language
+func explainNetworkError(err error) string {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		invalidCert      x509.CertificateInvalidError
+		dnsErr           *net.DNSError
+		netErr           net.Error
+	)
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return "the certificate is signed by an unknown authority, as with self-signed certificates or intercepting proxies; install the CA certificate or set insecure_skip_verify"
+	case errors.As(err, &hostnameErr):
+		return fmt.Sprintf("the certificate is not valid for %s, check the host name in the URL", hostnameErr.Host)
+	case errors.As(err, &invalidCert) && invalidCert.Reason == x509.Expired:
+		return "the certificate has expired or is not valid yet, check the server certificate and the system clock"
+	case errors.As(err, &invalidCert):
+		return "the certificate is invalid: " + invalidCert.Error()
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("cannot resolve %s, check the host name in the URL and your network or DNS settings", dnsErr.Name)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "the connection was refused, nothing is listening at this address; check the URL and port"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "the connection timed out, a firewall or a required proxy may be blocking it"
+	case strings.Contains(err.Error(), "first record does not look like a TLS handshake"):
+		return "the server does not speak TLS, try an http:// URL"
+	default:
+		return err.Error()
+	}
+}
+
+// suggestModels lists models served by the endpoint when the configured one is missing
+func suggestModels(ctx context.Context, provider, apiURL string, config *ModelConfig) string {
+	available, err := DiscoverModels(ctx, provider, apiURL, config.APIKey)
+	if err != nil || len(available) == 0 {
+		return ""
+	}
+
+	const maxSuggestions = 5
+	names := make([]string, 0, maxSuggestions)
+	for _, model := range available {
+		if model.ID == config.Name {
+			return ""
+		}
+		if len(names) < maxSuggestions {
+			names = append(names, model.ID)
+		}
+	}
+	suffix := ""
+	if len(available) > maxSuggestions {
+		suffix = fmt.Sprintf(" and %d more", len(available)-maxSuggestions)
+	}
+	return fmt.Sprintf(", the endpoint serves %s%s", strings.Join(names, ", "), suffix)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Authorization") != "Bearer sk-good":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid key"}`))
+		case r.URL.Path == "/v1/models":
+			w.Write([]byte(`{"data":[{"id":"gpt-4o"},{"id":"gpt-4o-mini"}]}`))
+		default:
+			var req OpenAIRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Model != "gpt-4o" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":"model not found"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(OpenAIResponse{Model: "gpt-4o-2024-08-06", Choices: []Choice{{Message: Message{Role: "assistant", Content: "OK"}}}})
+		}
+	}))
+	defer server.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name   string
+		config ModelConfig
+		want   map[string]string
+	}{
+		{
+			name:   "working model",
+			config: ModelConfig{Name: "gpt-4o", URL: server.URL, APIKey: "sk-good"},
+			want:   map[string]string{CheckDNS: CheckPassed, CheckConnection: CheckPassed, CheckAuthentication: CheckPassed, CheckModel: CheckPassed},
+		},
+		{
+			name:   "bad key",
+			config: ModelConfig{Name: "gpt-4o", URL: server.URL, APIKey: "sk-bad"},
+			want:   map[string]string{CheckAuthentication: CheckFailed, CheckModel: CheckSkipped},
+		},
+		{
+			name:   "unknown model",
+			config: ModelConfig{Name: "gpt-5x", URL: server.URL, APIKey: "sk-good"},
+			want:   map[string]string{CheckAuthentication: CheckPassed, CheckModel: CheckFailed},
+		},
+		{
+			name:   "server down",
+			config: ModelConfig{Name: "gpt-4o", URL: closed.URL, APIKey: "sk-good"},
+			want:   map[string]string{CheckConnection: CheckFailed, CheckAuthentication: CheckSkipped},
+		},
+		{
+			name:   "invalid URL",
+			config: ModelConfig{Name: "gpt-4o", URL: "api.openai.com", APIKey: "sk-good"},
+			want:   map[string]string{CheckEndpoint: CheckFailed, CheckDNS: CheckSkipped},
+		},
+	}
+
+	for _, tt := range tests {
+		checks := Diagnose(context.Background(), &tt.config)
+		got := make(map[string]Check)
+		for _, check := range checks {
+			got[check.Name] = check
+		}
+		for name, status := range tt.want {
+			if got[name].Status != status {
+				t.Errorf("%s: %s = %s (%s), want %s", tt.name, name, got[name].Status, got[name].Detail, status)
+			}
+		}
+	}
+
+	checks := Diagnose(context.Background(), &ModelConfig{Name: "gpt-5x", URL: server.URL, APIKey: "sk-good"})
+	if detail := checks[len(checks)-1].Detail; !strings.Contains(detail, "gpt-4o-mini") {
+		t.Errorf("Expected available models to be suggested, got %q", detail)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pokitpeng/ai/pkg/models"
+)
+
+func main() {
+	// Get API key from environment variable
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		log.Fatal("Please set the OPENAI_API_KEY environment variable")
+	}
+
+	// Create model configuration
+	config := &models.ModelConfig{
+		Name:   "gpt-4o-mini",
+		URL:    "https://api.openai.com",
+		APIKey: apiKey,
+	}
+
+	// Log requests and responses at debug level
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	// Print how long each call took
+	timing := models.Timing(func(model string, elapsed time.Duration, err error) {
+		fmt.Fprintf(os.Stderr, "%s answered in %s (error: %v)\n", model, elapsed, err)
+	})
+
+	// A custom policy: refuse questions mentioning secrets without calling the API
+	policy := models.Interceptor(func(ctx context.Context, call *models.Call, next models.Handler) (string, error) {
+		if strings.Contains(strings.ToLower(call.Question), "password") {
+			return "", fmt.Errorf("question refused by policy")
+		}
+		return next(ctx, call)
+	})
+
+	// The first middleware sees each call first
+	model := models.Chain(models.NewOpenAIModel(config), models.Logging(logger), timing, policy)
+
+	answer, err := model.Chat(context.Background(), "What is a goroutine?", models.WithStream(false))
+	if err != nil {
+		log.Fatalf("Question failed: %v", err)
+	}
+
+	fmt.Println("Answer:")
+	fmt.Println(answer)
+
+	// Models from a ModelManager can be wrapped the same way:
+	//   manager.Use(models.Logging(logger), timing)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pokitpeng/ai/pkg/models"
+)
+
+func main() {
+	// Get API key from environment variable
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		log.Fatal("Please set the OPENAI_API_KEY environment variable")
+	}
+
+	// Create model configuration
+	config := &models.ModelConfig{
+		Name:   "gpt-4o-mini",
+		URL:    "https://api.openai.com",
+		APIKey: apiKey,
+	}
+	model := models.NewOpenAIModel(config)
+
+	// Register the functions the model may call, with the JSON schema of their arguments
+	registry := models.NewToolRegistry()
+	registry.SetMaxSteps(5)
+	err := registry.Register("current_time", "Get the current time in a time zone",
+		json.RawMessage(`{"type":"object","properties":{"zone":{"type":"string","description":"IANA time zone, such as Europe/Paris"}},"required":["zone"]}`),
+		func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct {
+				Zone string `json:"zone"`
+			}
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			location, err := time.LoadLocation(args.Zone)
+			if err != nil {
+				return "", err
+			}
+			return time.Now().In(location).Format(time.RFC1123), nil
+		})
+	if err != nil {
+		log.Fatalf("Failed to register tool: %v", err)
+	}
+
+	// Run asks the model, calls the tools it asks for and sends back their output until it answers
+	info := &models.ResponseInfo{}
+	answer, err := registry.Run(context.Background(), model, "What time is it in Tokyo and in Paris?",
+		models.WithStream(false), models.WithResponseInfo(info))
+	if err != nil {
+		log.Fatalf("Question failed: %v", err)
+	}
+
+	for _, result := range info.ToolResults {
+		fmt.Printf("Called %s(%s): %s\n", result.Call.Function.Name, result.Call.Function.Arguments, result.Output)
+	}
+	fmt.Println("\nAnswer:")
+	fmt.Println(answer)
+	fmt.Printf("\nTokens: %d\n", info.Usage.TotalTokens)
+}
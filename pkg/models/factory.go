@@ -2,28 +2,52 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 )
 
+// ErrUnknownProvider is returned for a provider that is set but not supported
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// CheckProvider fails when provider is set but not supported, an empty provider is detected from the model
+func CheckProvider(provider string) error {
+	if !knownProviderTypes[strings.ToLower(provider)] {
+		return fmt.Errorf("%w %q, use openai, anthropic or gemini", ErrUnknownProvider, provider)
+	}
+	return nil
+}
+
 // Factory function for creating model instances
 func CreateModel(config *ModelConfig) (Model, error) {
+	// An explicit provider never falls back to another API
+	if err := CheckProvider(config.Provider); err != nil {
+		return nil, err
+	}
+
 	// Determine model type based on name or URL characteristics
-	modelType := determineModelType(config.Name, config.URL)
+	modelType := determineModelType(config.Provider, config.Name, config.URL)
 
 	switch modelType {
 	case "openai":
 		return NewOpenAIModel(config), nil
 	case "anthropic":
 		return NewAnthropicModel(config), nil
+	case "gemini":
+		return NewGeminiModel(config), nil
 	default:
 		// Use generic model by default
 		return NewOpenAIModel(config), nil
 	}
 }
 
-// Determine model type based on name and URL
-func determineModelType(name, url string) string {
+// Determine model type based on provider, name and URL
+func determineModelType(provider, name, url string) string {
+	// An explicitly configured provider always wins
+	if provider != "" {
+		return strings.ToLower(provider)
+	}
+
 	name = strings.ToLower(name)
 
 	// Determine model type based on name
@@ -33,11 +57,17 @@ func determineModelType(name, url string) string {
 	if strings.Contains(name, "anthropic") || strings.Contains(name, "claude") {
 		return "anthropic"
 	}
+	if strings.Contains(name, "gemini") {
+		return "gemini"
+	}
 
 	// Determine model type based on URL
 	if strings.Contains(url, "openai.com") {
 		return "openai"
 	}
+	if strings.Contains(url, "generativelanguage.googleapis.com") {
+		return "gemini"
+	}
 
 	// Default to openai model
 	return "openai"
@@ -52,6 +82,27 @@ func (m *baseModel) Name() string {
 	return m.config.Name
 }
 
+// chatOptions applies the model defaults and the user-provided options
+func (m *baseModel) chatOptions(options []ChatOption) *ChatOptions {
+	// Apply default options from model config if available
+	var opts *ChatOptions
+	if m.config.DefaultChatOptions != nil {
+		// Create a copy of default options
+		defaultOpts := *m.config.DefaultChatOptions
+		opts = &defaultOpts
+	} else {
+		// Use global defaults
+		opts = DefaultChatOptions()
+	}
+
+	// Apply user-provided options
+	for _, option := range options {
+		option(opts)
+	}
+
+	return opts
+}
+
 // OpenAIModel implementation
 type OpenAIModel struct {
 	baseModel
@@ -93,7 +144,16 @@ func (m *AnthropicModel) Chat(ctx context.Context, question string, options ...C
 		option(opts)
 	}
 
+	if len(opts.Tools) > 0 {
+		return "", ErrToolsNotSupported
+	}
+	if err := checkImages(m.config, buildMessages(opts, question)); err != nil {
+		return "", err
+	}
+
 	// Implement actual Anthropic API call here
+	start := opts.ResponseInfo.start("anthropic", m.config.Name, opts)
+	defer opts.ResponseInfo.finish(start)
 	return fmt.Sprintf("[Anthropic] Response to: %s", question), nil
 }
 
@@ -114,6 +174,15 @@ func (m *AnthropicModel) ChatWithFile(ctx context.Context, question string, file
 		option(opts)
 	}
 
+	if len(opts.Tools) > 0 {
+		return "", ErrToolsNotSupported
+	}
+	if err := checkImages(m.config, fileMessages(opts, question, fileName, fileContent)); err != nil {
+		return "", err
+	}
+
 	// Implement actual Anthropic API call here
+	start := opts.ResponseInfo.start("anthropic", m.config.Name, opts)
+	defer opts.ResponseInfo.finish(start)
 	return fmt.Sprintf("[Anthropic] Response to file %s question: %s", fileName, question), nil
 }
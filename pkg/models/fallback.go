@@ -0,0 +1,120 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// Fallback is a failed attempt of a FallbackModel, before the next model was tried
+type Fallback struct {
+	Model string
+	Err   error
+}
+
+// IsRetryable reports whether another model may succeed where a request failed:
+// rate limits, timeouts, server errors and connection failures. Rejected requests,
+// such as a wrong API key, and canceled requests are not retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusRequestTimeout ||
+			apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// FallbackModel asks a model and, when it fails with a retryable error before
+// streaming any text, each of its fallbacks in turn. The ResponseInfo describes
+// the model that answered and lists the failed attempts in Fallbacks.
+type FallbackModel struct {
+	models []Model
+}
+
+// NewFallbackModel creates a model trying primary, then the fallbacks in order
+func NewFallbackModel(primary Model, fallbacks ...Model) *FallbackModel {
+	return &FallbackModel{models: append([]Model{primary}, fallbacks...)}
+}
+
+// Name returns the name of the primary model
+func (m *FallbackModel) Name() string {
+	return m.models[0].Name()
+}
+
+// Unwrap returns the primary model
+func (m *FallbackModel) Unwrap() Model {
+	return m.models[0]
+}
+
+// Chat asks the models in turn until one answers
+func (m *FallbackModel) Chat(ctx context.Context, question string, options ...ChatOption) (string, error) {
+	return m.try(ctx, options, func(model Model, options []ChatOption) (string, error) {
+		return model.Chat(ctx, question, options...)
+	})
+}
+
+// ChatWithFile asks the models about a file in turn until one answers
+func (m *FallbackModel) ChatWithFile(ctx context.Context, question string, fileName string, fileContent string, options ...ChatOption) (string, error) {
+	return m.try(ctx, options, func(model Model, options []ChatOption) (string, error) {
+		return model.ChatWithFile(ctx, question, fileName, fileContent, options...)
+	})
+}
+
+// try calls ask with each model until one succeeds or fails in a way another model can't fix
+func (m *FallbackModel) try(ctx context.Context, options []ChatOption, ask func(Model, []ChatOption) (string, error)) (string, error) {
+	opts := &ChatOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+	handler := opts.StreamHandler
+
+	var failed []Fallback
+	for i, model := range m.models {
+		// Once text was shown, answering with another model would mix two answers
+		streamed := false
+		info := &ResponseInfo{}
+		attemptOptions := append(options[:len(options):len(options)],
+			WithResponseInfo(info),
+			WithStreamHandler(func(chunk string) {
+				streamed = true
+				if handler != nil {
+					handler(chunk)
+				} else {
+					fmt.Print(chunk)
+				}
+			}),
+		)
+
+		answer, err := ask(model, attemptOptions)
+		if err != nil && !streamed && IsRetryable(err) && ctx.Err() == nil && i < len(m.models)-1 {
+			failed = append(failed, Fallback{Model: model.Name(), Err: err})
+			continue
+		}
+
+		if opts.ResponseInfo != nil {
+			*opts.ResponseInfo = *info
+			opts.ResponseInfo.Fallbacks = failed
+		}
+		if err == nil && streamed {
+			endStream(handler)
+		}
+		return answer, err
+	}
+
+	// Not reached, the last model always returns
+	return "", errors.New("no model to ask")
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// partialModel streams part of an answer, then fails
+type partialModel struct{}
+
+func (m *partialModel) Name() string { return "partial" }
+
+func (m *partialModel) Chat(ctx context.Context, question string, options ...ChatOption) (string, error) {
+	opts := &ChatOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+	opts.StreamHandler("half an ")
+	return "", &APIError{StatusCode: 502}
+}
+
+func (m *partialModel) ChatWithFile(ctx context.Context, question string, fileName string, fileContent string, options ...ChatOption) (string, error) {
+	return m.Chat(ctx, question, options...)
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&APIError{StatusCode: 429}, true},
+		{&APIError{StatusCode: 503}, true},
+		{fmt.Errorf("failed to send request: %w", syscall.ECONNREFUSED), true},
+		{&APIError{StatusCode: 401}, false},
+		{&APIError{StatusCode: 400}, false},
+		{fmt.Errorf("failed to send request: %w", context.Canceled), false},
+		{errors.New("failed to parse response"), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFallbackModel(t *testing.T) {
+	down := &chunkModel{name: "down", err: &APIError{StatusCode: 503}}
+	limited := &chunkModel{name: "limited", err: &APIError{StatusCode: 429}}
+	up := &chunkModel{name: "up", answer: "hello there"}
+
+	var streamed strings.Builder
+	info := &ResponseInfo{}
+	model := NewFallbackModel(down, limited, up)
+	answer, err := model.Chat(context.Background(), "q", WithResponseInfo(info), WithStreamHandler(func(chunk string) { streamed.WriteString(chunk) }))
+	if err != nil || answer != "hello there" || streamed.String() != "hello there" {
+		t.Fatalf("Expected the last model to answer, got %q (streamed %q), %v", answer, streamed.String(), err)
+	}
+	if model.Name() != "down" || info.Model != "up" {
+		t.Errorf("Expected the primary name and the answering model in the info, got %s and %s", model.Name(), info.Model)
+	}
+	if len(info.Fallbacks) != 2 || info.Fallbacks[0].Model != "down" || info.Fallbacks[1].Model != "limited" {
+		t.Errorf("Expected both failures to be recorded, got %+v", info.Fallbacks)
+	}
+
+	// Errors another model can't fix are returned at once
+	rejected := &chunkModel{name: "rejected", err: &APIError{StatusCode: 401}}
+	if _, err := NewFallbackModel(rejected, up).Chat(context.Background(), "q", WithStreamHandler(func(string) {})); err == nil {
+		t.Error("Expected the authentication error to be returned")
+	}
+
+	// Once text was streamed, the answer can't be completed by another model
+	info = &ResponseInfo{}
+	streamed.Reset()
+	_, err = NewFallbackModel(&partialModel{}, up).Chat(context.Background(), "q", WithResponseInfo(info), WithStreamHandler(func(chunk string) { streamed.WriteString(chunk) }))
+	if err == nil || streamed.String() != "half an " || len(info.Fallbacks) != 0 {
+		t.Errorf("Expected the partial answer to fail without fallback, got %q, %v, %+v", streamed.String(), err, info.Fallbacks)
+	}
+
+	// The last error is returned when every model fails
+	info = &ResponseInfo{}
+	_, err = NewFallbackModel(down, limited).Chat(context.Background(), "q", WithResponseInfo(info))
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 429 || len(info.Fallbacks) != 1 {
+		t.Errorf("Expected the error of the last model, got %v with %+v", err, info.Fallbacks)
+	}
+}
+
+func TestModelManager_GetModelWithFallbacks(t *testing.T) {
+	m := newTestManager(t, `version: 2
+defaults:
+  model: a
+  fallbacks: [d, a]
+models:
+  a:
+    url: https://a
+    fallbacks: [c]
+  b:
+    url: https://b
+    tags: [local]
+  c:
+    url: https://c
+    tags: [local]
+  d:
+    url: https://d
+groups:
+  pair: [b, a]
+`)
+
+	chain := func(model Model) string {
+		fallback, ok := model.(*FallbackModel)
+		if !ok {
+			return model.Name()
+		}
+		names := make([]string, len(fallback.models))
+		for i, model := range fallback.models {
+			names[i] = model.Name()
+		}
+		return strings.Join(names, ",")
+	}
+
+	tests := map[string]string{
+		"":       "a,c,d",
+		"a":      "a,c,d",
+		"b":      "b,d,a",
+		"@pair":  "b,a,d",
+		"@local": "b,c,d,a",
+	}
+	for name, want := range tests {
+		model, err := m.GetModelWithFallbacks(name)
+		if err != nil {
+			t.Fatalf("GetModelWithFallbacks(%q) failed: %v", name, err)
+		}
+		if got := chain(model); got != want {
+			t.Errorf("GetModelWithFallbacks(%q) tries %s, want %s", name, got, want)
+		}
+	}
+
+	if _, err := m.GetModelWithFallbacks("missing"); !errors.Is(err, ErrModelNotFound) {
+		t.Errorf("Expected ErrModelNotFound, got %v", err)
+	}
+
+	// Removed models leave the chains
+	if err := m.RemoveModel("d"); err != nil {
+		t.Fatalf("RemoveModel failed: %v", err)
+	}
+	if defaults := m.GetDefaults(); strings.Join(defaults.Fallbacks, ",") != "a" {
+		t.Errorf("Expected d to be removed from the global fallbacks, got %v", defaults.Fallbacks)
+	}
+	if err := m.SetDefaults(DefaultsConfig{Fallbacks: []string{"nope"}}); err == nil {
+		t.Error("Expected an error for an unknown fallback")
+	}
+}
@@ -0,0 +1,366 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Default Gemini API endpoint
+const defaultGeminiURL = "https://generativelanguage.googleapis.com"
+
+// GeminiRequest represents a generateContent request body
+type GeminiRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GeminiContent represents a single turn in a Gemini conversation
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart represents a part of a Gemini content
+type GeminiPart struct {
+	Text string `json:"text,omitempty"`
+	// InlineData is an image sent with the request
+	InlineData *GeminiInlineData `json:"inlineData,omitempty"`
+}
+
+// GeminiInlineData represents base64 data of a Gemini part
+type GeminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// GeminiGenerationConfig represents the generation parameters
+type GeminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	// ResponseMimeType is application/json to ask for a JSON reply
+	ResponseMimeType string `json:"responseMimeType,omitempty"`
+}
+
+// GeminiResponse represents a generateContent response
+type GeminiResponse struct {
+	Candidates    []GeminiCandidate    `json:"candidates"`
+	UsageMetadata *GeminiUsageMetadata `json:"usageMetadata,omitempty"`
+}
+
+// GeminiCandidate represents a candidate returned by the API
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+// GeminiUsageMetadata represents the token usage of the API call
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// GeminiClient implements the Google Gemini API client
+type GeminiClient struct {
+	apiKey     string
+	apiURL     string
+	headers    map[string]string
+	httpClient *http.Client
+	model      string
+}
+
+// NewGeminiClient creates a new Gemini client
+func NewGeminiClient(modelConfig ModelConfig) *GeminiClient {
+	apiURL := modelConfig.URL
+	if apiURL == "" {
+		apiURL = defaultGeminiURL
+	}
+
+	return &GeminiClient{
+		apiKey:     modelConfig.APIKey,
+		apiURL:     apiURL,
+		headers:    modelConfig.Headers,
+		httpClient: newHTTPClient(modelConfig.TransportConfig),
+		model:      modelConfig.Name,
+	}
+}
+
+// Chat sends a chat request
+func (c *GeminiClient) Chat(ctx context.Context, messages []Message, opts *ChatOptions) (string, error) {
+	// Prepare request
+	req := buildGeminiRequest(messages, opts)
+
+	// Convert request to JSON
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	// Create HTTP request
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.endpoint(opts.Stream), bytes.NewBuffer(reqBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTTP request: %w", redactError(err))
+	}
+
+	// Set request headers
+	httpReq.Header.Set("Content-Type", "application/json")
+	setCustomHeaders(httpReq, c.headers)
+
+	// Send request
+	info := opts.ResponseInfo
+	start := info.start("gemini", c.model, opts)
+	defer info.finish(start)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to send request: %w", redactError(err))
+	}
+	defer resp.Body.Close()
+
+	// Check response status
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError(resp)
+	}
+
+	// Handle stream response
+	if opts.Stream {
+		return c.handleStreamResponse(resp.Body, opts.StreamHandler, info)
+	}
+
+	// Handle normal response
+	return c.handleNormalResponse(resp.Body, info)
+}
+
+// endpoint builds the generateContent or streamGenerateContent URL
+func (c *GeminiClient) endpoint(stream bool) string {
+	apiURL := strings.TrimSuffix(c.apiURL, "/")
+
+	method := "generateContent"
+	query := url.Values{}
+	if stream {
+		method = "streamGenerateContent"
+		query.Set("alt", "sse")
+	}
+	query.Set("key", c.apiKey)
+
+	model := strings.TrimPrefix(c.model, "models/")
+	return fmt.Sprintf("%s/v1beta/models/%s:%s?%s", apiURL, url.PathEscape(model), method, query.Encode())
+}
+
+// handleNormalResponse handles normal responses, recording usage in info when it is not nil
+func (c *GeminiClient) handleNormalResponse(respBody io.Reader, info *ResponseInfo) (string, error) {
+	var apiResp GeminiResponse
+
+	// Parse response
+	if err := json.NewDecoder(respBody).Decode(&apiResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	// Check if there are candidates
+	if len(apiResp.Candidates) == 0 {
+		return "", fmt.Errorf("API returned empty response")
+	}
+
+	info.recordGemini(apiResp)
+
+	// Return the text of the first candidate
+	return geminiText(apiResp.Candidates[0].Content), nil
+}
+
+// handleStreamResponse handles stream responses, passing text to onChunk or printing it when onChunk is nil,
+// and recording usage in info when it is not nil
+func (c *GeminiClient) handleStreamResponse(respBody io.Reader, onChunk func(string), info *ResponseInfo) (string, error) {
+	// Use bufio.Scanner to read line by line in SSE format
+	scanner := bufio.NewScanner(respBody)
+	var fullContent strings.Builder
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Check if it's a data line
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+
+		// Parse JSON data
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &chunk); err != nil {
+			// Parse error, skip this line
+			continue
+		}
+
+		// Every chunk carries the usage so far, the last one has the totals
+		info.recordGemini(chunk)
+
+		// Extract content and add to result
+		if len(chunk.Candidates) > 0 {
+			content := geminiText(chunk.Candidates[0].Content)
+			if content != "" {
+				fullContent.WriteString(content)
+				info.firstToken()
+				// Print content in real time
+				emitChunk(onChunk, content)
+			}
+		}
+	}
+
+	// Check if there was an error during scanning
+	if err := scanner.Err(); err != nil {
+		return fullContent.String(), fmt.Errorf("error scanning stream response: %w", err)
+	}
+
+	// Output newline, making subsequent output more pretty
+	endStream(onChunk)
+
+	return fullContent.String(), nil
+}
+
+// geminiParts converts the text and images of a message into Gemini parts.
+// Images given by URL are skipped, Gemini only takes their data.
+func geminiParts(msg Message) []GeminiPart {
+	if len(msg.Parts) == 0 {
+		return []GeminiPart{{Text: msg.Content}}
+	}
+
+	var parts []GeminiPart
+	for _, part := range msg.Parts {
+		if part.Type != ContentImage {
+			parts = append(parts, GeminiPart{Text: part.Text})
+		} else if mimeType, data, ok := part.imageData(); ok {
+			parts = append(parts, GeminiPart{InlineData: &GeminiInlineData{MimeType: mimeType, Data: data}})
+		}
+	}
+	return parts
+}
+
+// buildGeminiRequest converts messages and options into a Gemini request
+func buildGeminiRequest(messages []Message, opts *ChatOptions) GeminiRequest {
+	var req GeminiRequest
+	var systemParts []GeminiPart
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			// Gemini takes system prompts separately from the conversation
+			systemParts = append(systemParts, GeminiPart{Text: msg.Content})
+		case "assistant":
+			req.Contents = append(req.Contents, GeminiContent{
+				Role:  "model",
+				Parts: []GeminiPart{{Text: msg.Content}},
+			})
+		default:
+			req.Contents = append(req.Contents, GeminiContent{
+				Role:  "user",
+				Parts: geminiParts(msg),
+			})
+		}
+	}
+
+	if len(systemParts) > 0 {
+		req.SystemInstruction = &GeminiContent{Parts: systemParts}
+	}
+
+	temperature := opts.Temperature
+	req.GenerationConfig = &GeminiGenerationConfig{
+		Temperature:     &temperature,
+		MaxOutputTokens: opts.MaxTokens,
+	}
+	if opts.ResponseFormat != nil {
+		req.GenerationConfig.ResponseMimeType = "application/json"
+	}
+
+	return req
+}
+
+// recordGemini records the usage and finish reason of a Gemini response
+func (info *ResponseInfo) recordGemini(resp GeminiResponse) {
+	if info == nil {
+		return
+	}
+	if resp.UsageMetadata != nil {
+		info.Usage = Usage{
+			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
+		}
+	}
+	if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason != "" {
+		info.FinishReason = resp.Candidates[0].FinishReason
+	}
+}
+
+// geminiText joins the text parts of a content
+func geminiText(content GeminiContent) string {
+	var text strings.Builder
+	for _, part := range content.Parts {
+		text.WriteString(part.Text)
+	}
+	return text.String()
+}
+
+// GeminiModel implementation
+type GeminiModel struct {
+	baseModel
+}
+
+func NewGeminiModel(config *ModelConfig) *GeminiModel {
+	return &GeminiModel{
+		baseModel: baseModel{config: config},
+	}
+}
+
+func (m *GeminiModel) Chat(ctx context.Context, question string, options ...ChatOption) (string, error) {
+	opts := m.chatOptions(options)
+	if len(opts.Tools) > 0 {
+		return "", ErrToolsNotSupported
+	}
+
+	// Create messages array
+	messages := buildMessages(opts, question)
+	if err := m.checkImages(messages, opts); err != nil {
+		return "", err
+	}
+
+	// Send to API
+	client := NewGeminiClient(*m.config)
+	return client.Chat(ctx, messages, opts)
+}
+
+func (m *GeminiModel) ChatWithFile(ctx context.Context, question string, fileName string, fileContent string, options ...ChatOption) (string, error) {
+	opts := m.chatOptions(options)
+	if len(opts.Tools) > 0 {
+		return "", ErrToolsNotSupported
+	}
+
+	// Create messages with the file content
+	messages := fileMessages(opts, question, fileName, fileContent)
+	if err := m.checkImages(messages, opts); err != nil {
+		return "", err
+	}
+
+	// Send request
+	client := NewGeminiClient(*m.config)
+	return client.Chat(ctx, messages, opts)
+}
+
+// checkImages fails when messages contain images the model can't read, or images given by URL
+func (m *GeminiModel) checkImages(messages []Message, opts *ChatOptions) error {
+	if err := checkImages(m.config, messages); err != nil {
+		return err
+	}
+	for _, image := range opts.Images {
+		if _, _, ok := image.imageData(); !ok {
+			return fmt.Errorf("%w by URL: %s, send the image data instead", ErrImagesNotSupported, m.config.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGeminiClient_Chat(t *testing.T) {
+	// Create mock server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Verify API key is passed as query parameter
+		if r.URL.Query().Get("key") != "test-api-key" {
+			t.Errorf("Expected key query parameter to be test-api-key, got %s", r.URL.Query().Get("key"))
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		// Parse request body
+		var req GeminiRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to parse request body: %v", err)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		// Verify system instruction and role mapping
+		if req.SystemInstruction == nil || geminiText(*req.SystemInstruction) != "be brief" {
+			t.Errorf("Expected system instruction to be set")
+		}
+		if len(req.Contents) != 3 || req.Contents[1].Role != "model" {
+			t.Errorf("Expected assistant message to be mapped to model role, got %+v", req.Contents)
+		}
+		if req.GenerationConfig == nil || req.GenerationConfig.MaxOutputTokens != 100 {
+			t.Errorf("Expected maxOutputTokens to be 100")
+		}
+
+		resp := GeminiResponse{
+			Candidates: []GeminiCandidate{
+				{
+					Content: GeminiContent{
+						Role:  "model",
+						Parts: []GeminiPart{{Text: "this is a test response"}},
+					},
+					FinishReason: "STOP",
+				},
+			},
+		}
+
+		// If streaming request, return SSE format
+		if strings.HasSuffix(r.URL.Path, ":streamGenerateContent") {
+			if r.URL.Query().Get("alt") != "sse" {
+				t.Errorf("Expected alt=sse for streaming request")
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+
+			for _, text := range []string{"this is ", "a test response"} {
+				resp.Candidates[0].Content.Parts[0].Text = text
+				chunkData, _ := json.Marshal(resp)
+				w.Write([]byte("data: " + string(chunkData) + "\n\n"))
+			}
+			return
+		}
+
+		if !strings.HasSuffix(r.URL.Path, "/v1beta/models/gemini-test:generateContent") {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+
+		// Return JSON response
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	// Create client
+	client := NewGeminiClient(ModelConfig{
+		Name:   "gemini-test",
+		URL:    server.URL,
+		APIKey: "test-api-key",
+	})
+
+	messages := []Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "Hi"},
+		{Role: "assistant", Content: "Hello"},
+		{Role: "user", Content: "How are you?"},
+	}
+
+	for _, stream := range []bool{false, true} {
+		opts := &ChatOptions{
+			Temperature: 0.7,
+			MaxTokens:   100,
+			Stream:      stream,
+		}
+
+		resp, err := client.Chat(context.Background(), messages, opts)
+		if err != nil {
+			t.Fatalf("Chat request failed (stream=%v): %v", stream, err)
+		}
+
+		expected := "this is a test response"
+		if resp != expected {
+			t.Errorf("Expected response to be %s, got %s (stream=%v)", expected, resp, stream)
+		}
+	}
+}
+
+func TestCreateModel_Gemini(t *testing.T) {
+	configs := []*ModelConfig{
+		{Name: "gemini-1.5-flash"},
+		{Name: "flash", URL: "https://generativelanguage.googleapis.com"},
+		{Name: "custom", Provider: "gemini"},
+	}
+
+	for _, config := range configs {
+		model, err := CreateModel(config)
+		if err != nil {
+			t.Fatalf("CreateModel failed: %v", err)
+		}
+		if _, ok := model.(*GeminiModel); !ok {
+			t.Errorf("Expected %s to create a GeminiModel, got %T", config.Name, model)
+		}
+	}
+
+	// A misspelled provider is not served by another API
+	if _, err := CreateModel(&ModelConfig{Name: "custom", Provider: "gemnii"}); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("Expected ErrUnknownProvider, got %v", err)
+	}
+	if _, err := DiscoverModels(context.Background(), "gemnii", "https://example.com", ""); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("Expected ErrUnknownProvider from DiscoverModels, got %v", err)
+	}
+}
+
+func TestResponseInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := GeminiResponse{
+			Candidates: []GeminiCandidate{
+				{
+					Content:      GeminiContent{Role: "model", Parts: []GeminiPart{{Text: "ok"}}},
+					FinishReason: "STOP",
+				},
+			},
+			UsageMetadata: &GeminiUsageMetadata{PromptTokenCount: 4, CandidatesTokenCount: 1, TotalTokenCount: 5},
+		}
+
+		if strings.HasSuffix(r.URL.Path, ":streamGenerateContent") {
+			chunkData, _ := json.Marshal(resp)
+			w.Write([]byte("data: " + string(chunkData) + "\n\n"))
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	model := NewGeminiModel(&ModelConfig{Name: "gemini-test", URL: server.URL, APIKey: "test-api-key"})
+
+	for _, stream := range []bool{false, true} {
+		info := &ResponseInfo{}
+		if _, err := model.Chat(context.Background(), "hi", WithStream(stream), WithTemperature(0.5), WithResponseInfo(info)); err != nil {
+			t.Fatalf("Chat failed: %v", err)
+		}
+
+		if info.Model != "gemini-test" || info.Provider != "gemini" || info.Temperature != 0.5 {
+			t.Errorf("Expected request details to be recorded, got %+v", info)
+		}
+		if info.Usage.TotalTokens != 5 || info.FinishReason != "STOP" {
+			t.Errorf("Expected usage and finish reason to be recorded (stream=%v), got %+v", stream, info)
+		}
+		if info.Latency <= 0 {
+			t.Errorf("Expected latency to be recorded")
+		}
+	}
+}
+
+func TestGeminiErrorsHideAPIKey(t *testing.T) {
+	// A closed server makes the request fail in the transport
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	model := NewGeminiModel(&ModelConfig{Name: "gemini-2.0-flash", URL: server.URL, APIKey: "SECRETKEY123"})
+	_, err := model.Chat(context.Background(), "Hello", WithStream(false))
+	if err == nil || strings.Contains(err.Error(), "SECRETKEY123") {
+		t.Errorf("Expected an error without the API key, got %v", err)
+	}
+
+	_, err = DiscoverModels(context.Background(), "gemini", server.URL, "SECRETKEY123")
+	if err == nil || strings.Contains(err.Error(), "SECRETKEY123") {
+		t.Errorf("Expected a discovery error without the API key, got %v", err)
+	}
+
+	// Even a URL that can't be parsed keeps the key hidden
+	model = NewGeminiModel(&ModelConfig{Name: "gemini-2.0-flash", URL: "http://bad host", APIKey: "SECRETKEY123"})
+	if _, err := model.Chat(context.Background(), "Hello"); err == nil || strings.Contains(err.Error(), "SECRETKEY123") {
+		t.Errorf("Expected an error without the API key, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// judgeRubric is the grading rubric given to the judge model
+const judgeRubric = `You are an impartial judge comparing answers to the same question.
+Grade each answer from 1 to 10 on:
+- correctness: facts, code and reasoning are accurate
+- completeness: the whole question is answered
+- clarity: the answer is well organized and easy to follow
+- concision: no padding or irrelevant content
+Do not favor an answer because of its length or position.
+
+Reply with JSON only, in this form:
+{"ranking": [{"answer": "A", "score": 8, "reason": "one sentence"}], "rationale": "a short paragraph comparing the answers"}
+List every answer in the ranking, best first.`
+
+// synthesisPrompt asks a model to merge the candidate answers
+const synthesisPrompt = `Merge the answers below into one reply to the question. Keep what the answers agree on,
+resolve their disagreements by choosing the best supported position, and leave out mistakes.
+Reply with the merged answer only, without mentioning the individual answers.`
+
+// Candidate is the answer of one model, as compared by Judge and merged by Synthesize
+type Candidate struct {
+	Model  string
+	Answer string
+}
+
+// Rank is the place of a candidate in a judgement
+type Rank struct {
+	Model  string  `json:"model"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// Judgement is the verdict of a judge model on several candidate answers
+type Judgement struct {
+	Judge string `json:"judge"`
+	// Ranking lists the candidates best first
+	Ranking   []Rank `json:"ranking"`
+	Rationale string `json:"rationale"`
+}
+
+// Judge asks a model to grade candidate answers to a question with a rubric and
+// returns the ranking. The candidates are shown to the judge under letters rather
+// than model names, so that it grades the answers alone.
+func Judge(ctx context.Context, judge Model, question string, candidates []Candidate, options ...ChatOption) (*Judgement, error) {
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no answers to judge")
+	}
+
+	options = append(options[:len(options):len(options)], WithStream(false), WithHistory(nil), WithSystemPrompt(judgeRubric))
+	reply, err := judge.Chat(ctx, candidatesPrompt(question, candidates), options...)
+	if err != nil {
+		return nil, err
+	}
+
+	var verdict struct {
+		Ranking []struct {
+			Answer string  `json:"answer"`
+			Score  float64 `json:"score"`
+			Reason string  `json:"reason"`
+		} `json:"ranking"`
+		Rationale string `json:"rationale"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(reply)), &verdict); err != nil {
+		return nil, fmt.Errorf("judge %s did not reply with a valid ranking: %w", judge.Name(), err)
+	}
+
+	judgement := &Judgement{Judge: judge.Name(), Rationale: strings.TrimSpace(verdict.Rationale)}
+	seen := make(map[int]bool)
+	for _, entry := range verdict.Ranking {
+		index := candidateIndex(entry.Answer)
+		if index < 0 || index >= len(candidates) || seen[index] {
+			continue
+		}
+		seen[index] = true
+		judgement.Ranking = append(judgement.Ranking, Rank{Model: candidates[index].Model, Score: entry.Score, Reason: strings.TrimSpace(entry.Reason)})
+	}
+	if len(judgement.Ranking) == 0 {
+		return nil, fmt.Errorf("judge %s did not rank any answer", judge.Name())
+	}
+
+	// The judge is asked for the best first, sort anyway in case it didn't
+	sort.SliceStable(judgement.Ranking, func(i, j int) bool {
+		return judgement.Ranking[i].Score > judgement.Ranking[j].Score
+	})
+	return judgement, nil
+}
+
+// Synthesize asks a model to merge candidate answers into one consensus reply
+func Synthesize(ctx context.Context, model Model, question string, candidates []Candidate, options ...ChatOption) (string, error) {
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no answers to synthesize")
+	}
+
+	options = append(options[:len(options):len(options)], WithHistory(nil), WithSystemPrompt(synthesisPrompt))
+	return model.Chat(ctx, candidatesPrompt(question, candidates), options...)
+}
+
+// candidatesPrompt lists the question and the candidate answers under letters
+func candidatesPrompt(question string, candidates []Candidate) string {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "question: %s\n", question)
+	for i, candidate := range candidates {
+		fmt.Fprintf(&prompt, "\nanswer %s:\n%s\n", candidateLabel(i), candidate.Answer)
+	}
+	return prompt.String()
+}
+
+// candidateLabel returns the letter of the candidate at index: A, B, ... Z, AA, AB...
+func candidateLabel(index int) string {
+	label := string(rune('A' + index%26))
+	if index >= 26 {
+		label = candidateLabel(index/26-1) + label
+	}
+	return label
+}
+
+// candidateIndex returns the index of a candidate label, or -1 when it isn't one
+func candidateIndex(label string) int {
+	label = strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(label)), "answer")))
+	if label == "" {
+		return -1
+	}
+	index := 0
+	for _, r := range label {
+		if r < 'A' || r > 'Z' {
+			return -1
+		}
+		index = index*26 + int(r-'A'+1)
+	}
+	return index - 1
+}
+
+// extractJSON returns the JSON object or array in a reply, which models often wrap in a
+// code block or surround with text. An object is preferred when both parse.
+func extractJSON(reply string) string {
+	reply = strings.TrimSpace(reply)
+	if json.Valid([]byte(reply)) {
+		return reply
+	}
+
+	object := enclosed(reply, "{", "}")
+	if array := enclosed(reply, "[", "]"); array != "" && !json.Valid([]byte(object)) && json.Valid([]byte(array)) {
+		return array
+	}
+	if object != "" {
+		return object
+	}
+	return reply
+}
+
+// enclosed returns the text from the first opening to the last closing delimiter, or ""
+func enclosed(text, opening, closing string) string {
+	start := strings.Index(text, opening)
+	end := strings.LastIndex(text, closing)
+	if start < 0 || end < start {
+		return ""
+	}
+	return text[start : end+1]
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// replyModel answers with a fixed reply and records the last request
+type replyModel struct {
+	reply    string
+	question string
+	opts     ChatOptions
+}
+
+func (m *replyModel) Name() string { return "judge" }
+
+func (m *replyModel) Chat(ctx context.Context, question string, options ...ChatOption) (string, error) {
+	m.question, m.opts = question, ChatOptions{}
+	for _, option := range options {
+		option(&m.opts)
+	}
+	return m.reply, nil
+}
+
+func (m *replyModel) ChatWithFile(ctx context.Context, question string, fileName string, fileContent string, options ...ChatOption) (string, error) {
+	return m.Chat(ctx, question, options...)
+}
+
+func TestJudge(t *testing.T) {
+	candidates := []Candidate{
+		{Model: "gpt-4o", Answer: "first answer"},
+		{Model: "gemini", Answer: "second answer"},
+	}
+	judge := &replyModel{reply: "```json\n" + `{"ranking": [{"answer": "A", "score": 6, "reason": "vague"}, {"answer": "Answer B", "score": 9, "reason": "precise"}, {"answer": "Z", "score": 10}], "rationale": "B is better."}` + "\n```"}
+
+	judgement, err := Judge(context.Background(), judge, "q", candidates, WithHistory([]Message{{Role: "user", Content: "old"}}))
+	if err != nil {
+		t.Fatalf("Judge failed: %v", err)
+	}
+
+	if strings.Contains(judge.question, "gpt-4o") || !strings.Contains(judge.question, "answer B:\nsecond answer") {
+		t.Errorf("Expected the answers to be labeled by letter only, got:\n%s", judge.question)
+	}
+	if judge.opts.SystemPrompt != judgeRubric || judge.opts.History != nil || judge.opts.Stream {
+		t.Errorf("Expected a non-streamed request with the rubric and no history, got %+v", judge.opts)
+	}
+
+	want := []Rank{{Model: "gemini", Score: 9, Reason: "precise"}, {Model: "gpt-4o", Score: 6, Reason: "vague"}}
+	if len(judgement.Ranking) != len(want) {
+		t.Fatalf("Expected ranking %+v, got %+v", want, judgement.Ranking)
+	}
+	for i := range want {
+		if judgement.Ranking[i] != want[i] {
+			t.Errorf("Expected rank %d to be %+v, got %+v", i+1, want[i], judgement.Ranking[i])
+		}
+	}
+	if judgement.Judge != "judge" || judgement.Rationale != "B is better." {
+		t.Errorf("Unexpected judgement %+v", judgement)
+	}
+
+	judge.reply = "I think B is better."
+	if _, err := Judge(context.Background(), judge, "q", candidates); err == nil {
+		t.Error("Expected an error when the judge doesn't reply with JSON")
+	}
+}
+
+func TestSynthesize(t *testing.T) {
+	model := &replyModel{reply: "merged"}
+	answer, err := Synthesize(context.Background(), model, "q", []Candidate{{Model: "a", Answer: "x"}, {Model: "b", Answer: "y"}})
+	if err != nil || answer != "merged" {
+		t.Fatalf("Expected the merged answer, got %q, %v", answer, err)
+	}
+	if model.opts.SystemPrompt != synthesisPrompt || !strings.Contains(model.question, "answer A:\nx") {
+		t.Errorf("Unexpected synthesis request %q with %+v", model.question, model.opts)
+	}
+
+	if _, err := Synthesize(context.Background(), model, "q", nil); err == nil {
+		t.Error("Expected an error without answers")
+	}
+}
+
+func TestCandidateLabel(t *testing.T) {
+	for index, label := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := candidateLabel(index); got != label {
+			t.Errorf("candidateLabel(%d) = %q, want %q", index, got, label)
+		}
+		if got := candidateIndex(label); got != index {
+			t.Errorf("candidateIndex(%q) = %d, want %d", label, got, index)
+		}
+	}
+}
+
+func TestExtractJSON(t *testing.T) {
+	tests := map[string]string{
+		`{"winner": "A"}`: `{"winner": "A"}`,
+		"Answer [A] wins:\n```json\n{\"winner\": \"A\"}\n```": `{"winner": "A"}`,
+		`Here you go: [{"a": 1}, {"a": 2}] Done.`:             `[{"a": 1}, {"a": 2}]`,
+		` [1, 2] `: `[1, 2]`,
+		`no JSON`:  `no JSON`,
+	}
+	for reply, want := range tests {
+		if got := extractJSON(reply); got != want {
+			t.Errorf("extractJSON(%q) = %q, want %q", reply, got, want)
+		}
+	}
+}
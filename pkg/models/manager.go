@@ -1,46 +1,63 @@
 package models
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
-	"gopkg.in/yaml.v3"
+	"github.com/pokitpeng/ai/pkg/util"
 )
 
 var (
-	ErrModelNotFound = errors.New("model not found")
-	ErrModelExists   = errors.New("model already exists")
+	ErrModelNotFound    = errors.New("model not found")
+	ErrModelExists      = errors.New("model already exists")
+	ErrProviderNotFound = errors.New("provider not found")
+	ErrProviderExists   = errors.New("provider already exists")
+	ErrProviderInUse    = errors.New("provider is used by models")
+	ErrGroupNotFound    = errors.New("group not found")
+	ErrGroupExists      = errors.New("group already exists")
+	ErrModelRouted      = errors.New("model is used by the router")
 )
 
 // ModelManager manages all AI models
 type ModelManager struct {
 	models       map[string]Model
 	configs      map[string]*ModelConfig
+	providers    map[string]*ProviderConfig
+	aliases      map[string]string
+	groups       map[string][]string
+	router       *RouterConfig
+	defaults     *DefaultsConfig
 	defaultModel string
 	configFile   string
-	mu           sync.RWMutex
+	middlewares  []Middleware
+	// snapshot is the config file content last read or written by this manager
+	snapshot []byte
+	mu       sync.RWMutex
 }
 
-// NewModelManager creates a new model manager
+// NewModelManager creates a new model manager using the default config location
 func NewModelManager() *ModelManager {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		homeDir = "."
-	}
+	return NewModelManagerWithFile(filepath.Join(util.ConfigDir(), "config.yaml"))
+}
 
-	configDir := filepath.Join(homeDir, ".ai")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
+// NewModelManagerWithFile creates a new model manager that stores its configuration in configFile
+func NewModelManagerWithFile(configFile string) *ModelManager {
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to create config directory: %v\n", err)
 	}
 
-	configFile := filepath.Join(configDir, "config.yaml")
-
 	return &ModelManager{
 		models:     make(map[string]Model),
 		configs:    make(map[string]*ModelConfig),
+		providers:  make(map[string]*ProviderConfig),
+		aliases:    make(map[string]string),
+		groups:     make(map[string][]string),
 		configFile: configFile,
 	}
 }
@@ -57,33 +74,46 @@ func (m *ModelManager) Init() error {
 
 	// Initialize all models based on configuration
 	for name, config := range m.configs {
+		if config == nil {
+			fmt.Fprintf(os.Stderr, "Skipping model %s: empty configuration\n", name)
+			continue
+		}
+
 		// This will create different model instances based on model type
 		// Simplified handling, implementing factory methods for each model type
-		model, err := CreateModel(config)
+		model, err := m.createModel(config)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to create model %s: %v\n", name, err)
 			continue
 		}
 
 		m.models[name] = model
+	}
 
-		// If this model is marked as default enabled, set it as default
-		if config.DefaultEnabled {
-			m.defaultModel = name
+	// Use the default model stored in the configuration
+	if m.defaults != nil {
+		if _, exists := m.models[m.defaults.Model]; exists {
+			m.defaultModel = m.defaults.Model
 		}
 	}
 
 	// If no default model but models exist, set the first one as default
-	if m.defaultModel == "" && len(m.models) > 0 {
-		for name := range m.models {
-			m.defaultModel = name
-			break
-		}
+	if m.defaultModel == "" {
+		m.defaultModel = m.firstModelName()
 	}
 
 	return nil
 }
 
+// firstModelName returns the first model name in sorted order
+func (m *ModelManager) firstModelName() string {
+	names := sortedKeys(m.models)
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
 // loadConfig loads the configuration file
 func (m *ModelManager) loadConfig() error {
 	if _, err := os.Stat(m.configFile); os.IsNotExist(err) {
@@ -96,29 +126,135 @@ func (m *ModelManager) loadConfig() error {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	var configs map[string]*ModelConfig
-	if err := yaml.Unmarshal(data, &configs); err != nil {
-		return fmt.Errorf("failed to unmarshal config: %w", err)
+	config, migrated, err := ParseConfig(data)
+	if err != nil {
+		var configErr *ConfigError
+		if errors.As(err, &configErr) {
+			configErr.File = m.configFile
+		}
+		return err
+	}
+
+	m.applyConfig(config)
+	m.snapshot = data
+
+	if migrated {
+		// Keep the original file before rewriting it in the current schema
+		backupFile := m.configFile + ".bak"
+		if err := os.WriteFile(backupFile, data, 0600); err != nil {
+			return fmt.Errorf("failed to back up config file: %w", err)
+		}
+		if err := m.saveConfig(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Migrated %s to config version %d (backup: %s)\n", m.configFile, ConfigVersion, backupFile)
 	}
 
-	m.configs = configs
 	return nil
 }
 
-// saveConfig saves configuration to file
+// applyConfig replaces the in-memory configuration
+func (m *ModelManager) applyConfig(config *Config) {
+	m.configs = config.Models
+	m.providers = config.Providers
+	m.aliases = config.Aliases
+	m.groups = config.Groups
+	m.router = config.Router
+	m.defaults = config.Defaults
+}
+
+// currentConfig returns the in-memory configuration
+func (m *ModelManager) currentConfig() *Config {
+	// Record the default model explicitly
+	if m.defaultModel != "" || m.defaults != nil {
+		if m.defaults == nil {
+			m.defaults = &DefaultsConfig{}
+		}
+		m.defaults.Model = m.defaultModel
+	}
+
+	return &Config{
+		Version:   ConfigVersion,
+		Defaults:  m.defaults,
+		Providers: m.providers,
+		Models:    m.configs,
+		Aliases:   m.aliases,
+		Groups:    m.groups,
+		Router:    m.router,
+	}
+}
+
+// saveConfig saves configuration to file.
+// If another process changed the file since it was read, both sets of changes are merged.
 func (m *ModelManager) saveConfig() error {
-	data, err := yaml.Marshal(m.configs)
+	unlock, err := util.LockFile(m.configFile + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	config := m.currentConfig()
+
+	// Merge changes made by other processes since the last read
+	onDisk, err := os.ReadFile(m.configFile)
+	if err == nil && !bytes.Equal(onDisk, m.snapshot) {
+		theirs, _, err := ParseConfig(onDisk)
+		if err != nil {
+			return fmt.Errorf("config file was changed by another process and cannot be merged: %w", err)
+		}
+
+		base := newConfig()
+		if len(m.snapshot) > 0 {
+			if parsed, _, err := ParseConfig(m.snapshot); err == nil {
+				base = parsed
+			}
+		}
+
+		config = mergeConfig(base, config, theirs)
+		m.applyConfig(config)
+		m.rebuildModels()
+	}
+
+	data, err := MarshalConfig(config)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(m.configFile, data, 0644); err != nil {
+	if err := util.WriteFileAtomic(m.configFile, data, 0644); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
+	m.snapshot = data
 
 	return nil
 }
 
+// rebuildModels recreates every model instance from the configuration
+func (m *ModelManager) rebuildModels() {
+	models := make(map[string]Model, len(m.configs))
+	for name, config := range m.configs {
+		if config == nil {
+			continue
+		}
+		model, err := m.createModel(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create model %s: %v\n", name, err)
+			continue
+		}
+		models[name] = model
+	}
+	m.models = models
+
+	// Keep the default model valid
+	if m.defaults != nil && m.defaults.Model != "" {
+		if _, exists := m.models[m.defaults.Model]; exists {
+			m.defaultModel = m.defaults.Model
+		}
+	}
+	if _, exists := m.models[m.defaultModel]; !exists {
+		m.defaultModel = m.firstModelName()
+	}
+}
+
 // GetDefaultModel gets the default model
 func (m *ModelManager) GetDefaultModel() (Model, error) {
 	m.mu.RLock()
@@ -136,12 +272,20 @@ func (m *ModelManager) GetDefaultModel() (Model, error) {
 	return model, nil
 }
 
-// GetModel gets a model by name
+// GetModel gets a model by name or alias. A group or tag, written @name, selects its first model.
 func (m *ModelManager) GetModel(name string) (Model, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	model, exists := m.models[name]
+	if strings.HasPrefix(name, GroupPrefix) {
+		members, err := m.expandGroup(name)
+		if err != nil {
+			return nil, err
+		}
+		name = members[0]
+	}
+
+	model, exists := m.models[m.resolveAlias(name)]
 	if !exists {
 		return nil, ErrModelNotFound
 	}
@@ -151,34 +295,41 @@ func (m *ModelManager) GetModel(name string) (Model, error) {
 
 // AddModel adds a new model
 func (m *ModelManager) AddModel(name, url, apiKey string, defaultEnabled bool, chatOptions *ChatOptions) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if _, exists := m.configs[name]; exists {
-		return ErrModelExists
-	}
-
-	// Create new model configuration
-	config := &ModelConfig{
+	return m.AddModelConfig(&ModelConfig{
 		Name:               name,
 		URL:                url,
 		APIKey:             apiKey,
 		DefaultEnabled:     defaultEnabled,
 		DefaultChatOptions: chatOptions,
+	})
+}
+
+// AddModelConfig adds a new model from a complete configuration
+func (m *ModelManager) AddModelConfig(config *ModelConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := config.Name
+	defaultEnabled := config.DefaultEnabled
+
+	if _, exists := m.configs[name]; exists {
+		return ErrModelExists
 	}
 
 	// Create model instance
-	model, err := CreateModel(config)
+	model, err := m.createModel(config)
 	if err != nil {
 		return fmt.Errorf("failed to create model: %w", err)
 	}
 
-	// Store model and configuration
+	// Store model and configuration, the default model is recorded separately
+	stored := *config
+	stored.DefaultEnabled = false
 	m.models[name] = model
-	m.configs[name] = config
+	m.configs[name] = &stored
 
 	// If this is the first model or defaultEnabled is true, set it as default
-	if len(m.configs) == 1 || defaultEnabled {
+	if m.defaultModel == "" || defaultEnabled {
 		m.defaultModel = name
 	}
 
@@ -194,11 +345,53 @@ func (m *ModelManager) RemoveModel(name string) error {
 	if _, exists := m.configs[name]; !exists {
 		return ErrModelNotFound
 	}
+	if m.router != nil {
+		for i, rule := range m.router.Rules {
+			if m.resolveAlias(rule.Model) == name {
+				return fmt.Errorf("%w: rule %d", ErrModelRouted, i+1)
+			}
+		}
+		if m.resolveAlias(m.router.Default) == name {
+			return fmt.Errorf("%w: default", ErrModelRouted)
+		}
+	}
 
 	// Delete model and configuration
 	delete(m.models, name)
 	delete(m.configs, name)
 
+	// Delete aliases pointing to the model
+	for alias, target := range m.aliases {
+		if target == name {
+			delete(m.aliases, alias)
+		}
+	}
+
+	// Remove the model from fallback chains
+	for _, config := range m.configs {
+		if config != nil {
+			config.Fallbacks = m.withoutRemoved(config.Fallbacks)
+		}
+	}
+	if m.defaults != nil {
+		m.defaults.Fallbacks = m.withoutRemoved(m.defaults.Fallbacks)
+	}
+
+	// Remove the model and its aliases from groups, dropping groups left empty
+	for group, members := range m.groups {
+		kept := m.withoutRemoved(members)
+		if len(kept) == 0 {
+			delete(m.groups, group)
+		} else {
+			m.groups[group] = kept
+		}
+	}
+
+	// Choose another default model if the default was removed
+	if m.defaultModel == name {
+		m.defaultModel = m.firstModelName()
+	}
+
 	// Save configuration
 	return m.saveConfig()
 }
@@ -227,7 +420,11 @@ func (m *ModelManager) ListModels() map[string]*ModelConfig {
 	// Create a copy to avoid external modification
 	result := make(map[string]*ModelConfig, len(m.configs))
 	for k, v := range m.configs {
+		if v == nil {
+			continue
+		}
 		configCopy := *v
+		configCopy.DefaultEnabled = k == m.defaultModel
 		result[k] = &configCopy
 	}
 
@@ -250,23 +447,26 @@ func (m *ModelManager) UpdateModelConfig(name string, config *ModelConfig) error
 	if _, exists := m.configs[name]; !exists {
 		return ErrModelNotFound
 	}
-
-	// Update configuration
-	m.configs[name] = config
-
-	// If DefaultEnabled is true, set as default model
-	if config.DefaultEnabled {
-		m.defaultModel = name
+	if err := m.checkModelNames(config.Fallbacks); err != nil {
+		return err
 	}
 
-	// Recreate model instance with new configuration
-	model, err := CreateModel(config)
+	// Recreate model instance with new configuration, keeping the current one if it fails
+	model, err := m.createModel(config)
 	if err != nil {
 		return fmt.Errorf("failed to update model: %w", err)
 	}
 
-	// Update model instance
+	// Update model instance and configuration, the default model is recorded separately
+	stored := *config
+	stored.DefaultEnabled = false
 	m.models[name] = model
+	m.configs[name] = &stored
+
+	// If DefaultEnabled is true, set as default model
+	if config.DefaultEnabled {
+		m.defaultModel = name
+	}
 
 	// Save configuration
 	return m.saveConfig()
@@ -278,11 +478,533 @@ func (m *ModelManager) GetModelConfig(name string) (*ModelConfig, error) {
 	defer m.mu.RUnlock()
 
 	config, exists := m.configs[name]
-	if !exists {
+	if !exists || config == nil {
 		return nil, ErrModelNotFound
 	}
 
 	// Return a copy to avoid external modification
 	configCopy := *config
+	configCopy.DefaultEnabled = name == m.defaultModel
 	return &configCopy, nil
 }
+
+// ResolvedModelConfig returns a model's configuration with its connection profile applied
+func (m *ModelManager) ResolvedModelConfig(name string) (*ModelConfig, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	config, exists := m.configs[m.resolveAlias(name)]
+	if !exists || config == nil {
+		return nil, ErrModelNotFound
+	}
+
+	resolved, err := resolveModelConfig(config, m.providers)
+	if err != nil {
+		return nil, err
+	}
+	if resolved == config {
+		configCopy := *config
+		resolved = &configCopy
+	}
+	return resolved, nil
+}
+
+// CreateModel creates a model from a configuration that does not have to be stored,
+// applying its connection profile, the global defaults and the middlewares
+func (m *ModelManager) CreateModel(config *ModelConfig) (Model, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.createModel(config)
+}
+
+// createModel creates a model instance, resolving its connection profile and global defaults
+func (m *ModelManager) createModel(config *ModelConfig) (Model, error) {
+	resolved, err := resolveModelConfig(config, m.providers)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolved.DefaultChatOptions == nil && m.defaults.hasChatOptions() {
+		if resolved == config {
+			configCopy := *config
+			resolved = &configCopy
+		}
+		resolved.DefaultChatOptions = m.defaults.ChatOptions()
+	}
+
+	model, err := CreateModel(resolved)
+	if err != nil {
+		return nil, err
+	}
+	if m.defaults != nil && m.defaults.CacheEnabled() {
+		model = NewCachedModel(model, resolved, m.defaults.ResponseCache())
+	}
+	return Chain(model, m.middlewares...), nil
+}
+
+// Use adds middlewares wrapping every model returned by the manager.
+// Middlewares added first are the outermost ones.
+func (m *ModelManager) Use(middlewares ...Middleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.middlewares = append(m.middlewares, middlewares...)
+	m.rebuildModels()
+}
+
+// ConfigFile returns the path of the configuration file
+func (m *ModelManager) ConfigFile() string {
+	return m.configFile
+}
+
+// resolveAlias returns the model name an alias points to
+func (m *ModelManager) resolveAlias(name string) string {
+	if _, exists := m.models[name]; exists {
+		return name
+	}
+	if target, exists := m.aliases[name]; exists {
+		return target
+	}
+	return name
+}
+
+// GetModelWithFallbacks gets a model by name, alias or @group like GetModel, or the default
+// model when name is empty. When the model has fallbacks, it is wrapped in a FallbackModel
+// trying the rest of the group, the fallbacks of the model, then the global fallbacks.
+func (m *ModelManager) GetModelWithFallbacks(name string) (Model, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.modelWithFallbacks(name)
+}
+
+// modelWithFallbacks implements GetModelWithFallbacks, the caller holds the lock
+func (m *ModelManager) modelWithFallbacks(name string) (Model, error) {
+	var candidates []string
+	switch {
+	case name == "":
+		if m.defaultModel == "" {
+			return nil, errors.New("no default model set")
+		}
+		candidates = []string{m.defaultModel}
+	case strings.HasPrefix(name, GroupPrefix):
+		members, err := m.expandGroup(name)
+		if err != nil {
+			return nil, err
+		}
+		candidates = members
+	default:
+		candidates = []string{name}
+	}
+
+	primaryName := m.resolveAlias(candidates[0])
+	primary, exists := m.models[primaryName]
+	if !exists {
+		return nil, ErrModelNotFound
+	}
+
+	candidates = append(candidates[1:len(candidates):len(candidates)], m.configs[primaryName].Fallbacks...)
+	if m.defaults != nil {
+		candidates = append(candidates, m.defaults.Fallbacks...)
+	}
+
+	// Unknown models are skipped, each model is tried once
+	var fallbacks []Model
+	seen := map[string]bool{primaryName: true}
+	for _, candidate := range candidates {
+		names := []string{candidate}
+		if strings.HasPrefix(candidate, GroupPrefix) {
+			names, _ = m.expandGroup(candidate)
+		}
+		for _, fallbackName := range names {
+			fallbackName = m.resolveAlias(fallbackName)
+			if model, exists := m.models[fallbackName]; exists && !seen[fallbackName] {
+				seen[fallbackName] = true
+				fallbacks = append(fallbacks, model)
+			}
+		}
+	}
+
+	if len(fallbacks) == 0 {
+		return primary, nil
+	}
+	return NewFallbackModel(primary, fallbacks...), nil
+}
+
+// Router returns the router choosing models from the rules of the configuration,
+// or nil when no rules are configured. Each model of the router has its fallbacks.
+func (m *ModelManager) Router() (*Router, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.router == nil || len(m.router.Rules) == 0 {
+		return nil, nil
+	}
+
+	defaultModel, err := m.modelWithFallbacks(m.router.Default)
+	if err != nil {
+		return nil, fmt.Errorf("router default: %w", err)
+	}
+
+	routes := make([]Route, len(m.router.Rules))
+	for i, rule := range m.router.Rules {
+		if rule.Model == "" {
+			return nil, fmt.Errorf("router rule %d has no model", i+1)
+		}
+		model, err := m.modelWithFallbacks(rule.Model)
+		if err != nil {
+			return nil, fmt.Errorf("router rule %d: %w: %s", i+1, err, rule.Model)
+		}
+		routes[i] = Route{Rule: rule, Model: model}
+	}
+
+	return NewRouter(defaultModel, routes...), nil
+}
+
+// checkModelNames checks that names refer to models, aliases, groups or tags
+func (m *ModelManager) checkModelNames(names []string) error {
+	for _, name := range names {
+		if strings.HasPrefix(name, GroupPrefix) {
+			if _, err := m.expandGroup(name); err != nil {
+				return err
+			}
+		} else if _, exists := m.configs[m.resolveAlias(name)]; !exists {
+			return fmt.Errorf("%w: %s", ErrModelNotFound, name)
+		}
+	}
+	return nil
+}
+
+// withoutRemoved returns the names that still refer to a model, alias or group
+func (m *ModelManager) withoutRemoved(names []string) []string {
+	var kept []string
+	for _, name := range names {
+		if strings.HasPrefix(name, GroupPrefix) {
+			kept = append(kept, name)
+		} else if _, isModel := m.configs[name]; isModel {
+			kept = append(kept, name)
+		} else if _, isAlias := m.aliases[name]; isAlias {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// ListGroups lists the groups of the configuration
+func (m *ModelManager) ListGroups() map[string][]string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Create a copy to avoid external modification
+	result := make(map[string][]string, len(m.groups))
+	for name, members := range m.groups {
+		result[name] = append([]string(nil), members...)
+	}
+
+	return result
+}
+
+// AddGroup adds a named group of models, selected with @name
+func (m *ModelManager) AddGroup(name string, members []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !validGroupName(name) {
+		return fmt.Errorf("invalid group name %q", name)
+	}
+	if _, exists := m.groups[name]; exists {
+		return ErrGroupExists
+	}
+	if len(members) == 0 {
+		return errors.New("a group needs at least one model")
+	}
+	for _, member := range members {
+		if _, exists := m.configs[m.resolveAlias(member)]; !exists {
+			return fmt.Errorf("%w: %s", ErrModelNotFound, member)
+		}
+	}
+
+	m.groups[name] = append([]string(nil), members...)
+
+	// Save configuration
+	return m.saveConfig()
+}
+
+// RemoveGroup removes a group
+func (m *ModelManager) RemoveGroup(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.groups[strings.TrimPrefix(name, GroupPrefix)]; !exists {
+		return ErrGroupNotFound
+	}
+
+	delete(m.groups, strings.TrimPrefix(name, GroupPrefix))
+
+	// Save configuration
+	return m.saveConfig()
+}
+
+// GroupsOf returns the groups and tags a model belongs to, sorted
+func (m *ModelManager) GroupsOf(name string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for group, members := range m.groups {
+		for _, member := range members {
+			if m.resolveAlias(member) == name {
+				seen[group] = true
+			}
+		}
+	}
+	if config, exists := m.configs[name]; exists && config != nil {
+		for _, tag := range config.Tags {
+			seen[tag] = true
+		}
+	}
+
+	return sortedKeys(seen)
+}
+
+// ResolveModelNames expands the groups and tags, written @name, in a list of model
+// names. Names are kept in order and duplicates are removed.
+func (m *ModelManager) ResolveModelNames(names []string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var resolved []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		expanded := []string{name}
+		if strings.HasPrefix(name, GroupPrefix) {
+			var err error
+			if expanded, err = m.expandGroup(name); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, member := range expanded {
+			if member != "" && !seen[member] {
+				seen[member] = true
+				resolved = append(resolved, member)
+			}
+		}
+	}
+
+	return resolved, nil
+}
+
+// expandGroup returns the models of a group, or of a tag when no group has the name.
+// Models of a tag are sorted by name.
+func (m *ModelManager) expandGroup(name string) ([]string, error) {
+	name = strings.TrimPrefix(name, GroupPrefix)
+	if members, exists := m.groups[name]; exists && len(members) > 0 {
+		return members, nil
+	}
+
+	var tagged []string
+	for modelName, config := range m.configs {
+		if config == nil {
+			continue
+		}
+		for _, tag := range config.Tags {
+			if tag == name {
+				tagged = append(tagged, modelName)
+				break
+			}
+		}
+	}
+	if len(tagged) == 0 {
+		return nil, fmt.Errorf("%w: %s%s", ErrGroupNotFound, GroupPrefix, name)
+	}
+	sort.Strings(tagged)
+
+	return tagged, nil
+}
+
+// ListProviders lists all connection profiles
+func (m *ModelManager) ListProviders() map[string]*ProviderConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Create a copy to avoid external modification
+	result := make(map[string]*ProviderConfig, len(m.providers))
+	for k, v := range m.providers {
+		providerCopy := *v
+		result[k] = &providerCopy
+	}
+
+	return result
+}
+
+// GetProvider gets a connection profile by name
+func (m *ModelManager) GetProvider(name string) (*ProviderConfig, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	provider, exists := m.providers[name]
+	if !exists {
+		return nil, ErrProviderNotFound
+	}
+
+	// Return a copy to avoid external modification
+	providerCopy := *provider
+	return &providerCopy, nil
+}
+
+// AddProvider adds a new connection profile
+func (m *ModelManager) AddProvider(name string, provider *ProviderConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.providers[name]; exists {
+		return ErrProviderExists
+	}
+
+	m.providers[name] = provider
+
+	// Save configuration
+	return m.saveConfig()
+}
+
+// UpdateProvider updates a connection profile and recreates every model using it
+func (m *ModelManager) UpdateProvider(name string, provider *ProviderConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.providers[name]; !exists {
+		return ErrProviderNotFound
+	}
+
+	m.providers[name] = provider
+
+	// Recreate dependent model instances so they pick up the new settings
+	for modelName, config := range m.configs {
+		if config == nil || config.Profile != name {
+			continue
+		}
+
+		model, err := m.createModel(config)
+		if err != nil {
+			return fmt.Errorf("failed to update model %s: %w", modelName, err)
+		}
+		m.models[modelName] = model
+	}
+
+	// Save configuration
+	return m.saveConfig()
+}
+
+// RemoveProvider removes a connection profile that is no longer used
+func (m *ModelManager) RemoveProvider(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.providers[name]; !exists {
+		return ErrProviderNotFound
+	}
+
+	for modelName, config := range m.configs {
+		if config != nil && config.Profile == name {
+			return fmt.Errorf("%w: %s", ErrProviderInUse, modelName)
+		}
+	}
+
+	delete(m.providers, name)
+
+	// Save configuration
+	return m.saveConfig()
+}
+
+// ModelsUsingProvider returns the names of the models that use a connection profile
+func (m *ModelManager) ModelsUsingProvider(name string) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var names []string
+	for modelName, config := range m.configs {
+		if config != nil && config.Profile == name {
+			names = append(names, modelName)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// GetDefaults returns the global settings
+func (m *ModelManager) GetDefaults() DefaultsConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var defaults DefaultsConfig
+	if m.defaults != nil {
+		defaults = *m.defaults
+	}
+	defaults.Model = m.defaultModel
+
+	return defaults
+}
+
+// SetDefaults validates and stores the global settings, recreating models whose options depend on them
+func (m *ModelManager) SetDefaults(defaults DefaultsConfig) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if defaults.Model != "" {
+		defaults.Model = m.resolveAlias(defaults.Model)
+		if _, exists := m.configs[defaults.Model]; !exists {
+			return fmt.Errorf("%w: %s", ErrModelNotFound, defaults.Model)
+		}
+	}
+	if defaults.Temperature != nil && !validTemperature(*defaults.Temperature) {
+		return errors.New("temperature must be between 0 and 2")
+	}
+	if defaults.MaxTokens < 0 {
+		return errors.New("max tokens must not be negative")
+	}
+	if !validOutputFormat(defaults.Output) {
+		return fmt.Errorf("unknown output format %q, expected %s or %s", defaults.Output, OutputText, OutputJSON)
+	}
+	if defaults.MonthlyBudget < 0 {
+		return errors.New("monthly budget must not be negative")
+	}
+	if !validSessionScope(defaults.SessionScope) {
+		return fmt.Errorf("unknown session scope %q, expected %s or %s", defaults.SessionScope, SessionScopeGlobal, SessionScopeDirectory)
+	}
+	if defaults.CacheTTL < 0 {
+		return errors.New("cache ttl must not be negative")
+	}
+	if defaults.CacheMaxSize < 0 {
+		return errors.New("cache max size must not be negative")
+	}
+	if err := m.checkModelNames(defaults.Fallbacks); err != nil {
+		return err
+	}
+
+	m.defaults = &defaults
+	if defaults.Model != "" {
+		m.defaultModel = defaults.Model
+	} else {
+		// Without a default model, the first one is used
+		m.defaultModel = m.firstModelName()
+	}
+
+	// Recreate model instances so they pick up the new chat defaults
+	for name, config := range m.configs {
+		if config == nil {
+			continue
+		}
+		model, err := m.createModel(config)
+		if err != nil {
+			continue
+		}
+		m.models[name] = model
+	}
+
+	// Save configuration
+	return m.saveConfig()
+}
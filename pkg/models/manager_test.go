@@ -0,0 +1,343 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestManager creates a model manager that stores its config in a temporary directory
+func newTestManager(t *testing.T, configData string) *ModelManager {
+	t.Helper()
+
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	if configData != "" {
+		if err := os.WriteFile(configFile, []byte(configData), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+	}
+
+	m := NewModelManagerWithFile(configFile)
+	if err := m.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	return m
+}
+
+func TestModelManager_LegacyConfig(t *testing.T) {
+	m := newTestManager(t, `gpt-4o:
+  name: gpt-4o
+  url: https://api.openai.com
+  api_key: sk-test
+  default_enabled: true
+`)
+
+	config, err := m.GetModelConfig("gpt-4o")
+	if err != nil {
+		t.Fatalf("Expected legacy model to be loaded: %v", err)
+	}
+	if config.URL != "https://api.openai.com" {
+		t.Errorf("Expected URL to be loaded, got %s", config.URL)
+	}
+	if m.GetDefaultModelName() != "gpt-4o" {
+		t.Errorf("Expected gpt-4o to be the default model, got %s", m.GetDefaultModelName())
+	}
+
+	// The original file is backed up and rewritten in the current schema
+	if _, err := os.Stat(m.configFile + ".bak"); err != nil {
+		t.Errorf("Expected backup of the legacy config: %v", err)
+	}
+	data, _ := os.ReadFile(m.configFile)
+	if !strings.HasPrefix(string(data), fmt.Sprintf("version: %d\n", ConfigVersion)) {
+		t.Errorf("Expected migrated config to be versioned, got:\n%s", data)
+	}
+}
+
+func TestModelManager_ProviderProfiles(t *testing.T) {
+	m := newTestManager(t, "")
+
+	if err := m.AddProvider("gateway", &ProviderConfig{
+		URL:     "https://gateway.example.com",
+		APIKey:  "old-key",
+		Headers: map[string]string{"X-Team": "ai"},
+	}); err != nil {
+		t.Fatalf("AddProvider failed: %v", err)
+	}
+
+	for _, name := range []string{"gpt-4o-mini", "qwen-7b"} {
+		if err := m.AddModelConfig(&ModelConfig{Name: name, Profile: "gateway"}); err != nil {
+			t.Fatalf("AddModelConfig failed: %v", err)
+		}
+	}
+
+	if err := m.AddModelConfig(&ModelConfig{Name: "orphan", Profile: "missing"}); err == nil {
+		t.Errorf("Expected error when referencing an unknown profile")
+	}
+
+	// Rotate the key
+	provider, _ := m.GetProvider("gateway")
+	provider.APIKey = "new-key"
+	if err := m.UpdateProvider("gateway", provider); err != nil {
+		t.Fatalf("UpdateProvider failed: %v", err)
+	}
+
+	for _, name := range []string{"gpt-4o-mini", "qwen-7b"} {
+		model := m.models[name].(*OpenAIModel)
+		if model.config.APIKey != "new-key" {
+			t.Errorf("Expected %s to use the rotated key, got %s", name, model.config.APIKey)
+		}
+		if model.config.URL != "https://gateway.example.com" || model.config.Headers["X-Team"] != "ai" {
+			t.Errorf("Expected %s to inherit the profile connection settings", name)
+		}
+	}
+
+	if err := m.RemoveProvider("gateway"); err == nil {
+		t.Errorf("Expected error when removing a profile in use")
+	}
+
+	// A failed update leaves the model as it was
+	update, _ := m.GetModelConfig("qwen-7b")
+	update.Profile = "missing"
+	update.DefaultEnabled = true
+	if err := m.UpdateModelConfig("qwen-7b", update); err == nil {
+		t.Errorf("Expected error when updating a model to an unknown profile")
+	}
+	if config, _ := m.GetModelConfig("qwen-7b"); config.Profile != "gateway" {
+		t.Errorf("Expected the config to be kept after a failed update, got profile %s", config.Profile)
+	}
+	if m.GetDefaultModelName() != "gpt-4o-mini" {
+		t.Errorf("Expected the default model to be kept after a failed update, got %s", m.GetDefaultModelName())
+	}
+
+	// The secret is stored once, in the profile
+	data, err := os.ReadFile(m.configFile)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	if strings.Count(string(data), "new-key") != 1 {
+		t.Errorf("Expected the key to be stored once, got:\n%s", data)
+	}
+
+	// Reload from disk
+	reloaded := newTestManager(t, string(data))
+	if _, err := reloaded.GetModel("qwen-7b"); err != nil {
+		t.Errorf("Expected model to be reloaded: %v", err)
+	}
+}
+
+func TestModelManager_Groups(t *testing.T) {
+	m := newTestManager(t, `version: 2
+models:
+  gpt-4o-mini:
+    url: https://api.openai.com
+  qwen-7b:
+    url: http://localhost:11434
+    tags: [local]
+  llama-3:
+    url: http://localhost:11434
+    tags: [local]
+aliases:
+  mini: gpt-4o-mini
+`)
+
+	if err := m.AddGroup("fast", []string{"mini", "qwen-7b"}); err != nil {
+		t.Fatalf("AddGroup failed: %v", err)
+	}
+	if err := m.AddGroup("fast", []string{"qwen-7b"}); err != ErrGroupExists {
+		t.Errorf("Expected ErrGroupExists, got %v", err)
+	}
+	if err := m.AddGroup("broken", []string{"missing"}); err == nil {
+		t.Error("Expected an error for a group with an unknown model")
+	}
+
+	names, err := m.ResolveModelNames([]string{"@fast", "llama-3", "@local"})
+	if err != nil {
+		t.Fatalf("ResolveModelNames failed: %v", err)
+	}
+	if strings.Join(names, ",") != "mini,qwen-7b,llama-3" {
+		t.Errorf("Expected groups and tags to be expanded without duplicates, got %v", names)
+	}
+	if _, err := m.ResolveModelNames([]string{"@nothing"}); err == nil {
+		t.Error("Expected an error for an unknown group")
+	}
+
+	// A group selects its first model
+	model, err := m.GetModel("@local")
+	if err != nil || model.Name() != "llama-3" {
+		t.Errorf("Expected @local to select llama-3, got %v, %v", model, err)
+	}
+
+	if groups := m.GroupsOf("qwen-7b"); strings.Join(groups, ",") != "fast,local" {
+		t.Errorf("Expected qwen-7b to belong to fast and local, got %v", groups)
+	}
+
+	// Removing models removes them from groups, and groups left empty
+	if err := m.RemoveModel("qwen-7b"); err != nil {
+		t.Fatalf("RemoveModel failed: %v", err)
+	}
+	if err := m.RemoveModel("gpt-4o-mini"); err != nil {
+		t.Fatalf("RemoveModel failed: %v", err)
+	}
+	if _, exists := m.ListGroups()["fast"]; exists {
+		t.Errorf("Expected the empty group to be removed, got %v", m.ListGroups())
+	}
+
+	if err := m.AddGroup("local-only", []string{"llama-3"}); err != nil {
+		t.Fatalf("AddGroup failed: %v", err)
+	}
+	data, _ := os.ReadFile(m.configFile)
+	reloaded := newTestManager(t, string(data))
+	if groups := reloaded.ListGroups(); len(groups["local-only"]) != 1 {
+		t.Errorf("Expected the group to be reloaded, got %v", groups)
+	}
+	if err := reloaded.RemoveGroup("@local-only"); err != nil {
+		t.Errorf("RemoveGroup failed: %v", err)
+	}
+}
+
+func TestModelManager_DefaultModelPersisted(t *testing.T) {
+	m := newTestManager(t, "")
+
+	for _, name := range []string{"a", "b", "c"} {
+		if err := m.AddModel(name, "https://"+name, "key", false, nil); err != nil {
+			t.Fatalf("AddModel failed: %v", err)
+		}
+	}
+	if m.GetDefaultModelName() != "a" {
+		t.Errorf("Expected the first model to become the default, got %s", m.GetDefaultModelName())
+	}
+
+	if err := m.SetDefaultModel("c"); err != nil {
+		t.Fatalf("SetDefaultModel failed: %v", err)
+	}
+
+	// Reload several times, the default must not depend on map order
+	data, _ := os.ReadFile(m.configFile)
+	for i := 0; i < 10; i++ {
+		reloaded := newTestManager(t, string(data))
+		if reloaded.GetDefaultModelName() != "c" {
+			t.Fatalf("Expected default model c after reload, got %s", reloaded.GetDefaultModelName())
+		}
+	}
+
+	if err := m.RemoveModel("c"); err != nil {
+		t.Fatalf("RemoveModel failed: %v", err)
+	}
+	if m.GetDefaultModelName() != "a" {
+		t.Errorf("Expected a new default after removing the default model, got %s", m.GetDefaultModelName())
+	}
+
+	if err := m.SetDefaults(DefaultsConfig{Output: "xml"}); err == nil {
+		t.Errorf("Expected error for unknown output format")
+	}
+
+	// An empty default model falls back to the first model
+	if err := m.SetDefaultModel("b"); err != nil {
+		t.Fatalf("SetDefaultModel failed: %v", err)
+	}
+	defaults := m.GetDefaults()
+	defaults.Model = ""
+	if err := m.SetDefaults(defaults); err != nil {
+		t.Fatalf("SetDefaults failed: %v", err)
+	}
+	if m.GetDefaultModelName() != "a" || m.GetDefaults().Model != "a" {
+		t.Errorf("Expected the default model to be reset to a, got %s", m.GetDefaultModelName())
+	}
+}
+
+func TestModelManager_ConcurrentWriters(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+
+	const writers = 8
+	const modelsPerWriter = 5
+
+	// Each manager stands for a separate process sharing the config file
+	managers := make([]*ModelManager, writers)
+	for i := range managers {
+		managers[i] = NewModelManagerWithFile(configFile)
+		if err := managers[i].Init(); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, manager := range managers {
+		wg.Add(1)
+		go func(writer int, manager *ModelManager) {
+			defer wg.Done()
+			for j := 0; j < modelsPerWriter; j++ {
+				name := fmt.Sprintf("model-%d-%d", writer, j)
+				if err := manager.AddModel(name, "https://example.com", "key", false, nil); err != nil {
+					t.Errorf("AddModel failed: %v", err)
+				}
+			}
+		}(i, manager)
+	}
+	wg.Wait()
+
+	reloaded := NewModelManagerWithFile(configFile)
+	if err := reloaded.Init(); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if got := len(reloaded.ListModels()); got != writers*modelsPerWriter {
+		t.Errorf("Expected %d models, got %d", writers*modelsPerWriter, got)
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	base := newConfig()
+	base.Models["a"] = &ModelConfig{Name: "a", URL: "https://a"}
+	base.Models["b"] = &ModelConfig{Name: "b", URL: "https://b"}
+
+	// We removed a and added c
+	ours := newConfig()
+	ours.Models["b"] = &ModelConfig{Name: "b", URL: "https://b"}
+	ours.Models["c"] = &ModelConfig{Name: "c", URL: "https://c"}
+
+	// They changed b and added d
+	theirs := newConfig()
+	theirs.Models["a"] = &ModelConfig{Name: "a", URL: "https://a"}
+	theirs.Models["b"] = &ModelConfig{Name: "b", URL: "https://b2"}
+	theirs.Models["d"] = &ModelConfig{Name: "d", URL: "https://d"}
+
+	merged := mergeConfig(base, ours, theirs)
+	if _, exists := merged.Models["a"]; exists {
+		t.Errorf("Expected a to be removed")
+	}
+	if merged.Models["b"].URL != "https://b2" {
+		t.Errorf("Expected their change to b to be kept")
+	}
+	if merged.Models["c"] == nil || merged.Models["d"] == nil {
+		t.Errorf("Expected both added models, got %v", sortedKeys(merged.Models))
+	}
+}
+
+func TestModelManager_EmptyModelEntry(t *testing.T) {
+	m := newTestManager(t, `version: 1
+providers:
+  gateway:
+    url: https://gateway.example.com
+models:
+  foo:
+`)
+
+	if names := m.ModelsUsingProvider("gateway"); len(names) != 0 {
+		t.Errorf("Expected no models using the profile, got %v", names)
+	}
+	if _, err := m.GetModelConfig("foo"); err != ErrModelNotFound {
+		t.Errorf("Expected ErrModelNotFound for an empty entry, got %v", err)
+	}
+	if _, err := m.ResolvedModelConfig("foo"); err != ErrModelNotFound {
+		t.Errorf("Expected ErrModelNotFound for an empty entry, got %v", err)
+	}
+	if err := m.UpdateProvider("gateway", &ProviderConfig{URL: "https://other.example.com"}); err != nil {
+		t.Errorf("UpdateProvider failed: %v", err)
+	}
+	if err := m.RemoveProvider("gateway"); err != nil {
+		t.Errorf("RemoveProvider failed: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Middleware wraps a model to add behavior around its calls, such as logging,
+// retries or rate limiting, without changing the provider code
+type Middleware func(Model) Model
+
+// Chain wraps model with middlewares. The first middleware is the outermost one
+// and sees each call first.
+func Chain(model Model, middlewares ...Middleware) Model {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		model = middlewares[i](model)
+	}
+	return model
+}
+
+// Call describes a request to a model as seen by an interceptor
+type Call struct {
+	Model    string
+	Question string
+	// FileName and FileContent are set for ChatWithFile
+	FileName    string
+	FileContent string
+	Options     []ChatOption
+}
+
+// HasFile reports whether the call asks about a file
+func (c *Call) HasFile() bool {
+	return c.FileName != ""
+}
+
+// Handler answers a call
+type Handler func(ctx context.Context, call *Call) (string, error)
+
+// Interceptor creates a middleware from a function that handles both Chat and ChatWithFile.
+// The function may change the call before passing it to next, or answer it itself.
+func Interceptor(intercept func(ctx context.Context, call *Call, next Handler) (string, error)) Middleware {
+	return func(model Model) Model {
+		return &interceptedModel{model: model, intercept: intercept}
+	}
+}
+
+// interceptedModel passes every call of a model through an interceptor
+type interceptedModel struct {
+	model     Model
+	intercept func(ctx context.Context, call *Call, next Handler) (string, error)
+}
+
+func (m *interceptedModel) Name() string {
+	return m.model.Name()
+}
+
+// Unwrap returns the model the interceptor wraps
+func (m *interceptedModel) Unwrap() Model {
+	return m.model
+}
+
+func (m *interceptedModel) Chat(ctx context.Context, question string, options ...ChatOption) (string, error) {
+	return m.intercept(ctx, &Call{Model: m.model.Name(), Question: question, Options: options}, m.next)
+}
+
+func (m *interceptedModel) ChatWithFile(ctx context.Context, question string, fileName string, fileContent string, options ...ChatOption) (string, error) {
+	call := &Call{Model: m.model.Name(), Question: question, FileName: fileName, FileContent: fileContent, Options: options}
+	return m.intercept(ctx, call, m.next)
+}
+
+// next forwards a call to the wrapped model
+func (m *interceptedModel) next(ctx context.Context, call *Call) (string, error) {
+	if call.HasFile() {
+		return m.model.ChatWithFile(ctx, call.Question, call.FileName, call.FileContent, call.Options...)
+	}
+	return m.model.Chat(ctx, call.Question, call.Options...)
+}
+
+// Logging logs each request and response at debug level
+func Logging(logger *slog.Logger) Middleware {
+	return Interceptor(func(ctx context.Context, call *Call, next Handler) (string, error) {
+		attrs := []any{"model", call.Model, "question", call.Question}
+		if call.HasFile() {
+			attrs = append(attrs, "file", call.FileName, "file_bytes", len(call.FileContent))
+		}
+		logger.DebugContext(ctx, "model request", attrs...)
+
+		start := time.Now()
+		answer, err := next(ctx, call)
+		if err != nil {
+			logger.DebugContext(ctx, "model error", "model", call.Model, "elapsed", time.Since(start), "error", err)
+			return answer, err
+		}
+
+		logger.DebugContext(ctx, "model response", "model", call.Model, "elapsed", time.Since(start), "answer", answer)
+		return answer, nil
+	})
+}
+
+// Timing reports the duration and result of each call to observe
+func Timing(observe func(model string, elapsed time.Duration, err error)) Middleware {
+	return Interceptor(func(ctx context.Context, call *Call, next Handler) (string, error) {
+		start := time.Now()
+		answer, err := next(ctx, call)
+		observe(call.Model, time.Since(start), err)
+		return answer, err
+	})
+}
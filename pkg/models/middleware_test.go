@@ -0,0 +1,105 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoModel answers with the question, or the file name and question
+type echoModel struct {
+	err error
+}
+
+func (m *echoModel) Name() string { return "echo" }
+
+func (m *echoModel) Chat(ctx context.Context, question string, options ...ChatOption) (string, error) {
+	return question, m.err
+}
+
+func (m *echoModel) ChatWithFile(ctx context.Context, question string, fileName string, fileContent string, options ...ChatOption) (string, error) {
+	return fileName + ": " + question, m.err
+}
+
+// tag returns a middleware appending name to questions on the way in
+func tag(name string) Middleware {
+	return Interceptor(func(ctx context.Context, call *Call, next Handler) (string, error) {
+		call.Question += " " + name
+		return next(ctx, call)
+	})
+}
+
+func TestChain(t *testing.T) {
+	model := Chain(&echoModel{}, tag("outer"), tag("inner"))
+
+	answer, err := model.Chat(context.Background(), "q")
+	if err != nil {
+		t.Fatalf("Chat failed: %v", err)
+	}
+	if answer != "q outer inner" {
+		t.Errorf("Expected the first middleware to run first, got %q", answer)
+	}
+
+	answer, _ = model.ChatWithFile(context.Background(), "q", "main.go", "package main")
+	if answer != "main.go: q outer inner" {
+		t.Errorf("Expected file calls to be intercepted, got %q", answer)
+	}
+	if model.Name() != "echo" {
+		t.Errorf("Expected the wrapped model name, got %q", model.Name())
+	}
+}
+
+func TestLoggingAndTiming(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	var observed []error
+	timing := Timing(func(model string, elapsed time.Duration, err error) {
+		if model != "echo" || elapsed < 0 {
+			t.Errorf("Unexpected timing for %s: %v", model, elapsed)
+		}
+		observed = append(observed, err)
+	})
+
+	failure := errors.New("boom")
+	for _, inner := range []*echoModel{{}, {err: failure}} {
+		Chain(inner, Logging(logger), timing).Chat(context.Background(), "hello")
+	}
+
+	if len(observed) != 2 || observed[0] != nil || !errors.Is(observed[1], failure) {
+		t.Errorf("Expected one success and one failure to be timed, got %v", observed)
+	}
+	for _, want := range []string{"model request", "model response", "model error", "question=hello", "error=boom"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("Expected log to contain %q, got:\n%s", want, logs.String())
+		}
+	}
+}
+
+func TestModelManager_Use(t *testing.T) {
+	m := newTestManager(t, `version: 2
+models:
+  gpt-4o:
+    name: gpt-4o
+    url: https://api.openai.com
+    api_key: sk-test
+`)
+
+	// Answer without calling the API to check the middleware wraps managed models
+	m.Use(Interceptor(func(ctx context.Context, call *Call, next Handler) (string, error) {
+		return "intercepted " + call.Model, nil
+	}))
+
+	model, err := m.GetModel("gpt-4o")
+	if err != nil {
+		t.Fatalf("GetModel failed: %v", err)
+	}
+	answer, err := model.Chat(context.Background(), "hi")
+	if err != nil || answer != "intercepted gpt-4o" {
+		t.Errorf("Expected the middleware to answer, got %q, %v", answer, err)
+	}
+}
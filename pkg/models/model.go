@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"fmt"
 )
 
 // Model represents an AI model interface
@@ -19,10 +20,36 @@ type Model interface {
 // ModelConfig stores model configuration
 type ModelConfig struct {
 	Name               string       `json:"name" yaml:"name"`
+	Provider           string       `json:"provider,omitempty" yaml:"provider,omitempty"`
+	Profile            string       `json:"profile,omitempty" yaml:"profile,omitempty"`
 	URL                string       `json:"url" yaml:"url"`
 	APIKey             string       `json:"api_key" yaml:"api_key"`
-	DefaultEnabled     bool         `json:"default_enabled" yaml:"default_enabled"`
+	DefaultEnabled     bool         `json:"default_enabled,omitempty" yaml:"default_enabled,omitempty"`
 	DefaultChatOptions *ChatOptions `json:"default_chat_options" yaml:"default_chat_options"`
+	Pricing            *Pricing     `json:"pricing,omitempty" yaml:"pricing,omitempty"`
+	Tags               []string     `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Fallbacks          []string     `json:"fallbacks,omitempty" yaml:"fallbacks,omitempty"`
+	// Vision tells whether the model reads images, guessed from its name when not set
+	Vision *bool `json:"vision,omitempty" yaml:"vision,omitempty"`
+
+	// Connection settings, inherited from the profile when not set
+	Headers         map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	TransportConfig `json:",inline" yaml:",inline"`
+}
+
+// Pricing is the price of a model in USD per million tokens
+type Pricing struct {
+	Input  float64 `json:"input" yaml:"input"`
+	Output float64 `json:"output" yaml:"output"`
+}
+
+// Cost returns the price of a call with the given token usage.
+// It returns 0 when no pricing is set.
+func (p *Pricing) Cost(usage Usage) float64 {
+	if p == nil {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*p.Input + float64(usage.CompletionTokens)*p.Output) / 1_000_000
 }
 
 // ChatOption represents a chat option function
@@ -30,10 +57,24 @@ type ChatOption func(*ChatOptions)
 
 // ChatOptions represents a collection of chat options
 type ChatOptions struct {
-	Temperature float64
-	MaxTokens   int
-	Stream      bool
-	History     []Message
+	Temperature  float64
+	MaxTokens    int
+	Stream       bool
+	History      []Message
+	SystemPrompt string `yaml:"systemprompt,omitempty"`
+
+	// ResponseInfo receives the details of the response, it is not part of the request
+	ResponseInfo *ResponseInfo `yaml:"-"`
+	// Cache controls the response cache for this request
+	Cache CacheMode `yaml:"-"`
+	// StreamHandler receives streamed text instead of standard output
+	StreamHandler func(chunk string) `yaml:"-"`
+	// Tools are the functions the model may ask to call, see ToolRegistry
+	Tools []Tool `yaml:"-"`
+	// ResponseFormat asks for a JSON reply, see JSONOutput
+	ResponseFormat *ResponseFormat `yaml:"-"`
+	// Images are sent with the question
+	Images []ContentPart `yaml:"-"`
 }
 
 // WithTemperature sets the temperature parameter
@@ -64,6 +105,111 @@ func WithHistory(messages []Message) ChatOption {
 	}
 }
 
+// WithSystemPrompt sets the system prompt sent before the conversation
+func WithSystemPrompt(prompt string) ChatOption {
+	return func(o *ChatOptions) {
+		o.SystemPrompt = prompt
+	}
+}
+
+// WithCacheMode sets how the response cache is used for this request
+func WithCacheMode(mode CacheMode) ChatOption {
+	return func(o *ChatOptions) {
+		o.Cache = mode
+	}
+}
+
+// WithStreamHandler passes streamed text to handler as it arrives instead of printing it
+func WithStreamHandler(handler func(chunk string)) ChatOption {
+	return func(o *ChatOptions) {
+		o.StreamHandler = handler
+	}
+}
+
+// WithTools offers functions the model may ask to call instead of answering
+func WithTools(tools ...Tool) ChatOption {
+	return func(o *ChatOptions) {
+		o.Tools = tools
+	}
+}
+
+// WithResponseFormat asks the model to reply with JSON, matching a schema if the format has one
+func WithResponseFormat(format *ResponseFormat) ChatOption {
+	return func(o *ChatOptions) {
+		o.ResponseFormat = format
+	}
+}
+
+// WithImages sends images with the question, see ImageDataPart and ImageURLPart
+func WithImages(images ...ContentPart) ChatOption {
+	return func(o *ChatOptions) {
+		o.Images = images
+	}
+}
+
+// buildMessages creates the messages for a question: system prompt, history, then the question.
+// Images are sent as parts of the question.
+// An empty question continues the history, as after tool results.
+func buildMessages(opts *ChatOptions, question string) []Message {
+	messages := []Message{}
+
+	// Add system prompt if provided
+	if opts.SystemPrompt != "" {
+		messages = append(messages, Message{
+			Role:    "system",
+			Content: opts.SystemPrompt,
+		})
+	}
+
+	// Add history messages if provided
+	if len(opts.History) > 0 {
+		messages = append(messages, opts.History...)
+	}
+
+	// Add current question, with its images
+	if question != "" || len(opts.History) == 0 {
+		message := Message{
+			Role:    "user",
+			Content: question,
+		}
+		if len(opts.Images) > 0 {
+			message.Parts = append([]ContentPart{TextPart(question)}, opts.Images...)
+		}
+		messages = append(messages, message)
+	}
+
+	return messages
+}
+
+// fileMessages creates the messages for a question about a file: system prompt, then the
+// question with the file and its images. The history is not sent with a file.
+func fileMessages(opts *ChatOptions, question, fileName, fileContent string) []Message {
+	fileOpts := *opts
+	fileOpts.History = nil
+	return buildMessages(&fileOpts, filePrompt(question, fileName, fileContent))
+}
+
+// filePrompt builds the question sent with a file
+func filePrompt(question, fileName, fileContent string) string {
+	return fmt.Sprintf("file name: %s\n\nfile content:\n%s\n\nquestion: %s", fileName, fileContent, question)
+}
+
+// emitChunk passes a part of a streamed answer to handler, or prints it when handler is nil
+func emitChunk(handler func(string), content string) {
+	if handler != nil {
+		handler(content)
+		return
+	}
+	fmt.Print(content)
+}
+
+// endStream ends a streamed answer printed on standard output, making subsequent output more pretty
+func endStream(handler func(string)) {
+	if handler == nil {
+		fmt.Println()
+	}
+}
+
 // DefaultChatOptions returns default chat options
 func DefaultChatOptions() *ChatOptions {
 	return &ChatOptions{
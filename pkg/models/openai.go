@@ -9,7 +9,6 @@ import (
 	"io"
 	"net/http"
 	"strings"
-	"time"
 )
 
 // OpenAI API response structure
@@ -29,12 +28,29 @@ type OpenAIRequest struct {
 	Temperature float64   `json:"temperature"`
 	MaxTokens   int       `json:"max_tokens,omitempty"`
 	Stream      bool      `json:"stream,omitempty"`
+	Tools       []Tool    `json:"tools,omitempty"`
+
+	// ResponseFormat asks for a JSON reply
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	// StreamOptions asks for token usage at the end of a stream
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+}
+
+// StreamOptions represents the options of a streaming request
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // Message represents a message in a conversation
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ToolCalls are the functions an assistant message asks to call
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID is the call a tool message answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// Parts are the text and images of the message, sent instead of Content when set
+	Parts []ContentPart `json:"-"`
 }
 
 // Choice represents a choice returned by the API
@@ -55,21 +71,18 @@ type Usage struct {
 type OpenAIClient struct {
 	apiKey     string
 	apiURL     string
+	headers    map[string]string
 	httpClient *http.Client
 	model      string
 }
 
 // NewOpenAIClient creates a new OpenAI client
 func NewOpenAIClient(modelConfig ModelConfig) *OpenAIClient {
-	// Create HTTP client, set timeout
-	httpClient := &http.Client{
-		Timeout: 60 * time.Second,
-	}
-
 	return &OpenAIClient{
 		apiKey:     modelConfig.APIKey,
 		apiURL:     modelConfig.URL,
-		httpClient: httpClient,
+		headers:    modelConfig.Headers,
+		httpClient: newHTTPClient(modelConfig.TransportConfig),
 		model:      modelConfig.Name,
 	}
 }
@@ -88,6 +101,12 @@ func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, opts *ChatO
 		Temperature: opts.Temperature,
 		MaxTokens:   opts.MaxTokens,
 		Stream:      opts.Stream,
+		Tools:       opts.Tools,
+
+		ResponseFormat: opts.ResponseFormat,
+	}
+	if opts.Stream {
+		req.StreamOptions = &StreamOptions{IncludeUsage: true}
 	}
 
 	// Convert request to JSON
@@ -111,8 +130,13 @@ func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, opts *ChatO
 	// Set request headers
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	setCustomHeaders(httpReq, c.headers)
 
 	// Send request
+	info := opts.ResponseInfo
+	start := info.start("openai", c.model, opts)
+	defer info.finish(start)
+
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
 		return "", fmt.Errorf("failed to send request: %w", err)
@@ -121,21 +145,20 @@ func (c *OpenAIClient) Chat(ctx context.Context, messages []Message, opts *ChatO
 
 	// Check response status
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed, status code: %d, response: %s", resp.StatusCode, string(body))
+		return "", newAPIError(resp)
 	}
 
 	// Handle stream response
 	if opts.Stream {
-		return c.handleStreamResponse(resp.Body)
+		return c.handleStreamResponse(resp.Body, opts.StreamHandler, info)
 	}
 
 	// Handle normal response
-	return c.handleNormalResponse(resp.Body)
+	return c.handleNormalResponse(resp.Body, info)
 }
 
-// handleNormalResponse handles normal responses
-func (c *OpenAIClient) handleNormalResponse(respBody io.Reader) (string, error) {
+// handleNormalResponse handles normal responses, recording usage in info when it is not nil
+func (c *OpenAIClient) handleNormalResponse(respBody io.Reader, info *ResponseInfo) (string, error) {
 	var apiResp OpenAIResponse
 
 	// Parse response
@@ -148,15 +171,24 @@ func (c *OpenAIClient) handleNormalResponse(respBody io.Reader) (string, error)
 		return "", fmt.Errorf("API returned empty response")
 	}
 
+	if info != nil {
+		info.Usage = apiResp.Usage
+		info.FinishReason = apiResp.Choices[0].FinishReason
+		info.ToolCalls = apiResp.Choices[0].Message.ToolCalls
+		info.setUpstreamModel(apiResp.Model)
+	}
+
 	// Return the content of the first choice
 	return apiResp.Choices[0].Message.Content, nil
 }
 
-// handleStreamResponse handles stream responses
-func (c *OpenAIClient) handleStreamResponse(respBody io.Reader) (string, error) {
+// handleStreamResponse handles stream responses, passing text to onChunk or printing it when onChunk is nil,
+// and recording usage in info when it is not nil
+func (c *OpenAIClient) handleStreamResponse(respBody io.Reader, onChunk func(string), info *ResponseInfo) (string, error) {
 	// Use bufio.Scanner to read line by line in SSE format
 	scanner := bufio.NewScanner(respBody)
 	var fullContent strings.Builder
+	var toolCalls toolCallBuilder
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -188,11 +220,14 @@ func (c *OpenAIClient) handleStreamResponse(respBody io.Reader) (string, error)
 			Choices []struct {
 				Index int `json:"index"`
 				Delta struct {
-					Role    string `json:"role,omitempty"`
-					Content string `json:"content,omitempty"`
+					Role      string          `json:"role,omitempty"`
+					Content   string          `json:"content,omitempty"`
+					ToolCalls []toolCallDelta `json:"tool_calls,omitempty"`
 				} `json:"delta"`
 				FinishReason *string `json:"finish_reason"`
 			} `json:"choices"`
+			// Usage is sent in a last chunk without choices
+			Usage *Usage `json:"usage,omitempty"`
 		}
 
 		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
@@ -200,13 +235,27 @@ func (c *OpenAIClient) handleStreamResponse(respBody io.Reader) (string, error)
 			continue
 		}
 
+		if info != nil {
+			info.setUpstreamModel(chunk.Model)
+			if chunk.Usage != nil {
+				info.Usage = *chunk.Usage
+			}
+			if len(chunk.Choices) > 0 && chunk.Choices[0].FinishReason != nil {
+				info.FinishReason = *chunk.Choices[0].FinishReason
+			}
+		}
+
 		// Extract content and add to result
 		if len(chunk.Choices) > 0 {
+			if err := toolCalls.add(chunk.Choices[0].Delta.ToolCalls); err != nil {
+				return fullContent.String(), err
+			}
 			content := chunk.Choices[0].Delta.Content
 			if content != "" {
 				fullContent.WriteString(content)
+				info.firstToken()
 				// Print content in real time
-				fmt.Print(content)
+				emitChunk(onChunk, content)
 			}
 		}
 	}
@@ -216,40 +265,42 @@ func (c *OpenAIClient) handleStreamResponse(respBody io.Reader) (string, error)
 		return fullContent.String(), fmt.Errorf("error scanning stream response: %w", err)
 	}
 
+	if info != nil {
+		info.ToolCalls = toolCalls.calls()
+	}
+
 	// Output newline, making subsequent output more pretty
-	fmt.Println()
+	endStream(onChunk)
 
 	return fullContent.String(), nil
 }
 
 // Enhance OpenAIModel implementation
 func (m *OpenAIModel) Chat(ctx context.Context, question string, options ...ChatOption) (string, error) {
-	// Apply options
-	opts := DefaultChatOptions()
+	// Apply default options from model config if available
+	var opts *ChatOptions
+	if m.config.DefaultChatOptions != nil {
+		// Create a copy of default options
+		defaultOpts := *m.config.DefaultChatOptions
+		opts = &defaultOpts
+	} else {
+		// Use global defaults
+		opts = DefaultChatOptions()
+	}
+
+	// Apply user-provided options
 	for _, option := range options {
 		option(opts)
 	}
 
 	// Create messages array
-	messages := []Message{}
-
-	// Add history messages if provided
-	if len(opts.History) > 0 {
-		messages = append(messages, opts.History...)
+	messages := buildMessages(opts, question)
+	if err := checkImages(m.config, messages); err != nil {
+		return "", err
 	}
 
-	// Add current question
-	messages = append(messages, Message{
-		Role:    "user",
-		Content: question,
-	})
-
 	// Send to API
-	client := NewOpenAIClient(ModelConfig{
-		Name:   m.config.Name,
-		URL:    m.config.URL,
-		APIKey: m.config.APIKey,
-	})
+	client := NewOpenAIClient(*m.config)
 	return client.Chat(ctx, messages, opts)
 }
 
@@ -271,24 +322,13 @@ func (m *OpenAIModel) ChatWithFile(ctx context.Context, question string, fileNam
 		option(opts)
 	}
 
-	// Create client
-	client := NewOpenAIClient(ModelConfig{
-		Name:   m.config.Name,
-		URL:    m.config.URL,
-		APIKey: m.config.APIKey,
-	})
-
-	// Build prompt with file content
-	prompt := fmt.Sprintf("file name: %s\n\nfile content:\n%s\n\nquestion: %s", fileName, fileContent, question)
-
-	// Create messages
-	messages := []Message{
-		{
-			Role:    "user",
-			Content: prompt,
-		},
+	// Create messages with the file content
+	messages := fileMessages(opts, question, fileName, fileContent)
+	if err := checkImages(m.config, messages); err != nil {
+		return "", err
 	}
 
 	// Send request
+	client := NewOpenAIClient(*m.config)
 	return client.Chat(ctx, messages, opts)
 }
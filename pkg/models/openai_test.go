@@ -341,7 +341,7 @@ data: [DONE]
 	})
 
 	// Call handleStreamResponse
-	result, err := client.handleStreamResponse(reader)
+	result, err := client.handleStreamResponse(reader, nil, nil)
 	if err != nil {
 		t.Fatalf("Failed to handle stream response: %v", err)
 	}
@@ -366,7 +366,7 @@ func TestHandleStreamResponseError(t *testing.T) {
 	})
 
 	// Call handleStreamResponse
-	_, err := client.handleStreamResponse(errorReader)
+	_, err := client.handleStreamResponse(errorReader, nil, nil)
 
 	// Verify if an error is returned
 	if err == nil {
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// probePrompt is the tiny prompt sent by Probe
+const probePrompt = "Say hello in one short sentence."
+
+// ProbeResult is the outcome of a test request to a model
+type ProbeResult struct {
+	Model  string
+	Answer string
+	// TimeToFirstToken is the time until the first streamed text arrived
+	TimeToFirstToken time.Duration
+	Latency          time.Duration
+	Usage            Usage
+	Err              error
+}
+
+// TokensPerSecond returns the generation speed after the first token, or 0 when the
+// API did not report token usage
+func (r ProbeResult) TokensPerSecond() float64 {
+	generation := r.Latency - r.TimeToFirstToken
+	if r.Usage.CompletionTokens == 0 || generation <= 0 {
+		return 0
+	}
+	return float64(r.Usage.CompletionTokens) / generation.Seconds()
+}
+
+// Probe sends a tiny streamed prompt to a model, without history or cache, and
+// measures how it answers. The answer is collected instead of printed.
+func Probe(ctx context.Context, model Model) ProbeResult {
+	var answer strings.Builder
+	info := &ResponseInfo{}
+
+	start := time.Now()
+	_, err := model.Chat(ctx, probePrompt,
+		WithStream(true),
+		WithMaxTokens(32),
+		WithHistory(nil),
+		WithSystemPrompt(""),
+		WithCacheMode(CacheBypass),
+		WithResponseInfo(info),
+		WithStreamHandler(func(chunk string) { answer.WriteString(chunk) }),
+	)
+
+	result := ProbeResult{
+		Model:            model.Name(),
+		Answer:           strings.TrimSpace(answer.String()),
+		TimeToFirstToken: info.TimeToFirstToken,
+		Latency:          time.Since(start),
+		Usage:            info.Usage,
+		Err:              err,
+	}
+	if info.Latency > 0 {
+		result.Latency = info.Latency
+	}
+	return result
+}
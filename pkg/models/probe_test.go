@@ -0,0 +1,46 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-good" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		flusher := w.(http.Flusher)
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n"))
+		flusher.Flush()
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\" there\"},\"finish_reason\":\"stop\"}]}\n\n"))
+		w.Write([]byte("data: {\"choices\":[],\"usage\":{\"prompt_tokens\":8,\"completion_tokens\":4,\"total_tokens\":12}}\n\n"))
+		w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	result := Probe(context.Background(), NewOpenAIModel(&ModelConfig{Name: "gpt-test", URL: server.URL, APIKey: "sk-good"}))
+	if result.Err != nil {
+		t.Fatalf("Probe failed: %v", result.Err)
+	}
+	if result.Answer != "Hello there" {
+		t.Errorf("Expected the streamed answer to be collected, got %q", result.Answer)
+	}
+	if result.TimeToFirstToken <= 0 || result.TimeToFirstToken >= result.Latency {
+		t.Errorf("Expected the first token before the end, got %v of %v", result.TimeToFirstToken, result.Latency)
+	}
+	if result.Usage.CompletionTokens != 4 || result.TokensPerSecond() <= 0 {
+		t.Errorf("Expected usage and speed, got %+v, %v tokens/s", result.Usage, result.TokensPerSecond())
+	}
+
+	result = Probe(context.Background(), NewOpenAIModel(&ModelConfig{Name: "gpt-test", URL: server.URL, APIKey: "sk-bad"}))
+	if result.Err == nil || result.Model != "gpt-test" {
+		t.Errorf("Expected an error for a rejected key, got %+v", result)
+	}
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pokitpeng/ai/pkg/util"
+)
+
+// ProjectConfigFile is the name of the project-local configuration file
+const ProjectConfigFile = ".ai.yaml"
+
+// ErrOutsideProject is returned for a context file outside the project directory
+var ErrOutsideProject = errors.New("context file is outside the project directory")
+
+// ProjectConfig stores settings for a repository, read from .ai.yaml
+type ProjectConfig struct {
+	// Path is the location of the file the settings were read from
+	Path string `yaml:"-"`
+
+	Model        string   `yaml:"model,omitempty"`
+	SystemPrompt string   `yaml:"system_prompt,omitempty"`
+	ContextFiles []string `yaml:"context_files,omitempty"`
+}
+
+// LoadProjectConfig finds .ai.yaml in dir or its parents and loads it.
+// It returns nil when there is no project configuration.
+func LoadProjectConfig(dir string) (*ProjectConfig, error) {
+	path := util.FindUp(dir, ProjectConfigFile)
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project config: %w", err)
+	}
+
+	config := &ProjectConfig{}
+	if err := decodeStrict(data, config); err != nil {
+		if configErr, ok := err.(*ConfigError); ok {
+			configErr.File = path
+		}
+		return nil, err
+	}
+	config.Path = path
+
+	return config, nil
+}
+
+// Dir returns the directory containing the project configuration
+func (p *ProjectConfig) Dir() string {
+	return filepath.Dir(p.Path)
+}
+
+// BuildSystemPrompt combines the system prompt with the content of the context files.
+// Context file paths are resolved against the project directory and must stay inside it,
+// so a cloned repository can't send other files of the user to the model.
+func (p *ProjectConfig) BuildSystemPrompt() (string, error) {
+	prompt := p.SystemPrompt
+
+	for _, file := range p.ContextFiles {
+		path, err := p.resolveContextFile(file)
+		if err != nil {
+			return "", fmt.Errorf("failed to load context file %s: %w", file, err)
+		}
+
+		content, language, err := util.GetFileInfo(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to load context file %s: %w", file, err)
+		}
+
+		if prompt != "" {
+			prompt += "\n\n"
+		}
+		prompt += fmt.Sprintf("context file: %s (%s)\n\n%s", file, language, content)
+	}
+
+	return prompt, nil
+}
+
+// resolveContextFile returns the real path of a context file, following symbolic links,
+// and ErrOutsideProject when it is not in the project directory
+func (p *ProjectConfig) resolveContextFile(file string) (string, error) {
+	root, err := filepath.EvalSymlinks(p.Dir())
+	if err != nil {
+		return "", err
+	}
+
+	path := file
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrOutsideProject
+	}
+	return real, nil
+}
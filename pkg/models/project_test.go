@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	subDir := filepath.Join(root, "pkg", "sub")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatalf("Failed to create directories: %v", err)
+	}
+
+	projectFile := filepath.Join(root, ProjectConfigFile)
+	os.WriteFile(projectFile, []byte("model: qwen-7b\nsystem_prompt: Answer in Go.\ncontext_files:\n  - docs/arch.md\n"), 0644)
+	os.MkdirAll(filepath.Join(root, "docs"), 0755)
+	os.WriteFile(filepath.Join(root, "docs", "arch.md"), []byte("# Architecture"), 0644)
+
+	// The file is found from a nested directory
+	config, err := LoadProjectConfig(subDir)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig failed: %v", err)
+	}
+	if config == nil || config.Path != projectFile {
+		t.Fatalf("Expected project config at %s, got %+v", projectFile, config)
+	}
+	if config.Model != "qwen-7b" {
+		t.Errorf("Expected model qwen-7b, got %s", config.Model)
+	}
+
+	// Context files are resolved relative to the project directory
+	prompt, err := config.BuildSystemPrompt()
+	if err != nil {
+		t.Fatalf("BuildSystemPrompt failed: %v", err)
+	}
+	if !strings.HasPrefix(prompt, "Answer in Go.") || !strings.Contains(prompt, "# Architecture") {
+		t.Errorf("Unexpected system prompt: %q", prompt)
+	}
+
+	// Context files outside the project are refused, through symbolic links too
+	secret := filepath.Join(t.TempDir(), "credentials")
+	os.WriteFile(secret, []byte("secret"), 0600)
+	os.Symlink(secret, filepath.Join(root, "docs", "link.md"))
+	relative, _ := filepath.Rel(root, secret)
+	for _, file := range []string{secret, relative, "docs/link.md"} {
+		config.ContextFiles = []string{file}
+		if _, err := config.BuildSystemPrompt(); !errors.Is(err, ErrOutsideProject) {
+			t.Errorf("Expected ErrOutsideProject for %s, got %v", file, err)
+		}
+	}
+
+	// Unknown fields are reported
+	os.WriteFile(projectFile, []byte("modle: typo\n"), 0644)
+	if _, err := LoadProjectConfig(subDir); err == nil {
+		t.Errorf("Expected error for unknown field")
+	}
+}
+
+func TestBuildMessages_SystemPrompt(t *testing.T) {
+	opts := DefaultChatOptions()
+	WithSystemPrompt("be brief")(opts)
+	WithHistory([]Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}})(opts)
+
+	messages := buildMessages(opts, "question")
+	if len(messages) != 4 || messages[0].Role != "system" || messages[3].Content != "question" {
+		t.Errorf("Unexpected messages: %+v", messages)
+	}
+}
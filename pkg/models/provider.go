@@ -0,0 +1,140 @@
+package models
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// Default timeout for HTTP requests to the model API
+const defaultRequestTimeout = 60 * time.Second
+
+// TransportConfig stores HTTP transport settings
+type TransportConfig struct {
+	Timeout            time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Proxy              string        `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+	InsecureSkipVerify bool          `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// ProviderConfig stores a connection profile shared by several models
+type ProviderConfig struct {
+	Type            string            `json:"type,omitempty" yaml:"type,omitempty"`
+	URL             string            `json:"url" yaml:"url"`
+	APIKey          string            `json:"api_key,omitempty" yaml:"api_key,omitempty"`
+	APIKeyEnv       string            `json:"api_key_env,omitempty" yaml:"api_key_env,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	TransportConfig `json:",inline" yaml:",inline"`
+}
+
+// ResolveAPIKey returns the API key, reading it from the environment if configured
+func (p *ProviderConfig) ResolveAPIKey() string {
+	if p.APIKeyEnv != "" {
+		if key := os.Getenv(p.APIKeyEnv); key != "" {
+			return key
+		}
+	}
+	return p.APIKey
+}
+
+// resolveModelConfig merges a model configuration with its connection profile.
+// Values set on the model take precedence over the profile.
+func resolveModelConfig(config *ModelConfig, providers map[string]*ProviderConfig) (*ModelConfig, error) {
+	if config.Profile == "" {
+		return config, nil
+	}
+
+	profile, exists := providers[config.Profile]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrProviderNotFound, config.Profile)
+	}
+
+	resolved := *config
+	if resolved.Provider == "" {
+		resolved.Provider = profile.Type
+	}
+	if resolved.URL == "" {
+		resolved.URL = profile.URL
+	}
+	if resolved.APIKey == "" {
+		resolved.APIKey = profile.ResolveAPIKey()
+	}
+	if resolved.Timeout == 0 {
+		resolved.Timeout = profile.Timeout
+	}
+	if resolved.Proxy == "" {
+		resolved.Proxy = profile.Proxy
+	}
+	if !resolved.InsecureSkipVerify {
+		resolved.InsecureSkipVerify = profile.InsecureSkipVerify
+	}
+
+	// Merge headers, model headers override profile headers
+	if len(profile.Headers) > 0 {
+		headers := make(map[string]string, len(profile.Headers)+len(config.Headers))
+		for k, v := range profile.Headers {
+			headers[k] = v
+		}
+		for k, v := range config.Headers {
+			headers[k] = v
+		}
+		resolved.Headers = headers
+	}
+
+	return &resolved, nil
+}
+
+// newHTTPClient creates an HTTP client from transport settings
+func newHTTPClient(transport TransportConfig) *http.Client {
+	timeout := transport.Timeout
+	if timeout == 0 {
+		timeout = defaultRequestTimeout
+	}
+
+	httpClient := &http.Client{
+		Timeout: timeout,
+	}
+
+	if transport.Proxy == "" && !transport.InsecureSkipVerify {
+		return withDebug(httpClient)
+	}
+
+	httpTransport := http.DefaultTransport.(*http.Transport).Clone()
+	if transport.Proxy != "" {
+		if proxyURL, err := url.Parse(transport.Proxy); err == nil {
+			httpTransport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	if transport.InsecureSkipVerify {
+		httpTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	httpClient.Transport = httpTransport
+
+	return withDebug(httpClient)
+}
+
+// APIError is returned when the API answers with an unexpected status code
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed, status code: %d, response: %s", e.StatusCode, e.Body)
+}
+
+// newAPIError reads the body of a failed response into an APIError
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(resp.Body)
+	return &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+}
+
+// setCustomHeaders adds the configured extra headers to a request
+func setCustomHeaders(req *http.Request, headers map[string]string) {
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+}
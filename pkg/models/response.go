@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+)
+
+// ResponseInfo describes how an answer was produced.
+// Pass one with WithResponseInfo to have it filled in during the call.
+type ResponseInfo struct {
+	// Model is the configured model name
+	Model string
+	// UpstreamModel is the model name reported by the API, when it differs
+	UpstreamModel string
+	// Provider is the API type used for the call
+	Provider     string
+	Temperature  float64
+	Usage        Usage
+	FinishReason string
+	// Latency is the time from sending the request to the end of the answer
+	Latency time.Duration
+	// TimeToFirstToken is the time from sending the request to the first streamed text
+	TimeToFirstToken time.Duration
+	// Cached reports that the answer came from the response cache, without an API call
+	Cached bool
+	// Fallbacks lists the models that failed before Model answered
+	Fallbacks []Fallback
+	// ToolCalls are the functions the model asked to call instead of answering
+	ToolCalls []ToolCall
+	// ToolResults are the calls run by ToolRegistry.Run before the answer
+	ToolResults []ToolResult
+
+	started time.Time
+}
+
+// WithResponseInfo collects the details of the response into info
+func WithResponseInfo(info *ResponseInfo) ChatOption {
+	return func(o *ChatOptions) {
+		o.ResponseInfo = info
+	}
+}
+
+// start records the request side of the call and returns the time it was sent
+func (info *ResponseInfo) start(provider, model string, opts *ChatOptions) time.Time {
+	if info != nil {
+		info.Model = model
+		info.Provider = provider
+		info.Temperature = opts.Temperature
+		info.started = time.Now()
+	}
+	return time.Now()
+}
+
+// finish records the latency of a call started at start
+func (info *ResponseInfo) finish(start time.Time) {
+	if info != nil {
+		info.Latency = time.Since(start)
+	}
+}
+
+// firstToken records the time to the first streamed text
+func (info *ResponseInfo) firstToken() {
+	if info != nil && info.TimeToFirstToken == 0 && !info.started.IsZero() {
+		info.TimeToFirstToken = time.Since(info.started)
+	}
+}
+
+// setUpstreamModel records the model reported by the API when it differs from the configured one
+func (info *ResponseInfo) setUpstreamModel(model string) {
+	if info != nil && model != "" && model != info.Model {
+		info.UpstreamModel = model
+	}
+}
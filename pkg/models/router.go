@@ -0,0 +1,200 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pokitpeng/ai/pkg/util"
+)
+
+// Commands a route rule can match
+const (
+	// RouteCommandRoot is a question asked directly, as with 'ai "..."'
+	RouteCommandRoot = "root"
+	// RouteCommandFile is a question about a file, as with 'ai file'
+	RouteCommandFile = "file"
+)
+
+// RouterConfig chooses a model for each request from rules, when no model is given
+type RouterConfig struct {
+	// Default is used when no rule matches, the default model when empty
+	Default string      `yaml:"default,omitempty"`
+	Rules   []RouteRule `yaml:"rules"`
+}
+
+// RouteRule selects a model for requests matching all of its conditions.
+// Conditions left empty always match.
+type RouteRule struct {
+	Name  string `yaml:"name,omitempty"`
+	Model string `yaml:"model"`
+	// Command is root or file
+	Command string `yaml:"command,omitempty"`
+	// MinLength and MaxLength bound the prompt length in characters, file content included
+	MinLength int `yaml:"min_length,omitempty"`
+	MaxLength int `yaml:"max_length,omitempty"`
+	// MinFiles is the minimum number of attached files
+	MinFiles int `yaml:"min_files,omitempty"`
+	// Languages match the language of an attached file, as detected from its extension
+	Languages []string `yaml:"languages,omitempty"`
+	// Keywords match when the question contains one of them, ignoring case
+	Keywords []string `yaml:"keywords,omitempty"`
+}
+
+// RouteRequest describes a request to route
+type RouteRequest struct {
+	Command  string
+	Question string
+	// Files are the names of the attached files
+	Files []string
+	// Length is the prompt length in characters, file content included
+	Length int
+}
+
+// RouteDecision is the model chosen for a request and why
+type RouteDecision struct {
+	Model Model
+	// Rule is the name of the matching rule, empty when the default was used
+	Rule   string
+	Reason string
+}
+
+// QuestionRequest describes a question asked directly
+func QuestionRequest(question string) RouteRequest {
+	return RouteRequest{Command: RouteCommandRoot, Question: question, Length: utf8.RuneCountInString(question)}
+}
+
+// FileRequest describes a question about a file
+func FileRequest(question, fileName, fileContent string) RouteRequest {
+	return RouteRequest{
+		Command:  RouteCommandFile,
+		Question: question,
+		Files:    []string{fileName},
+		Length:   utf8.RuneCountInString(question) + utf8.RuneCountInString(fileContent),
+	}
+}
+
+// Route is a rule with the model it selects
+type Route struct {
+	Rule  RouteRule
+	Model Model
+}
+
+// Router is a Model that forwards each request to the model of the first matching rule
+type Router struct {
+	routes       []Route
+	defaultModel Model
+}
+
+// NewRouter creates a router trying routes in order, then using defaultModel
+func NewRouter(defaultModel Model, routes ...Route) *Router {
+	return &Router{routes: routes, defaultModel: defaultModel}
+}
+
+// Name returns the name of the router
+func (r *Router) Name() string {
+	return "router"
+}
+
+// Route chooses the model for a request
+func (r *Router) Route(request RouteRequest) RouteDecision {
+	for i, route := range r.routes {
+		reasons, ok := route.Rule.match(request)
+		if !ok {
+			continue
+		}
+
+		name := route.Rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+		reason := "matches every request"
+		if len(reasons) > 0 {
+			reason = strings.Join(reasons, ", ")
+		}
+		return RouteDecision{Model: route.Model, Rule: name, Reason: reason}
+	}
+
+	return RouteDecision{Model: r.defaultModel, Reason: "no rule matched"}
+}
+
+// Chat routes a question and asks the chosen model
+func (r *Router) Chat(ctx context.Context, question string, options ...ChatOption) (string, error) {
+	return r.Route(QuestionRequest(question)).Model.Chat(ctx, question, options...)
+}
+
+// ChatWithFile routes a question about a file and asks the chosen model
+func (r *Router) ChatWithFile(ctx context.Context, question string, fileName string, fileContent string, options ...ChatOption) (string, error) {
+	return r.Route(FileRequest(question, fileName, fileContent)).Model.ChatWithFile(ctx, question, fileName, fileContent, options...)
+}
+
+// match reports whether a request meets every condition of the rule, and describes the conditions met
+func (rule RouteRule) match(request RouteRequest) ([]string, bool) {
+	var reasons []string
+
+	if rule.Command != "" {
+		if !strings.EqualFold(rule.Command, request.Command) {
+			return nil, false
+		}
+		reasons = append(reasons, "command "+request.Command)
+	}
+	if rule.MinLength > 0 {
+		if request.Length < rule.MinLength {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("prompt length %d ≥ %d", request.Length, rule.MinLength))
+	}
+	if rule.MaxLength > 0 {
+		if request.Length > rule.MaxLength {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("prompt length %d ≤ %d", request.Length, rule.MaxLength))
+	}
+	if rule.MinFiles > 0 {
+		if len(request.Files) < rule.MinFiles {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("%d files ≥ %d", len(request.Files), rule.MinFiles))
+	}
+	if len(rule.Languages) > 0 {
+		language, ok := matchLanguage(rule.Languages, request.Files)
+		if !ok {
+			return nil, false
+		}
+		reasons = append(reasons, "language "+language)
+	}
+	if len(rule.Keywords) > 0 {
+		keyword, ok := matchKeyword(rule.Keywords, request.Question)
+		if !ok {
+			return nil, false
+		}
+		reasons = append(reasons, fmt.Sprintf("keyword %q", keyword))
+	}
+
+	return reasons, true
+}
+
+// matchLanguage returns the first language of the files that is listed
+func matchLanguage(languages []string, files []string) (string, bool) {
+	for _, file := range files {
+		language := util.DetectLanguage(file)
+		for _, want := range languages {
+			if strings.EqualFold(want, language) {
+				return language, true
+			}
+		}
+	}
+	return "", false
+}
+
+// matchKeyword returns the first keyword found in the question, ignoring case
+func matchKeyword(keywords []string, question string) (string, bool) {
+	question = strings.ToLower(question)
+	for _, keyword := range keywords {
+		if keyword != "" && strings.Contains(question, strings.ToLower(keyword)) {
+			return keyword, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	cheap := &echoModel{}
+	strong := &chunkModel{name: "strong", answer: "strong answer"}
+	reviewer := &chunkModel{name: "reviewer", answer: "reviewed"}
+	fallback := &chunkModel{name: "fallback", answer: "fallback answer"}
+
+	router := NewRouter(fallback,
+		Route{Rule: RouteRule{Name: "review", Model: "reviewer", Command: RouteCommandFile, Keywords: []string{"Review"}}, Model: reviewer},
+		Route{Rule: RouteRule{Name: "long code", Model: "strong", MinLength: 40, Languages: []string{"go", "rust"}}, Model: strong},
+		Route{Rule: RouteRule{Model: "cheap", MaxLength: 20}, Model: cheap},
+	)
+
+	tests := []struct {
+		name    string
+		request RouteRequest
+		model   string
+		rule    string
+		reason  string
+	}{
+		{"short question", QuestionRequest("What is Go?"), "echo", "rule 3", "prompt length 11 ≤ 20"},
+		{"long question", QuestionRequest(strings.Repeat("why ", 10)), "fallback", "", "no rule matched"},
+		{"review", FileRequest("please review this", "main.go", "package main"), "reviewer", "review", `command file, keyword "Review"`},
+		{"long go file", FileRequest("explain", "main.go", strings.Repeat("x", 40)), "strong", "long code", "prompt length 47 ≥ 40, language Go"},
+		{"long python file", FileRequest("explain", "main.py", strings.Repeat("x", 40)), "fallback", "", "no rule matched"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := router.Route(tt.request)
+			if decision.Model.Name() != tt.model || decision.Rule != tt.rule || decision.Reason != tt.reason {
+				t.Errorf("Expected %s by %q (%s), got %s by %q (%s)", tt.model, tt.rule, tt.reason, decision.Model.Name(), decision.Rule, decision.Reason)
+			}
+		})
+	}
+
+	// The router forwards the request to the chosen model
+	answer, err := router.Chat(context.Background(), "hi")
+	if err != nil || answer != "hi" {
+		t.Errorf("Expected the cheap model to answer, got %q, %v", answer, err)
+	}
+	answer, err = router.ChatWithFile(context.Background(), "review it", "a.go", "package a", WithStreamHandler(func(string) {}), WithResponseInfo(&ResponseInfo{}))
+	if err != nil || answer != "reviewed" {
+		t.Errorf("Expected the reviewer to answer, got %q, %v", answer, err)
+	}
+}
+
+func TestModelManager_Router(t *testing.T) {
+	m := newTestManager(t, "")
+	if router, err := m.Router(); router != nil || err != nil {
+		t.Errorf("Expected no router without rules, got %v, %v", router, err)
+	}
+
+	m = newTestManager(t, `version: 2
+defaults:
+  model: mini
+models:
+  mini:
+    url: https://a
+  big:
+    url: https://b
+    fallbacks: [mini]
+router:
+  rules:
+    - name: files
+      model: big
+      command: file
+`)
+
+	router, err := m.Router()
+	if err != nil || router == nil {
+		t.Fatalf("Expected a router, got %v, %v", router, err)
+	}
+	if decision := router.Route(QuestionRequest("hi")); decision.Model.Name() != "mini" {
+		t.Errorf("Expected the default model without a matching rule, got %s", decision.Model.Name())
+	}
+	decision := router.Route(FileRequest("hi", "a.go", ""))
+	if _, ok := decision.Model.(*FallbackModel); !ok || decision.Model.Name() != "big" {
+		t.Errorf("Expected big with its fallbacks, got %T %s", decision.Model, decision.Model.Name())
+	}
+
+	if err := m.RemoveModel("big"); !errors.Is(err, ErrModelRouted) {
+		t.Errorf("Expected ErrModelRouted, got %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a JSON schema used to check replies locally. It supports the keywords
+// structured output relies on: type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength, pattern,
+// minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf, anyOf and oneOf.
+// Other keywords are ignored.
+type Schema struct {
+	raw  json.RawMessage
+	node *schemaNode
+}
+
+// schemaNode is a parsed schema or subschema
+type schemaNode struct {
+	Type                 schemaTypes            `json:"type"`
+	Enum                 []any                  `json:"enum"`
+	Const                *any                   `json:"const"`
+	Properties           map[string]*schemaNode `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *schemaNode            `json:"-"`
+	NoAdditional         bool                   `json:"-"`
+	Items                *schemaNode            `json:"items"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	ExclusiveMinimum     *float64               `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64               `json:"exclusiveMaximum"`
+	AllOf                []*schemaNode          `json:"allOf"`
+	AnyOf                []*schemaNode          `json:"anyOf"`
+	OneOf                []*schemaNode          `json:"oneOf"`
+
+	pattern *regexp.Regexp
+}
+
+// schemaTypes is the type keyword, a single type or a list of types
+type schemaTypes []string
+
+// UnmarshalJSON reads a type given as a string or a list of strings
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("type must be a string or a list of strings")
+	}
+	*t = list
+	return nil
+}
+
+// UnmarshalJSON reads a schema, additionalProperties being a boolean or a schema
+func (n *schemaNode) UnmarshalJSON(data []byte) error {
+	type plain schemaNode
+	var fields struct {
+		plain
+		AdditionalProperties json.RawMessage `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*n = schemaNode(fields.plain)
+
+	switch additional := bytes.TrimSpace(fields.AdditionalProperties); {
+	case len(additional) == 0, string(additional) == "true":
+	case string(additional) == "false":
+		n.NoAdditional = true
+	default:
+		n.AdditionalProperties = &schemaNode{}
+		if err := json.Unmarshal(additional, n.AdditionalProperties); err != nil {
+			return fmt.Errorf("additionalProperties: %w", err)
+		}
+	}
+
+	if n.Pattern != "" {
+		pattern, err := regexp.Compile(n.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", n.Pattern, err)
+		}
+		n.pattern = pattern
+	}
+	return nil
+}
+
+// ParseSchema reads a JSON schema
+func ParseSchema(data []byte) (*Schema, error) {
+	node := &schemaNode{}
+	if err := json.Unmarshal(data, node); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %w", err)
+	}
+	return &Schema{raw: compact.Bytes(), node: node}, nil
+}
+
+// Raw returns the schema as JSON
+func (s *Schema) Raw() json.RawMessage {
+	return s.raw
+}
+
+// Validate checks a JSON document against the schema and returns the problems found,
+// each starting with the JSON path of the value, such as $.items[0].name
+func (s *Schema) Validate(document []byte) []string {
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(document))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return []string{"invalid JSON: " + err.Error()}
+	}
+
+	var problems []string
+	s.node.validate("$", value, &problems)
+	return problems
+}
+
+// validate adds the problems of value at path to problems
+func (n *schemaNode) validate(path string, value any, problems *[]string) {
+	report := func(format string, args ...any) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(n.Type) > 0 && !n.Type.matches(value) {
+		report("expected %s, got %s", strings.Join(n.Type, " or "), jsonType(value))
+		return
+	}
+	if len(n.Enum) > 0 && !containsJSON(n.Enum, value) {
+		report("must be one of %s", formatJSONList(n.Enum))
+	}
+	if n.Const != nil && !equalJSON(*n.Const, value) {
+		report("must be %s", formatJSON(*n.Const))
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		for _, name := range n.Required {
+			if _, ok := value[name]; !ok {
+				report("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := path + "." + name
+			if property, ok := n.Properties[name]; ok {
+				property.validate(child, value[name], problems)
+			} else if n.NoAdditional {
+				report("unexpected property %q", name)
+			} else if n.AdditionalProperties != nil {
+				n.AdditionalProperties.validate(child, value[name], problems)
+			}
+		}
+	case []any:
+		if n.MinItems != nil && len(value) < *n.MinItems {
+			report("expected at least %d items, got %d", *n.MinItems, len(value))
+		}
+		if n.MaxItems != nil && len(value) > *n.MaxItems {
+			report("expected at most %d items, got %d", *n.MaxItems, len(value))
+		}
+		if n.Items != nil {
+			for i, item := range value {
+				n.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(value)
+		if n.MinLength != nil && length < *n.MinLength {
+			report("expected at least %d characters, got %d", *n.MinLength, length)
+		}
+		if n.MaxLength != nil && length > *n.MaxLength {
+			report("expected at most %d characters, got %d", *n.MaxLength, length)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(value) {
+			report("does not match the pattern %q", n.Pattern)
+		}
+	case json.Number:
+		number, _ := value.Float64()
+		if n.Minimum != nil && number < *n.Minimum {
+			report("must be at least %v", *n.Minimum)
+		}
+		if n.Maximum != nil && number > *n.Maximum {
+			report("must be at most %v", *n.Maximum)
+		}
+		if n.ExclusiveMinimum != nil && number <= *n.ExclusiveMinimum {
+			report("must be greater than %v", *n.ExclusiveMinimum)
+		}
+		if n.ExclusiveMaximum != nil && number >= *n.ExclusiveMaximum {
+			report("must be less than %v", *n.ExclusiveMaximum)
+		}
+	}
+
+	for _, sub := range n.AllOf {
+		sub.validate(path, value, problems)
+	}
+	if len(n.AnyOf) > 0 && n.countMatches(n.AnyOf, path, value) == 0 {
+		report("does not match any of the allowed schemas")
+	}
+	if len(n.OneOf) > 0 {
+		if matches := n.countMatches(n.OneOf, path, value); matches != 1 {
+			report("must match exactly one of the allowed schemas, matches %d", matches)
+		}
+	}
+}
+
+// countMatches returns how many of the schemas value matches
+func (n *schemaNode) countMatches(schemas []*schemaNode, path string, value any) int {
+	matches := 0
+	for _, sub := range schemas {
+		var problems []string
+		sub.validate(path, value, &problems)
+		if len(problems) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+// matches reports whether value has one of the types
+func (t schemaTypes) matches(value any) bool {
+	actual := jsonType(value)
+	for _, want := range t {
+		if want == actual || (want == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON schema type of a decoded value
+func jsonType(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if number, err := value.Float64(); err == nil && number == math.Trunc(number) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// containsJSON reports whether values contains value
+func containsJSON(values []any, value any) bool {
+	for _, candidate := range values {
+		if equalJSON(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// equalJSON compares two decoded values by their JSON encoding, numbers by value
+func equalJSON(a, b any) bool {
+	if a, ok := a.(float64); ok {
+		if b, ok := b.(json.Number); ok {
+			number, err := b.Float64()
+			return err == nil && number == a
+		}
+	}
+	return formatJSON(a) == formatJSON(b)
+}
+
+// formatJSON encodes a value for a message
+func formatJSON(value any) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// formatJSONList encodes values for a message
+func formatJSONList(values []any) string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = formatJSON(value)
+	}
+	return strings.Join(formatted, ", ")
+}
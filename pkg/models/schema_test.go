@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchema_Validate(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{
+		"type": "object",
+		"required": ["name", "tags"],
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string", "minLength": 1, "pattern": "^[a-z]+$"},
+			"age": {"type": "integer", "minimum": 0},
+			"tags": {"type": "array", "maxItems": 2, "items": {"enum": ["a", "b"]}},
+			"id": {"anyOf": [{"type": "string"}, {"type": "integer"}]}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+
+	if problems := schema.Validate([]byte(`{"name": "bob", "age": 3, "tags": ["a"], "id": 7}`)); len(problems) != 0 {
+		t.Errorf("Expected a valid document, got %v", problems)
+	}
+
+	tests := map[string]string{
+		`{"tags": []}`:                             `$: missing required property "name"`,
+		`{"name": "Bob", "tags": []}`:              `$.name: does not match the pattern`,
+		`{"name": "bob", "tags": "a"}`:             `$.tags: expected array, got string`,
+		`{"name": "bob", "tags": ["a", "c"]}`:      `$.tags[1]: must be one of "a", "b"`,
+		`{"name": "bob", "tags": [], "x": 1}`:      `$: unexpected property "x"`,
+		`{"name": "bob", "tags": [], "age": 1.5}`:  `$.age: expected integer, got number`,
+		`{"name": "bob", "tags": [], "id": true}`:  `$.id: does not match any of the allowed schemas`,
+		`{"name": "bob", "tags": ["a", "b", "a"]}`: `$.tags: expected at most 2 items, got 3`,
+		`{"name": `: `invalid JSON`,
+	}
+	for document, want := range tests {
+		problems := schema.Validate([]byte(document))
+		if len(problems) != 1 || !strings.HasPrefix(problems[0], want) {
+			t.Errorf("Validate(%s) = %v, want %s", document, problems, want)
+		}
+	}
+
+	if _, err := ParseSchema([]byte(`{"type": 1}`)); err == nil {
+		t.Error("Expected an error for an invalid type")
+	}
+	if _, err := ParseSchema([]byte(`{"pattern": "("}`)); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
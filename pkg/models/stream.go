@@ -0,0 +1,68 @@
+package models
+
+import (
+	"context"
+	"sync"
+)
+
+// StreamEvent is a part of the answer of one of the models asked by StreamAll
+type StreamEvent struct {
+	// Index is the position of the model in the list given to StreamAll
+	Index int
+	Model string
+	// Chunk is the text received, it is empty in the final event
+	Chunk string
+
+	// Done marks the final event of a model, which carries the whole answer,
+	// the details of the response and the error if the request failed
+	Done   bool
+	Answer string
+	Info   *ResponseInfo
+	Err    error
+}
+
+// ChatStream asks a model a question and sends the streamed answer as events on
+// the returned channel, which is closed after the final event
+func ChatStream(ctx context.Context, model Model, question string, options ...ChatOption) <-chan StreamEvent {
+	return StreamAll(ctx, []Model{model}, question, options...)
+}
+
+// StreamAll asks several models the same question in parallel and sends their
+// streamed answers as events on the returned channel. Events of one model arrive
+// in order; the channel is closed once every model has sent its final event.
+func StreamAll(ctx context.Context, targets []Model, question string, options ...ChatOption) <-chan StreamEvent {
+	events := make(chan StreamEvent, 64)
+
+	var wg sync.WaitGroup
+	for i, model := range targets {
+		wg.Add(1)
+		go func(index int, model Model) {
+			defer wg.Done()
+			events <- streamModel(ctx, index, model, question, options, events)
+		}(i, model)
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return events
+}
+
+// streamModel asks one model, sending its chunks to events, and returns the final event
+func streamModel(ctx context.Context, index int, model Model, question string, options []ChatOption, events chan<- StreamEvent) StreamEvent {
+	name := model.Name()
+	info := &ResponseInfo{}
+
+	options = append(options[:len(options):len(options)],
+		WithStream(true),
+		WithResponseInfo(info),
+		WithStreamHandler(func(chunk string) {
+			events <- StreamEvent{Index: index, Model: name, Chunk: chunk}
+		}),
+	)
+
+	answer, err := model.Chat(ctx, question, options...)
+	return StreamEvent{Index: index, Model: name, Done: true, Answer: answer, Info: info, Err: err}
+}
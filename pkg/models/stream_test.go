@@ -0,0 +1,71 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// chunkModel streams its answer word by word through the stream handler
+type chunkModel struct {
+	name   string
+	answer string
+	err    error
+}
+
+func (m *chunkModel) Name() string { return m.name }
+
+func (m *chunkModel) Chat(ctx context.Context, question string, options ...ChatOption) (string, error) {
+	opts := &ChatOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+	if m.err != nil {
+		return "", m.err
+	}
+	for _, word := range strings.SplitAfter(m.answer, " ") {
+		opts.StreamHandler(word)
+	}
+	opts.ResponseInfo.Model = m.name
+	return m.answer, nil
+}
+
+func (m *chunkModel) ChatWithFile(ctx context.Context, question string, fileName string, fileContent string, options ...ChatOption) (string, error) {
+	return m.Chat(ctx, question, options...)
+}
+
+func TestStreamAll(t *testing.T) {
+	failure := errors.New("unavailable")
+	targets := []Model{
+		&chunkModel{name: "a", answer: "one two three"},
+		&chunkModel{name: "b", answer: "four five"},
+		&chunkModel{name: "c", err: failure},
+	}
+
+	streamed := make([]strings.Builder, len(targets))
+	finals := make([]StreamEvent, len(targets))
+	for event := range StreamAll(context.Background(), targets, "q") {
+		if event.Done {
+			finals[event.Index] = event
+			continue
+		}
+		if finals[event.Index].Done {
+			t.Errorf("Received a chunk of %s after its final event", event.Model)
+		}
+		streamed[event.Index].WriteString(event.Chunk)
+	}
+
+	for i, target := range targets[:2] {
+		want := target.(*chunkModel).answer
+		if streamed[i].String() != want || finals[i].Answer != want {
+			t.Errorf("%s: streamed %q, final %q, want %q", target.Name(), streamed[i].String(), finals[i].Answer, want)
+		}
+		if finals[i].Info == nil || finals[i].Info.Model != target.Name() {
+			t.Errorf("%s: expected the response details in the final event, got %+v", target.Name(), finals[i].Info)
+		}
+	}
+	if !finals[2].Done || !errors.Is(finals[2].Err, failure) {
+		t.Errorf("Expected a final event with the error, got %+v", finals[2])
+	}
+}
@@ -0,0 +1,160 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultJSONRetries is how many times a reply that is not valid is asked again
+const DefaultJSONRetries = 2
+
+// ErrInvalidJSON is returned when the model keeps replying with JSON that is not valid
+var ErrInvalidJSON = errors.New("the model did not reply with valid JSON")
+
+// ResponseFormat is the response_format of an OpenAI-compatible request
+type ResponseFormat struct {
+	// Type is json_object for any JSON object, or json_schema
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat is the schema a json_schema reply must match
+type JSONSchemaFormat struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict,omitempty"`
+}
+
+// invalidSchemaName matches the characters not allowed in a schema name
+var invalidSchemaName = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// JSONOutput asks models for a JSON reply, checks it against an optional schema
+// and asks again with the problems found
+type JSONOutput struct {
+	name    string
+	schema  *Schema
+	retries int
+}
+
+// NewJSONOutput creates a JSONOutput for replies matching schema, or any JSON
+// object when schema is nil. The name identifies the schema to the provider.
+func NewJSONOutput(name string, schema *Schema) *JSONOutput {
+	name = strings.Trim(invalidSchemaName.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		name = "response"
+	}
+	return &JSONOutput{name: name, schema: schema, retries: DefaultJSONRetries}
+}
+
+// SetRetries sets how many times a reply that is not valid is asked again
+func (o *JSONOutput) SetRetries(retries int) {
+	o.retries = max(retries, 0)
+}
+
+// Format returns the response format sent to the provider
+func (o *JSONOutput) Format() *ResponseFormat {
+	if o.schema == nil {
+		return &ResponseFormat{Type: "json_object"}
+	}
+	return &ResponseFormat{Type: "json_schema", JSONSchema: &JSONSchemaFormat{Name: o.name, Schema: o.schema.Raw()}}
+}
+
+// Chat asks the model a question and returns the JSON of its reply once valid.
+// The ResponseInfo of the options describes the last reply, with the usage of every attempt.
+func (o *JSONOutput) Chat(ctx context.Context, model Model, question string, options ...ChatOption) (string, error) {
+	return o.ask(ctx, model, question, func(options []ChatOption) (string, error) {
+		return model.Chat(ctx, question, options...)
+	}, options)
+}
+
+// ChatWithFile asks the model a question about a file and returns the JSON of its reply once valid
+func (o *JSONOutput) ChatWithFile(ctx context.Context, model Model, question, fileName, fileContent string, options ...ChatOption) (string, error) {
+	return o.ask(ctx, model, filePrompt(question, fileName, fileContent), func(options []ChatOption) (string, error) {
+		return model.ChatWithFile(ctx, question, fileName, fileContent, options...)
+	}, options)
+}
+
+// ask sends the first request with first, then continues the conversation from
+// prompt, the question as the model received it, until the reply is valid
+func (o *JSONOutput) ask(ctx context.Context, model Model, prompt string, first func([]ChatOption) (string, error), options []ChatOption) (string, error) {
+	opts := &ChatOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	systemPrompt := o.instructions()
+	if opts.SystemPrompt != "" {
+		systemPrompt = opts.SystemPrompt + "\n\n" + systemPrompt
+	}
+	conversation := append([]Message(nil), opts.History...)
+	var usage Usage
+
+	for attempt := 0; ; attempt++ {
+		info := &ResponseInfo{}
+		attemptOptions := append(options[:len(options):len(options)],
+			WithSystemPrompt(systemPrompt),
+			WithResponseFormat(o.Format()),
+			WithStream(false),
+			WithResponseInfo(info),
+		)
+
+		var answer string
+		var err error
+		if attempt == 0 {
+			answer, err = first(attemptOptions)
+		} else {
+			answer, err = model.Chat(ctx, prompt, append(attemptOptions, WithHistory(conversation))...)
+		}
+		usage.PromptTokens += info.Usage.PromptTokens
+		usage.CompletionTokens += info.Usage.CompletionTokens
+		usage.TotalTokens += info.Usage.TotalTokens
+		if opts.ResponseInfo != nil {
+			*opts.ResponseInfo = *info
+			opts.ResponseInfo.Usage = usage
+		}
+		if err != nil {
+			return "", err
+		}
+
+		document, problems := o.check(answer)
+		if len(problems) == 0 {
+			return document, nil
+		}
+		if attempt >= o.retries {
+			return "", fmt.Errorf("%w after %d attempt(s): %s", ErrInvalidJSON, attempt+1, strings.Join(problems, "; "))
+		}
+
+		conversation = append(conversation,
+			Message{Role: "user", Content: prompt},
+			Message{Role: "assistant", Content: answer},
+		)
+		prompt = fmt.Sprintf("Your reply is not valid:\n- %s\n\nReply again with only the corrected JSON.", strings.Join(problems, "\n- "))
+	}
+}
+
+// instructions tells the model to reply with JSON, which json_object requires
+func (o *JSONOutput) instructions() string {
+	if o.schema == nil {
+		return "Reply with a single JSON object only, without any other text or code fences."
+	}
+	return "Reply with a single JSON value only, without any other text or code fences. It must match this JSON schema:\n" + string(o.schema.Raw())
+}
+
+// check extracts the JSON of a reply and returns the problems that make it not valid
+func (o *JSONOutput) check(answer string) (string, []string) {
+	document := extractJSON(answer)
+	if !json.Valid([]byte(document)) {
+		return document, []string{"the reply is not a JSON document"}
+	}
+	if o.schema == nil {
+		if !strings.HasPrefix(document, "{") {
+			return document, []string{"the reply must be a JSON object"}
+		}
+		return document, nil
+	}
+	return document, o.schema.Validate([]byte(document))
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONOutput_Chat(t *testing.T) {
+	replies := []string{
+		"Sure! Here is the result:\n```json\n{\"answer\": 42}\n```",
+		`{"answer": "42"}`,
+	}
+	var requests []OpenAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OpenAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requests = append(requests, request)
+
+		reply := replies[min(len(requests), len(replies))-1]
+		json.NewEncoder(w).Encode(OpenAIResponse{
+			Choices: []Choice{{Message: Message{Role: "assistant", Content: reply}}},
+			Usage:   Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		})
+	}))
+	defer server.Close()
+
+	schema, err := ParseSchema([]byte(`{"type": "object", "required": ["answer"], "properties": {"answer": {"type": "string"}}}`))
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+	model := NewOpenAIModel(&ModelConfig{Name: "test-openai", URL: server.URL})
+
+	info := &ResponseInfo{}
+	output := NewJSONOutput("answer.schema", schema)
+	document, err := output.Chat(context.Background(), model, "What is the answer?", WithResponseInfo(info))
+	if err != nil || document != `{"answer": "42"}` {
+		t.Fatalf("Expected the corrected JSON, got %q, %v", document, err)
+	}
+	if len(requests) != 2 || info.Usage.TotalTokens != 30 {
+		t.Fatalf("Expected 2 requests with their usage summed, got %d and %+v", len(requests), info.Usage)
+	}
+
+	format := requests[0].ResponseFormat
+	if format == nil || format.Type != "json_schema" || format.JSONSchema.Name != "answer_schema" || string(format.JSONSchema.Schema) != string(schema.Raw()) {
+		t.Errorf("Expected the schema as the response format, got %+v", format)
+	}
+	if requests[0].Stream || !strings.Contains(requests[0].Messages[0].Content, "JSON schema") {
+		t.Errorf("Expected a non-streamed request asking for JSON, got %+v", requests[0])
+	}
+
+	// The retry continues the conversation with the problems found
+	retry := requests[1].Messages
+	if len(retry) != 4 || retry[2].Content != replies[0] || !strings.Contains(retry[3].Content, "$.answer: expected string, got integer") {
+		t.Errorf("Expected the validation errors to be sent back, got %+v", retry)
+	}
+
+	// Replies that stay invalid give up after the retries
+	requests = nil
+	replies = []string{"no JSON here"}
+	output = NewJSONOutput("", nil)
+	output.SetRetries(1)
+	if _, err := output.ChatWithFile(context.Background(), model, "Summarize", "notes.txt", "hello"); !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("Expected ErrInvalidJSON, got %v", err)
+	}
+	if len(requests) != 2 || requests[0].ResponseFormat.Type != "json_object" {
+		t.Errorf("Expected 2 json_object requests, got %d", len(requests))
+	}
+	if !strings.Contains(requests[1].Messages[1].Content, "file content:\nhello") {
+		t.Errorf("Expected the retry to include the file, got %+v", requests[1].Messages)
+	}
+}
@@ -0,0 +1,245 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DefaultMaxToolSteps is the number of tool call rounds ToolRegistry.Run allows by default
+const DefaultMaxToolSteps = 10
+
+// maxToolCalls bounds the tool calls of a streamed response
+const maxToolCalls = 128
+
+// Errors returned by tool calling
+var (
+	ErrToolsNotSupported = errors.New("the model API doesn't support tool calling")
+	ErrToolStepLimit     = errors.New("too many tool call steps")
+	ErrInvalidToolCall   = errors.New("invalid tool call index in the response")
+)
+
+// Tool is a function offered to the model, in the OpenAI format
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction describes a function the model may call
+type ToolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Parameters is the JSON schema of the arguments
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is a call to a function asked by the model
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction is the function of a tool call with its JSON encoded arguments
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolFunc runs a tool with the JSON arguments given by the model and returns its output
+type ToolFunc func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// ToolResult is a tool call run by ToolRegistry.Run
+type ToolResult struct {
+	Call   ToolCall
+	Output string
+	// Err is the error of the tool, its message was given to the model as output
+	Err error
+}
+
+// ToolRegistry holds the tools offered to a model and runs the calls it asks for
+type ToolRegistry struct {
+	tools    map[string]registeredTool
+	maxSteps int
+}
+
+// registeredTool is a tool with the function running it
+type registeredTool struct {
+	tool Tool
+	fn   ToolFunc
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools:    make(map[string]registeredTool),
+		maxSteps: DefaultMaxToolSteps,
+	}
+}
+
+// SetMaxSteps sets the number of tool call rounds allowed before Run gives up
+func (r *ToolRegistry) SetMaxSteps(steps int) {
+	r.maxSteps = steps
+}
+
+// Register adds a tool. parameters is the JSON schema of its arguments, nil for none.
+func (r *ToolRegistry) Register(name, description string, parameters json.RawMessage, fn ToolFunc) error {
+	if name == "" {
+		return errors.New("tool name is required")
+	}
+	if _, exists := r.tools[name]; exists {
+		return fmt.Errorf("tool %s already registered", name)
+	}
+	if parameters == nil {
+		parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	} else if !json.Valid(parameters) {
+		return fmt.Errorf("invalid parameters schema for tool %s", name)
+	}
+
+	r.tools[name] = registeredTool{
+		tool: Tool{
+			Type:     "function",
+			Function: ToolFunction{Name: name, Description: description, Parameters: parameters},
+		},
+		fn: fn,
+	}
+	return nil
+}
+
+// Tools returns the registered tools sorted by name
+func (r *ToolRegistry) Tools() []Tool {
+	tools := make([]Tool, 0, len(r.tools))
+	for _, registered := range r.tools {
+		tools = append(tools, registered.tool)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].Function.Name < tools[j].Function.Name
+	})
+	return tools
+}
+
+// Run asks the model a question with the registered tools, runs the calls it asks
+// for and sends back their output until it answers. Tool errors are given to the
+// model as output. The ResponseInfo of the options describes the last step, with
+// the usage of every step and the calls run in ToolResults.
+func (r *ToolRegistry) Run(ctx context.Context, model Model, question string, options ...ChatOption) (string, error) {
+	opts := &ChatOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+
+	conversation := append([]Message(nil), opts.History...)
+	var usage Usage
+	var results []ToolResult
+
+	for step := 0; ; step++ {
+		info := &ResponseInfo{}
+		stepOptions := append(options[:len(options):len(options)], WithTools(r.Tools()...), WithResponseInfo(info))
+		prompt := question
+		if step > 0 {
+			// The question is already in the conversation
+			prompt = ""
+			stepOptions = append(stepOptions, WithHistory(conversation))
+		}
+
+		answer, err := model.Chat(ctx, prompt, stepOptions...)
+		usage.PromptTokens += info.Usage.PromptTokens
+		usage.CompletionTokens += info.Usage.CompletionTokens
+		usage.TotalTokens += info.Usage.TotalTokens
+		if opts.ResponseInfo != nil {
+			*opts.ResponseInfo = *info
+			opts.ResponseInfo.Usage = usage
+			opts.ResponseInfo.ToolResults = results
+		}
+		if err != nil || len(info.ToolCalls) == 0 {
+			return answer, err
+		}
+		if step >= r.maxSteps {
+			return answer, fmt.Errorf("%w: stopped after %d", ErrToolStepLimit, r.maxSteps)
+		}
+
+		if step == 0 {
+			conversation = append(conversation, Message{Role: "user", Content: question})
+		}
+		conversation = append(conversation, Message{Role: "assistant", Content: answer, ToolCalls: info.ToolCalls})
+		for _, call := range info.ToolCalls {
+			result := r.call(ctx, call)
+			results = append(results, result)
+			conversation = append(conversation, Message{Role: "tool", Content: result.Output, ToolCallID: call.ID})
+		}
+		if opts.ResponseInfo != nil {
+			opts.ResponseInfo.ToolResults = results
+		}
+	}
+}
+
+// call runs a tool call, describing failures in the output for the model
+func (r *ToolRegistry) call(ctx context.Context, call ToolCall) ToolResult {
+	result := ToolResult{Call: call}
+
+	registered, ok := r.tools[call.Function.Name]
+	if !ok {
+		result.Err = fmt.Errorf("unknown tool %s", call.Function.Name)
+	} else {
+		arguments := json.RawMessage(call.Function.Arguments)
+		if len(arguments) == 0 {
+			arguments = json.RawMessage("{}")
+		}
+		if !json.Valid(arguments) {
+			result.Err = fmt.Errorf("invalid JSON arguments for tool %s", call.Function.Name)
+		} else {
+			result.Output, result.Err = registered.fn(ctx, arguments)
+		}
+	}
+
+	if result.Err != nil {
+		result.Output = "error: " + result.Err.Error()
+	}
+	return result
+}
+
+// toolCallDelta is a part of a tool call in a streamed response
+type toolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments,omitempty"`
+	} `json:"function"`
+}
+
+// toolCallBuilder assembles the tool calls of a streamed response from their parts
+type toolCallBuilder struct {
+	parts []ToolCall
+}
+
+// add merges parts of tool calls, the arguments arrive in pieces
+func (b *toolCallBuilder) add(deltas []toolCallDelta) error {
+	for _, delta := range deltas {
+		// The index comes from the server, don't trust it to size the calls
+		if delta.Index < 0 || delta.Index >= maxToolCalls {
+			return fmt.Errorf("%w: %d", ErrInvalidToolCall, delta.Index)
+		}
+		for len(b.parts) <= delta.Index {
+			b.parts = append(b.parts, ToolCall{Type: "function"})
+		}
+		call := &b.parts[delta.Index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Type != "" {
+			call.Type = delta.Type
+		}
+		call.Function.Name += delta.Function.Name
+		call.Function.Arguments += delta.Function.Arguments
+	}
+	return nil
+}
+
+// calls returns the assembled tool calls
+func (b *toolCallBuilder) calls() []ToolCall {
+	return b.parts
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestToolRegistry_Run(t *testing.T) {
+	var requests []OpenAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request OpenAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		requests = append(requests, request)
+
+		message := Message{Role: "assistant", Content: "It is sunny in Paris."}
+		if len(requests) == 1 {
+			message = Message{Role: "assistant", ToolCalls: []ToolCall{
+				{ID: "call_1", Type: "function", Function: ToolCallFunction{Name: "weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "call_2", Type: "function", Function: ToolCallFunction{Name: "missing", Arguments: `{}`}},
+			}}
+		}
+		json.NewEncoder(w).Encode(OpenAIResponse{
+			Choices: []Choice{{Message: message}},
+			Usage:   Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		})
+	}))
+	defer server.Close()
+
+	registry := NewToolRegistry()
+	err := registry.Register("weather", "Get the weather of a city", json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`),
+		func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct{ City string }
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			return "sunny in " + args.City, nil
+		})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if err := registry.Register("weather", "", nil, nil); err == nil {
+		t.Error("Expected an error for a duplicate tool")
+	}
+
+	info := &ResponseInfo{}
+	model := NewOpenAIModel(&ModelConfig{Name: "test-openai", URL: server.URL})
+	answer, err := registry.Run(context.Background(), model, "What is the weather in Paris?", WithStream(false), WithResponseInfo(info))
+	if err != nil || answer != "It is sunny in Paris." {
+		t.Fatalf("Expected the final answer, got %q, %v", answer, err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+	if len(requests[0].Tools) != 1 || requests[0].Tools[0].Function.Name != "weather" {
+		t.Errorf("Expected the weather tool to be offered, got %+v", requests[0].Tools)
+	}
+
+	// The second request continues with the tool calls and their results
+	var roles []string
+	for _, message := range requests[1].Messages {
+		roles = append(roles, message.Role)
+	}
+	if got := strings.Join(roles, ","); got != "user,assistant,tool,tool" {
+		t.Fatalf("Expected the conversation to continue after the tool calls, got %s", got)
+	}
+	results := requests[1].Messages[2:]
+	if results[0].ToolCallID != "call_1" || results[0].Content != "sunny in Paris" {
+		t.Errorf("Unexpected tool result %+v", results[0])
+	}
+	if results[1].ToolCallID != "call_2" || !strings.HasPrefix(results[1].Content, "error: unknown tool") {
+		t.Errorf("Expected the unknown tool to be reported to the model, got %+v", results[1])
+	}
+
+	if info.Usage.TotalTokens != 30 || len(info.ToolResults) != 2 || info.ToolResults[1].Err == nil {
+		t.Errorf("Expected the usage and tool results of both steps, got %+v", info)
+	}
+}
+
+func TestToolRegistry_StepLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(OpenAIResponse{Choices: []Choice{{Message: Message{Role: "assistant", ToolCalls: []ToolCall{
+			{ID: "call", Type: "function", Function: ToolCallFunction{Name: "again"}},
+		}}}}})
+	}))
+	defer server.Close()
+
+	calls := 0
+	registry := NewToolRegistry()
+	registry.SetMaxSteps(2)
+	registry.Register("again", "", nil, func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		calls++
+		return "", fmt.Errorf("call %d", calls)
+	})
+
+	model := NewOpenAIModel(&ModelConfig{Name: "test-openai", URL: server.URL})
+	_, err := registry.Run(context.Background(), model, "loop", WithStream(false))
+	if !errors.Is(err, ErrToolStepLimit) || calls != 2 {
+		t.Errorf("Expected the step limit after 2 rounds, got %v after %d calls", err, calls)
+	}
+}
+
+func TestHandleStreamResponse_ToolCalls(t *testing.T) {
+	sseResponse := `data: {"choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"weather","arguments":""}}]},"finish_reason":null}]}
+
+data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]},"finish_reason":null}]}
+
+data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}},{"index":1,"id":"call_2","type":"function","function":{"name":"time","arguments":"{}"}}]},"finish_reason":null}]}
+
+data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: [DONE]
+`
+
+	client := NewOpenAIClient(ModelConfig{Name: "test-openai"})
+	info := &ResponseInfo{}
+	result, err := client.handleStreamResponse(strings.NewReader(sseResponse), func(string) {}, info)
+	if err != nil || result != "" {
+		t.Fatalf("Expected no text, got %q, %v", result, err)
+	}
+
+	if info.FinishReason != "tool_calls" || len(info.ToolCalls) != 2 {
+		t.Fatalf("Expected 2 tool calls, got %+v", info)
+	}
+	call := info.ToolCalls[0]
+	if call.ID != "call_1" || call.Function.Name != "weather" || call.Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("Expected the arguments to be assembled, got %+v", call)
+	}
+	if info.ToolCalls[1].Function.Name != "time" {
+		t.Errorf("Unexpected second call %+v", info.ToolCalls[1])
+	}
+
+	// Indexes out of range are refused instead of sizing the calls
+	for _, index := range []string{"-1", "1000000000"} {
+		chunk := `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":` + index + `,"function":{"name":"x"}}]}}]}` + "\n"
+		if _, err := client.handleStreamResponse(strings.NewReader(chunk), func(string) {}, &ResponseInfo{}); !errors.Is(err, ErrInvalidToolCall) {
+			t.Errorf("Expected ErrInvalidToolCall for index %s, got %v", index, err)
+		}
+	}
+}
+
+func TestModelsWithoutTools(t *testing.T) {
+	model := NewGeminiModel(&ModelConfig{Name: "gemini-pro"})
+	if _, err := model.Chat(context.Background(), "q", WithTools(Tool{Type: "function"})); !errors.Is(err, ErrToolsNotSupported) {
+		t.Errorf("Expected ErrToolsNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pokitpeng/ai/pkg/util"
+)
+
+// Entry records the token usage and cost of a single model call
+type Entry struct {
+	Time             time.Time `json:"time"`
+	Command          string    `json:"command"` // root, file, multi, edit or agent
+	Model            string    `json:"model"`
+	Provider         string    `json:"provider,omitempty"`
+	SessionID        string    `json:"session_id,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	// Cost is computed with the pricing in effect at the time of the call, in USD
+	Cost float64 `json:"cost"`
+}
+
+// Ledger is an append-only log of model calls, one JSON entry per line
+type Ledger struct {
+	path string
+}
+
+// NewLedger creates a ledger stored in the given file
+func NewLedger(path string) *Ledger {
+	return &Ledger{path: path}
+}
+
+// Path returns the file the ledger is stored in
+func (l *Ledger) Path() string {
+	return l.path
+}
+
+// Record appends an entry to the ledger
+func (l *Ledger) Record(entry Entry) error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// Serialize writers so lines from concurrent processes don't interleave
+	unlock, err := util.LockFile(l.path + ".lock")
+	if err == nil {
+		defer unlock()
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Load returns the entries recorded at or after since, oldest first.
+// A missing ledger has no entries.
+func (l *Ledger) Load(since time.Time) ([]Entry, error) {
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// Skip damaged lines, e.g. from a write interrupted by a crash
+			slog.Warn("skipping invalid usage entry", "file", l.path, "line", line, "error", err)
+			continue
+		}
+		if !entry.Time.Before(since) {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", l.path, err)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
+
+// MonthlyCost returns the cost of the calls made in the calendar month of now
+func (l *Ledger) MonthlyCost(now time.Time) (float64, error) {
+	entries, err := l.Load(StartOfMonth(now))
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, entry := range entries {
+		total += entry.Cost
+	}
+	return total, nil
+}
+
+// StartOfMonth returns midnight of the first day of the month of t, in t's location
+func StartOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,96 @@
+package usage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLedger(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), "usage.jsonl"))
+
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: now.AddDate(0, -1, 0), Model: "gpt-4o", SessionID: "a", PromptTokens: 100, CompletionTokens: 50, TotalTokens: 150, Cost: 1},
+		{Time: now.AddDate(0, 0, -2), Model: "gpt-4o", SessionID: "a", PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, Cost: 0.5},
+		{Time: now.AddDate(0, 0, -1), Model: "gemini", SessionID: "b", PromptTokens: 20, CompletionTokens: 10, TotalTokens: 30, Cost: 0.25},
+		{Time: now, Model: "gpt-4o", PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2, Cost: 0.25},
+	}
+	for _, entry := range entries {
+		if err := ledger.Record(entry); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	recent, err := ledger.Load(now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(recent) != 3 {
+		t.Fatalf("Expected 3 entries in the last week, got %d", len(recent))
+	}
+
+	spent, err := ledger.MonthlyCost(now)
+	if err != nil {
+		t.Fatalf("MonthlyCost failed: %v", err)
+	}
+	if spent != 1 {
+		t.Errorf("Expected $1 spent this month, got %v", spent)
+	}
+
+	rows, total, err := Summarize(recent, ByModel)
+	if err != nil {
+		t.Fatalf("Summarize failed: %v", err)
+	}
+	if len(rows) != 2 || rows[0].Key != "gpt-4o" || rows[0].Requests != 2 || rows[0].TotalTokens != 17 {
+		t.Errorf("Unexpected rows by model: %+v", rows)
+	}
+	if total.Requests != 3 || total.Cost != 1 {
+		t.Errorf("Unexpected total: %+v", total)
+	}
+
+	rows, _, _ = Summarize(recent, BySession)
+	if len(rows) != 3 {
+		t.Errorf("Expected entries without a session to be grouped separately, got %+v", rows)
+	}
+
+	if _, _, err := Summarize(recent, "week"); err == nil {
+		t.Errorf("Expected error for unknown grouping")
+	}
+}
+
+func TestLedger_Missing(t *testing.T) {
+	ledger := NewLedger(filepath.Join(t.TempDir(), "usage.jsonl"))
+
+	entries, err := ledger.Load(time.Time{})
+	if err != nil || len(entries) != 0 {
+		t.Errorf("Expected no entries and no error, got %v, %v", entries, err)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 20, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "7d", want: now.AddDate(0, 0, -7)},
+		{value: "12h", want: now.Add(-12 * time.Hour)},
+		{value: "2024-05-01", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "last week", wantErr: true},
+		{value: "-3d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSince(tt.value, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSince(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !got.Equal(tt.want) {
+			t.Errorf("ParseSince(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
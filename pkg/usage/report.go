@@ -0,0 +1,102 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Groupings accepted by Summarize
+const (
+	ByModel   = "model"
+	ByDay     = "day"
+	BySession = "session"
+)
+
+// Row is the usage of one group in a report
+type Row struct {
+	Key              string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	Cost             float64
+}
+
+// add accumulates an entry into the row
+func (r *Row) add(entry Entry) {
+	r.Requests++
+	r.PromptTokens += entry.PromptTokens
+	r.CompletionTokens += entry.CompletionTokens
+	r.TotalTokens += entry.TotalTokens
+	r.Cost += entry.Cost
+}
+
+// Summarize groups entries by model, day or session and returns one row per
+// group and the total. Days are sorted in calendar order, other groups by cost.
+func Summarize(entries []Entry, by string) ([]Row, Row, error) {
+	var keyOf func(Entry) string
+	switch by {
+	case ByModel:
+		keyOf = func(e Entry) string { return e.Model }
+	case ByDay:
+		keyOf = func(e Entry) string { return e.Time.Local().Format("2006-01-02") }
+	case BySession:
+		keyOf = func(e Entry) string {
+			if e.SessionID == "" {
+				return "(none)"
+			}
+			return e.SessionID
+		}
+	default:
+		return nil, Row{}, fmt.Errorf("unknown grouping %q, expected %s, %s or %s", by, ByModel, ByDay, BySession)
+	}
+
+	groups := make(map[string]*Row)
+	total := Row{Key: "Total"}
+	for _, entry := range entries {
+		key := keyOf(entry)
+		row, exists := groups[key]
+		if !exists {
+			row = &Row{Key: key}
+			groups[key] = row
+		}
+		row.add(entry)
+		total.add(entry)
+	}
+
+	rows := make([]Row, 0, len(groups))
+	for _, row := range groups {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if by == ByDay {
+			return rows[i].Key < rows[j].Key
+		}
+		if rows[i].Cost != rows[j].Cost {
+			return rows[i].Cost > rows[j].Cost
+		}
+		return rows[i].Key < rows[j].Key
+	})
+
+	return rows, total, nil
+}
+
+// ParseSince parses a report start: a number of days ("7d"), a Go duration
+// ("12h") or a date ("2024-05-01"), relative to now
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n >= 0 {
+			return now.AddDate(0, 0, -n), nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+	if date, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+		return date, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected e.g. 7d, 12h or 2024-05-01", value)
+}
@@ -2,6 +2,9 @@ package util
 
 import (
 	"fmt"
+	"io"
+	"mime"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -88,6 +91,14 @@ func ReadTextFile(filename string) (string, error) {
 	return string(content), nil
 }
 
+// SplitLines splits text into lines, without a last empty line
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
 // GetFileInfo gets file information
 func GetFileInfo(filename string) (string, string, error) {
 	// Read file content
@@ -97,13 +108,69 @@ func GetFileInfo(filename string) (string, string, error) {
 	}
 
 	// Get language type
-	language := detectLanguage(filename)
+	language := DetectLanguage(filename)
 
 	return content, language, nil
 }
 
-// detectLanguage detects programming language based on file extension
-func detectLanguage(filename string) string {
+// imageTypes are the MIME types of the images models read
+var imageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// DetectMIMEType detects the MIME type of a file from its content, then from its extension
+func DetectMIMEType(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %s", err)
+	}
+	defer file.Close()
+
+	// The content type is detected from the first 512 bytes at most
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("failed to read file: %s", err)
+	}
+
+	mimeType := http.DetectContentType(head[:n])
+	if mimeType == "application/octet-stream" || strings.HasPrefix(mimeType, "text/plain") {
+		if byExtension := mime.TypeByExtension(filepath.Ext(filename)); byExtension != "" {
+			mimeType = byExtension
+		}
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	return mimeType, nil
+}
+
+// IsImageFile checks if a file is an image models can read: PNG, JPEG, GIF or WebP
+func IsImageFile(filename string) bool {
+	mimeType, err := DetectMIMEType(filename)
+	return err == nil && imageTypes[mimeType]
+}
+
+// ReadImageFile reads an image file and returns its content and MIME type
+func ReadImageFile(filename string) ([]byte, string, error) {
+	mimeType, err := DetectMIMEType(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	if !imageTypes[mimeType] {
+		return nil, "", fmt.Errorf("unsupported image type %s: %s", mimeType, filename)
+	}
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file: %s", err)
+	}
+	return content, mimeType, nil
+}
+
+// DetectLanguage detects programming language based on file extension
+func DetectLanguage(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
 
 	// Simple mapping from extension to language
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectMIMEType(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// PNG is sniffed from the content, whatever the extension
+	png := write("screenshot.dat", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	if mimeType, err := DetectMIMEType(png); err != nil || mimeType != "image/png" {
+		t.Errorf("Expected image/png, got %q, %v", mimeType, err)
+	}
+	data, mimeType, err := ReadImageFile(png)
+	if err != nil || mimeType != "image/png" || len(data) != 16 {
+		t.Errorf("ReadImageFile = %d bytes, %q, %v", len(data), mimeType, err)
+	}
+
+	// Content that can't be sniffed falls back to the extension
+	webp := write("photo.webp", "\x00\x01\x02\x03")
+	if mimeType, err := DetectMIMEType(webp); err != nil || mimeType != "image/webp" {
+		t.Errorf("Expected image/webp from the extension, got %q, %v", mimeType, err)
+	}
+	if !IsImageFile(webp) {
+		t.Error("Expected a WebP file to be an image")
+	}
+
+	// Other types, SVG included, are not images models read
+	svg := write("diagram.svg", `<svg xmlns="http://www.w3.org/2000/svg"></svg>`)
+	if IsImageFile(svg) {
+		t.Error("Expected an SVG file not to be an image models read")
+	}
+	if _, _, err := ReadImageFile(svg); err == nil {
+		t.Error("Expected an error reading an SVG file as an image")
+	}
+	if IsImageFile(write("main.go", "package main\n")) || IsImageFile(filepath.Join(dir, "missing.png")) {
+		t.Error("Expected text and missing files not to be images")
+	}
+}
@@ -0,0 +1,80 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GitRoot returns the top-level directory of the git repository containing dir,
+// or an empty string when dir is not inside a repository
+func GitRoot(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		// .git is a directory, or a file in worktrees and submodules
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// GitBranch returns the branch checked out in the repository at root.
+// A detached HEAD is reported as the abbreviated commit hash.
+// It returns an empty string when the branch cannot be determined.
+func GitBranch(root string) string {
+	gitDir := filepath.Join(root, ".git")
+
+	// In worktrees and submodules .git is a file pointing to the git directory
+	if info, err := os.Stat(gitDir); err == nil && !info.IsDir() {
+		data, err := os.ReadFile(gitDir)
+		if err != nil {
+			return ""
+		}
+		target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+		if !ok {
+			return ""
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(root, target)
+		}
+		gitDir = target
+	}
+
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	head := strings.TrimSpace(string(data))
+	if ref, ok := strings.CutPrefix(head, "ref: "); ok {
+		return strings.TrimPrefix(ref, "refs/heads/")
+	}
+	if len(head) > 7 {
+		return head[:7]
+	}
+	return head
+}
+
+// Workspace returns the directory a conversation belongs to: the git root when
+// dir is inside a repository, dir itself otherwise. The branch is empty outside of git.
+func Workspace(dir string) (root, branch string) {
+	if root = GitRoot(dir); root != "" {
+		return root, GitBranch(root)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir, ""
+	}
+	return abs, ""
+}
@@ -0,0 +1,14 @@
+//go:build !unix && !windows
+
+package util
+
+import "os"
+
+// Advisory locking is not available on this platform, writes are still atomic
+func lockFile(file *os.File) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,97 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ConfigDirEnv overrides the directory holding the configuration and data files
+const ConfigDirEnv = "AI_CONFIG_DIR"
+
+// ConfigDir returns the directory holding config.yaml.
+//
+// Precedence: $AI_CONFIG_DIR, then $XDG_CONFIG_HOME/ai, then ~/.ai.
+// An existing ~/.ai is kept while $XDG_CONFIG_HOME/ai does not exist.
+func ConfigDir() string {
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return dir
+	}
+	return xdgDir("XDG_CONFIG_HOME")
+}
+
+// DataDir returns the directory holding history and other data files.
+//
+// Precedence: $AI_CONFIG_DIR, then $XDG_DATA_HOME/ai, then ~/.ai.
+// An existing ~/.ai is kept while $XDG_DATA_HOME/ai does not exist.
+func DataDir() string {
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return dir
+	}
+	return xdgDir("XDG_DATA_HOME")
+}
+
+// CacheDir returns the directory holding cached data that can be safely deleted.
+//
+// Precedence: $AI_CONFIG_DIR/cache, then $XDG_CACHE_HOME/ai, then ~/.ai/cache.
+func CacheDir() string {
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return filepath.Join(dir, "cache")
+	}
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "ai")
+	}
+	return filepath.Join(legacyDir(), "cache")
+}
+
+// xdgDir returns the ai directory under the XDG base directory in env. The files of
+// users who upgraded stay in ~/.ai until the XDG directory is created.
+func xdgDir(env string) string {
+	legacy := legacyDir()
+	base := os.Getenv(env)
+	if base == "" {
+		return legacy
+	}
+
+	dir := filepath.Join(base, "ai")
+	if !isDir(dir) && isDir(legacy) {
+		return legacy
+	}
+	return dir
+}
+
+// isDir reports whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// legacyDir returns ~/.ai, used when no override is set
+func legacyDir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = "."
+	}
+	return filepath.Join(homeDir, ".ai")
+}
+
+// FindUp looks for a file in dir and its parent directories.
+// It returns the path of the first match, or an empty string.
+func FindUp(dir, name string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
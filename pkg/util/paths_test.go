@@ -0,0 +1,57 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigDir(t *testing.T) {
+	home := t.TempDir()
+	xdgConfig := filepath.Join(t.TempDir(), "config")
+	xdgData := filepath.Join(t.TempDir(), "data")
+	t.Setenv("HOME", home)
+	t.Setenv(ConfigDirEnv, "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+	legacy := filepath.Join(home, ".ai")
+
+	// Without overrides, ~/.ai
+	if got := ConfigDir(); got != legacy {
+		t.Errorf("ConfigDir() = %s, want %s", got, legacy)
+	}
+
+	// XDG directories when ~/.ai does not exist
+	t.Setenv("XDG_CONFIG_HOME", xdgConfig)
+	t.Setenv("XDG_DATA_HOME", xdgData)
+	if got := ConfigDir(); got != filepath.Join(xdgConfig, "ai") {
+		t.Errorf("ConfigDir() = %s, want the XDG config directory", got)
+	}
+	if got := DataDir(); got != filepath.Join(xdgData, "ai") {
+		t.Errorf("DataDir() = %s, want the XDG data directory", got)
+	}
+
+	// An existing ~/.ai is kept until the XDG directory exists
+	if err := os.MkdirAll(legacy, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if got := ConfigDir(); got != legacy {
+		t.Errorf("ConfigDir() = %s, want the existing %s", got, legacy)
+	}
+	if got := DataDir(); got != legacy {
+		t.Errorf("DataDir() = %s, want the existing %s", got, legacy)
+	}
+	if err := os.MkdirAll(filepath.Join(xdgConfig, "ai"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if got := ConfigDir(); got != filepath.Join(xdgConfig, "ai") {
+		t.Errorf("ConfigDir() = %s, want the existing XDG config directory", got)
+	}
+
+	// AI_CONFIG_DIR takes precedence over everything
+	override := t.TempDir()
+	t.Setenv(ConfigDirEnv, override)
+	if ConfigDir() != override || DataDir() != override {
+		t.Errorf("Expected %s for config and data, got %s and %s", override, ConfigDir(), DataDir())
+	}
+}
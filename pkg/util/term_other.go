@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package util
+
+import "os"
+
+// TerminalSize is not available on this platform, output is never treated as a terminal
+func TerminalSize(file *os.File) (width, height int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package util
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// TerminalSize returns the size of the terminal file is attached to.
+// It reports false when file is not a terminal.
+func TerminalSize(file *os.File) (width, height int, ok bool) {
+	ws, err := unix.IoctlGetWinsize(int(file.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 0, 0, false
+	}
+	return int(ws.Col), int(ws.Row), true
+}